@@ -1,11 +1,18 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/luckpoint/list-codes/tui"
 	"github.com/luckpoint/list-codes/utils"
@@ -26,6 +33,27 @@ var (
 	includeTests    bool
 	maxFileSizeStr  string
 	maxTotalSizeStr string
+	maxTokensStr    string
+	splitSizeStr    string
+	focusQuery      string
+	focusTop        int
+	entries         []string
+	entryDepth      int
+	jobs            int
+	noCache         bool
+	force           bool
+	tokenizerName   string
+	sinceRef        string
+	diffRange       string
+	withPatch       bool
+	formatName      string
+	onlyLangs       []string
+	skipLangs       []string
+	stripModes      []string
+	outline         bool
+	outlineLangs    []string
+	redact          bool
+	noRedact        bool
 	noGitignore     bool
 	configFile      string
 	noConfig        bool
@@ -56,19 +84,40 @@ func init() {
 
 	// Flag descriptions (always in English for technical consistency)
 	rootCmd.PersistentFlags().StringVarP(&folder, "folder", "f", ".", "Folder to scan")
-	rootCmd.PersistentFlags().StringVarP(&outputFile, "output", "o", "", "Output Markdown file path")
+	rootCmd.PersistentFlags().StringVarP(&outputFile, "output", "o", "", "Output file path")
+	rootCmd.PersistentFlags().StringVar(&formatName, "format", utils.FormatMarkdown, "Output format (markdown|json|jsonl|xml)")
 	rootCmd.PersistentFlags().BoolVar(&readmeOnly, "readme-only", false, "Only collect README.md files")
 	rootCmd.PersistentFlags().IntVar(&maxDepth, "max-depth", utils.MaxStructureDepthDefault, "Max depth for directory structure")
 	rootCmd.PersistentFlags().BoolVar(&debugMode, "debug", false, "Enable debug mode")
 	rootCmd.PersistentFlags().StringSliceVarP(&includes, "include", "i", []string{}, "Additional path or glob pattern to include beyond defaults (repeatable)")
 	rootCmd.PersistentFlags().StringSliceVarP(&excludes, "exclude", "e", []string{}, "Path or glob pattern to exclude (repeatable)")
+	rootCmd.PersistentFlags().StringSliceVar(&onlyLangs, "only-lang", []string{}, "Only collect files of these languages, e.g. Go,SQL (repeatable)")
+	rootCmd.PersistentFlags().StringSliceVar(&skipLangs, "skip-lang", []string{}, "Skip files of these languages, e.g. HTML,CSS (repeatable)")
 	rootCmd.PersistentFlags().StringVar(&maxFileSizeStr, "max-file-size", "1m", "Maximum file size to include (e.g., 1m, 500k, 2g)")
 	rootCmd.PersistentFlags().StringVar(&maxTotalSizeStr, "max-total-size", "", "Maximum total file size to collect (e.g., 10m, 1g) - empty means no limit")
+	rootCmd.PersistentFlags().StringVar(&maxTokensStr, "max-tokens", "", "Maximum total tokens to collect (e.g., 8000, 128k) - empty means no limit")
+	rootCmd.PersistentFlags().StringVar(&splitSizeStr, "split-size", "", "Split output into parts of this size (e.g., 500k) or token count (e.g., 32k-tokens); requires --output")
+	rootCmd.PersistentFlags().StringSliceVar(&entries, "entry", []string{}, "Entry file whose in-repo imports (Go, JavaScript, TypeScript) are included with it (repeatable)")
+	rootCmd.PersistentFlags().IntVar(&entryDepth, "depth", 1, "How many levels of imports --entry follows")
+	rootCmd.PersistentFlags().StringVar(&focusQuery, "focus", "", "Only collect the files most relevant to keywords or a question, e.g. \"how is gitignore handled\"")
+	rootCmd.PersistentFlags().IntVar(&focusTop, "focus-top", 10, "Number of most relevant files collected with --focus")
+	rootCmd.PersistentFlags().IntVar(&jobs, "jobs", 0, "Number of files read in parallel (0 uses the number of CPUs)")
+	rootCmd.PersistentFlags().BoolVar(&force, "force", false, "Overwrite an output file inside --folder that the scan would collect")
+	rootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "Do not reuse or store prepared files in the file cache")
+	rootCmd.PersistentFlags().StringVar(&tokenizerName, "tokenizer", utils.TokenizerBPE, "Tokenizer used for token counts (bpe|chars)")
+	rootCmd.PersistentFlags().StringVar(&sinceRef, "since", "", "Only collect files changed since a git ref (compared with the working tree)")
+	rootCmd.PersistentFlags().StringVar(&diffRange, "diff", "", "Only collect files changed in a git range (<base>..<head>)")
+	rootCmd.PersistentFlags().BoolVar(&withPatch, "with-patch", false, "Add each changed file's unified diff after its content (with --since/--diff)")
 	rootCmd.PersistentFlags().StringVarP(&prompt, "prompt", "p", "", "Prompt text or template name to prepend to output (accepts both predefined templates and custom text)")
 	rootCmd.PersistentFlags().StringVar(&langFlag, "lang", "", "Force language (ja|en) instead of auto-detection")
 	rootCmd.PersistentFlags().BoolP("version", "v", false, "Show version information")
 	rootCmd.PersistentFlags().BoolVar(&includeTests, "include-tests", false, "Include test files in the output")
 	rootCmd.PersistentFlags().BoolVar(&noGitignore, "no-gitignore", false, "Disable .gitignore file processing")
+	rootCmd.PersistentFlags().StringSliceVar(&stripModes, "strip", []string{}, "Strip content to save context: comments, license, blank, docstrings (repeatable)")
+	rootCmd.PersistentFlags().BoolVar(&outline, "outline", false, "Emit declarations and signatures instead of full source files (Go, Python, Javascript, Typescript, Java, Rust)")
+	rootCmd.PersistentFlags().StringSliceVar(&outlineLangs, "outline-lang", []string{}, "Only outline files of these languages, e.g. Python,Typescript; implies --outline (repeatable)")
+	rootCmd.PersistentFlags().BoolVar(&redact, "redact", true, "Replace secrets such as API keys, private keys and tokens with [REDACTED:<kind>]")
+	rootCmd.PersistentFlags().BoolVar(&noRedact, "no-redact", false, "Disable secret redaction")
 	rootCmd.Flags().StringVarP(&configFile, "config", "c", "", "Config file path (.list-codes.yaml)")
 	rootCmd.PersistentFlags().BoolVar(&noConfig, "no-config", false, "Disable auto-loading .list-codes.yaml")
 
@@ -77,6 +126,14 @@ func init() {
 
 	rootCmd.AddCommand(completionCmd)
 	rootCmd.AddCommand(selectCmd)
+	cacheCmd.AddCommand(cacheCleanCmd)
+	rootCmd.AddCommand(cacheCmd)
+
+	watchCmd.Flags().StringVarP(&configFile, "config", "c", "", "Config file path (.list-codes.yaml)")
+	watchCmd.Flags().DurationVar(&utils.WatchDebounce, "debounce", utils.WatchDebounce, "Wait this long after the last change before regenerating")
+	watchCmd.Flags().BoolVar(&utils.WatchPoll, "poll", false, "Poll for changes instead of using inotify")
+	watchCmd.Flags().DurationVar(&utils.WatchPollInterval, "poll-interval", utils.WatchPollInterval, "How often to poll for changes")
+	rootCmd.AddCommand(watchCmd)
 }
 
 var rootCmd = &cobra.Command{
@@ -89,197 +146,533 @@ var rootCmd = &cobra.Command{
 			return
 		}
 
-		// Auto-detect config file
-		if configFile == "" && !noConfig {
-			defaultCfg := filepath.Join(folder, ".list-codes.yaml")
-			if _, err := os.Stat(defaultCfg); err == nil {
-				configFile = defaultCfg
-			}
-		}
-
-		// Load config file if specified
-		if configFile != "" {
-			cfg, cfgErr := tui.LoadConfig(configFile)
-			if cfgErr != nil {
-				utils.PrintError(fmt.Sprintf("Could not load config '%s': %v", configFile, cfgErr))
+		opts := resolveRunOptions(cmd)
+		removeTemporaryFilesOnSignal()
+
+		// Every part carries the prompt, so split output applies it per part
+		// and needs the whole output before it can be cut.
+		if opts.splitLimit.Enabled() {
+			var outputMD strings.Builder
+			promptless := opts
+			promptless.promptText = ""
+			if err := writeOutput(&outputMD, promptless); err != nil {
+				utils.PrintError(fmt.Sprintf("Could not save output parts for '%s': %v", outputFile, err))
 				os.Exit(1)
 			}
-			// Prepend config patterns (CLI flags take priority by being appended later)
-			includes = append(cfg.Include, includes...)
-			excludes = append(cfg.Exclude, excludes...)
-			// Apply options only when CLI flags are not explicitly set
-			if cfg.Options != nil {
-				if !cmd.Flags().Changed("include-tests") && cfg.Options.IncludeTests {
-					includeTests = true
-				}
-				if !cmd.Flags().Changed("max-file-size") && cfg.Options.MaxFileSize != "" {
-					maxFileSizeStr = cfg.Options.MaxFileSize
-				}
-				if !cmd.Flags().Changed("max-depth") && cfg.Options.MaxDepth > 0 {
-					maxDepth = cfg.Options.MaxDepth
-				}
+			parts := utils.SplitMarkdownOutput(outputMD.String(), opts.promptText, opts.splitLimit)
+			paths, err := utils.SaveSplitOutput(parts, outputFile)
+			if err != nil {
+				utils.PrintError(fmt.Sprintf("Could not save output parts for '%s': %v", outputFile, err))
+				os.Exit(1)
 			}
+			utils.PrintDebug(fmt.Sprintf("Wrote %d part(s): %s", len(paths), strings.Join(paths, ", ")), debugMode)
+			utils.PrintDebug("Processing complete.", debugMode)
+			return
 		}
 
-		// Parse size strings to bytes
-		var err error
-		utils.MaxFileSizeBytes, err = utils.ParseSize(maxFileSizeStr)
+		// The output is written while the project is scanned, so a pipe
+		// receives the tree and the first files before the scan finishes.
+		out, err := utils.OpenOutput(outputFile)
 		if err != nil {
-			utils.PrintError(fmt.Sprintf("Invalid --max-file-size: %v", err))
+			utils.PrintError(fmt.Sprintf("Could not save output to '%s': %v", outputFile, err))
 			os.Exit(1)
 		}
-
-		utils.TotalMaxFileSizeBytes, err = utils.ParseSize(maxTotalSizeStr)
+		// A failed run keeps the previous output file.
+		if err = writeOutput(out, opts); err != nil {
+			utils.DiscardOutput(out)
+		} else {
+			err = out.Close()
+		}
 		if err != nil {
-			utils.PrintError(fmt.Sprintf("Invalid --max-total-size: %v", err))
+			utils.PrintError(fmt.Sprintf("Could not save output to '%s': %v", outputFile, err))
 			os.Exit(1)
 		}
 
-		excludeNames := make(map[string]struct{})
-		for k := range utils.DefaultExcludeNames {
-			excludeNames[k] = struct{}{}
+		utils.PrintDebug("Processing complete.", debugMode)
+	},
+}
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Regenerate the output file whenever included files change",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if outputFile == "" {
+			utils.PrintError("watch requires --output")
+			os.Exit(1)
+		}
+		if splitSizeStr != "" {
+			utils.PrintError("watch does not support --split-size")
+			os.Exit(1)
+		}
+		if utils.WatchDebounce < 0 || utils.WatchPollInterval <= 0 {
+			utils.PrintError("--debounce must not be negative and --poll-interval must be positive")
+			os.Exit(1)
 		}
-		utils.PrintDebug("Default exclude names: "+joinSet(excludeNames), debugMode)
 
-		folderAbs, err := filepath.Abs(folder)
+		opts := resolveRunOptions(cmd)
+		outputAbs, err := filepath.Abs(outputFile)
 		if err != nil {
-			utils.PrintError(fmt.Sprintf("Could not resolve absolute path for folder '%s': %v", folder, err))
+			utils.PrintError(fmt.Sprintf("Could not resolve absolute path for output '%s': %v", outputFile, err))
 			os.Exit(1)
 		}
-		utils.PrintDebug("Scanning folder: "+folderAbs, debugMode)
-
-		// Process excludes
-		var excludePatterns []string
-		for _, p := range excludes {
-			// If it contains glob characters, handle anchoring
-			if strings.ContainsAny(p, "*?[]") {
-				pattern := filepath.ToSlash(p)
-				// If it doesn't contain "**" and doesn't start with "/", anchor it to root
-				// This follows the requirement: "*.md" matches root only, "**/*.md" matches recursively.
-				if !strings.Contains(pattern, "**") && !strings.HasPrefix(pattern, "/") {
-					pattern = "/" + pattern
+		regenerate := func() error {
+			if sinceRef != "" || diffRange != "" {
+				if err := loadGitChanges(opts.folderAbs); err != nil {
+					return fmt.Errorf("could not read git changes: %w", err)
 				}
-				excludePatterns = append(excludePatterns, pattern)
-				continue
 			}
-
-			// Resolve to absolute path first to handle "relative to current dir" vs "relative to folder"
-			abs := p
-			if !filepath.IsAbs(abs) {
-				abs = filepath.Join(folder, p)
+			var buf bytes.Buffer
+			if err := writeOutput(&buf, opts); err != nil {
+				return fmt.Errorf("could not generate output: %w", err)
 			}
-			abs, err = filepath.Abs(abs)
-			if err == nil {
-				rel, err := filepath.Rel(folderAbs, abs)
-				if err == nil {
-					// Windows backslash to slash
-					rel = filepath.ToSlash(rel)
-					// Anchor to root to preserve "path relative to folder" semantics
-					// and prevent accidental matching of deeply nested files with same name
-					if !strings.HasPrefix(rel, "/") {
-						rel = "/" + rel
-					}
-					excludePatterns = append(excludePatterns, rel)
-				}
+			written, err := utils.RewriteOutput(outputFile, buf.Bytes())
+			if err != nil {
+				return fmt.Errorf("could not save output to '%s': %w", outputFile, err)
+			}
+			if written {
+				fmt.Fprintf(os.Stderr, "Wrote %s (%s)\n", outputFile, time.Now().Format(time.TimeOnly))
 			} else {
-				utils.PrintWarning(fmt.Sprintf("Could not resolve path for exclude '%s': %v", p, err), debugMode)
+				utils.PrintDebug("Output unchanged: "+outputFile, debugMode)
 			}
+			return nil
 		}
 
-		excludeMatcher, err := utils.NewSimpleMatcher(folderAbs, excludePatterns)
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		err = utils.WatchProject(ctx, opts.folderAbs, opts.includePaths, opts.includeMatcher, opts.excludeNames, opts.excludeMatcher, opts.gitIgnoreMatcher, includeTests, []string{outputAbs}, debugMode, regenerate)
 		if err != nil {
-			utils.PrintWarning(fmt.Sprintf("Failed to create exclude matcher: %v", err), debugMode)
+			utils.PrintError(err.Error())
+			os.Exit(1)
 		}
-		if len(excludePatterns) > 0 {
-			utils.PrintDebug("User exclude patterns: "+strings.Join(excludePatterns, ", "), debugMode)
+	},
+}
+
+// removeTemporaryFilesOnSignal makes SIGINT and SIGTERM remove the
+// temporary output files before the process exits, so an interrupted run
+// keeps the previous output and leaves nothing behind.
+func removeTemporaryFilesOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		utils.RemoveTemporaryFiles()
+		code := 1
+		if number, ok := sig.(syscall.Signal); ok {
+			code = 128 + int(number)
 		}
+		os.Exit(code)
+	}()
+}
 
-		// Process includes
-		includePaths := make(map[string]struct{})
-		var includePatterns []string
+// runOptions is what resolveRunOptions derives from the flags and the
+// config file for writing the output.
+type runOptions struct {
+	folderAbs        string
+	includePaths     map[string]struct{}
+	includeMatcher   *utils.SimpleMatcher
+	excludeNames     map[string]struct{}
+	excludeMatcher   *utils.SimpleMatcher
+	gitIgnoreMatcher *utils.GitIgnoreMatcher
+	promptText       string
+	splitLimit       utils.SplitLimit
+}
 
-		for _, p := range includes {
-			// If it contains glob characters, handle anchoring
-			if strings.ContainsAny(p, "*?[]") {
-				pattern := filepath.ToSlash(p)
-				// If it doesn't contain "**" and doesn't start with "/", anchor it to root
-				if !strings.Contains(pattern, "**") && !strings.HasPrefix(pattern, "/") {
-					pattern = "/" + pattern
-				}
-				includePatterns = append(includePatterns, pattern)
-				continue
+// resolveRunOptions loads the config file, validates the flags and
+// installs the settings of the utils package. It exits on invalid input.
+func resolveRunOptions(cmd *cobra.Command) runOptions {
+	// Auto-detect config file
+	if configFile == "" && !noConfig {
+		defaultCfg := filepath.Join(folder, ".list-codes.yaml")
+		if _, err := os.Stat(defaultCfg); err == nil {
+			configFile = defaultCfg
+		}
+	}
+
+	// Load config file if specified
+	if configFile != "" {
+		cfg, cfgErr := tui.LoadConfig(configFile)
+		if cfgErr != nil {
+			utils.PrintError(fmt.Sprintf("Could not load config '%s': %v", configFile, cfgErr))
+			os.Exit(1)
+		}
+		// Prepend config patterns (CLI flags take priority by being appended later)
+		includes = append(cfg.Include, includes...)
+		excludes = append(cfg.Exclude, excludes...)
+		for _, pattern := range cfg.Redact {
+			if err := utils.AddRedactionPattern(pattern.Name, pattern.Pattern); err != nil {
+				utils.PrintError(fmt.Sprintf("Invalid redact pattern in '%s': %v", configFile, err))
+				os.Exit(1)
+			}
+		}
+		for _, rule := range cfg.Priority {
+			if err := utils.AddPriorityPattern(rule.Pattern, rule.Weight); err != nil {
+				utils.PrintError(fmt.Sprintf("Invalid priority pattern in '%s': %v", configFile, err))
+				os.Exit(1)
+			}
+		}
+		// Apply options only when CLI flags are not explicitly set
+		if cfg.Options != nil {
+			if !cmd.Flags().Changed("include-tests") && cfg.Options.IncludeTests {
+				includeTests = true
+			}
+			if !cmd.Flags().Changed("max-file-size") && cfg.Options.MaxFileSize != "" {
+				maxFileSizeStr = cfg.Options.MaxFileSize
+			}
+			if !cmd.Flags().Changed("max-depth") && cfg.Options.MaxDepth > 0 {
+				maxDepth = cfg.Options.MaxDepth
 			}
+			if !cmd.Flags().Changed("only-lang") && len(cfg.Options.OnlyLang) > 0 {
+				onlyLangs = cfg.Options.OnlyLang
+			}
+			if !cmd.Flags().Changed("skip-lang") && len(cfg.Options.SkipLang) > 0 {
+				skipLangs = cfg.Options.SkipLang
+			}
+			if !cmd.Flags().Changed("strip") && len(cfg.Options.Strip) > 0 {
+				stripModes = cfg.Options.Strip
+			}
+			if !cmd.Flags().Changed("outline") && cfg.Options.Outline {
+				outline = true
+			}
+			if !cmd.Flags().Changed("outline-lang") && len(cfg.Options.OutlineLang) > 0 {
+				outlineLangs = cfg.Options.OutlineLang
+			}
+		}
+	}
+
+	// Parse size strings to bytes
+	var err error
+	utils.MaxFileSizeBytes, err = utils.ParseSize(maxFileSizeStr)
+	if err != nil {
+		utils.PrintError(fmt.Sprintf("Invalid --max-file-size: %v", err))
+		os.Exit(1)
+	}
+
+	utils.TotalMaxFileSizeBytes, err = utils.ParseSize(maxTotalSizeStr)
+	if err != nil {
+		utils.PrintError(fmt.Sprintf("Invalid --max-total-size: %v", err))
+		os.Exit(1)
+	}
+
+	utils.OutputFormat, err = utils.ParseOutputFormat(formatName)
+	if err != nil {
+		utils.PrintError(fmt.Sprintf("Invalid --format: %v", err))
+		os.Exit(1)
+	}
+
+	utils.MaxTokens, err = utils.ParseTokenCount(maxTokensStr)
+	if err != nil {
+		utils.PrintError(fmt.Sprintf("Invalid --max-tokens: %v", err))
+		os.Exit(1)
+	}
+
+	utils.ActiveTokenizer, err = utils.GetTokenizer(tokenizerName)
+	if err != nil {
+		utils.PrintError(fmt.Sprintf("Invalid --tokenizer: %v", err))
+		os.Exit(1)
+	}
 
-			abs := p
-			if !filepath.IsAbs(abs) {
-				abs = filepath.Join(folder, p)
+	splitLimit, err := utils.ParseSplitSize(splitSizeStr)
+	if err != nil {
+		utils.PrintError(fmt.Sprintf("Invalid --split-size: %v", err))
+		os.Exit(1)
+	}
+	if splitLimit.Enabled() {
+		if outputFile == "" {
+			utils.PrintError("--split-size requires --output")
+			os.Exit(1)
+		}
+		if utils.OutputFormat != utils.FormatMarkdown {
+			utils.PrintError("--split-size only supports Markdown output")
+			os.Exit(1)
+		}
+	}
+
+	if jobs < 0 {
+		utils.PrintError(fmt.Sprintf("Invalid --jobs: %d (must not be negative)", jobs))
+		os.Exit(1)
+	}
+	utils.Jobs = jobs
+	utils.CacheEnabled = !noCache
+
+	if focusTop < 1 {
+		utils.PrintError(fmt.Sprintf("Invalid --focus-top: %d (must be at least 1)", focusTop))
+		os.Exit(1)
+	}
+	utils.FocusQuery = strings.TrimSpace(focusQuery)
+	utils.FocusTop = focusTop
+
+	applyLanguageFilters()
+
+	utils.ActiveStripOptions, err = utils.ParseStripOptions(stripModes)
+	if err != nil {
+		utils.PrintError(fmt.Sprintf("Invalid --strip: %v", err))
+		os.Exit(1)
+	}
+	if utils.ActiveStripOptions.Enabled() {
+		utils.PrintDebug(fmt.Sprintf("Strip modes: %v", stripModes), debugMode)
+	}
+
+	utils.OutlineLanguages, err = utils.ParseOutlineLanguages(outlineLangs)
+	if err != nil {
+		utils.PrintError(fmt.Sprintf("Invalid --outline-lang: %v", err))
+		os.Exit(1)
+	}
+	utils.OutlineMode = outline || len(utils.OutlineLanguages) > 0
+
+	utils.RedactSecrets = redact && !noRedact
+	if !utils.RedactSecrets {
+		utils.PrintDebug("Secret redaction disabled", debugMode)
+	}
+
+	excludeNames := make(map[string]struct{})
+	for k := range utils.DefaultExcludeNames {
+		excludeNames[k] = struct{}{}
+	}
+	utils.PrintDebug("Default exclude names: "+joinSet(excludeNames), debugMode)
+
+	folderAbs, err := filepath.Abs(folder)
+	if err != nil {
+		utils.PrintError(fmt.Sprintf("Could not resolve absolute path for folder '%s': %v", folder, err))
+		os.Exit(1)
+	}
+	utils.PrintDebug("Scanning folder: "+folderAbs, debugMode)
+
+	// Process excludes
+	var excludePatterns []string
+	for _, p := range excludes {
+		// If it contains glob characters, handle anchoring
+		if strings.ContainsAny(p, "*?[]") {
+			pattern := filepath.ToSlash(p)
+			// If it doesn't contain "**" and doesn't start with "/", anchor it to root
+			// This follows the requirement: "*.md" matches root only, "**/*.md" matches recursively.
+			if !strings.Contains(pattern, "**") && !strings.HasPrefix(pattern, "/") {
+				pattern = "/" + pattern
 			}
-			resolved, err := filepath.Abs(abs)
+			excludePatterns = append(excludePatterns, pattern)
+			continue
+		}
+
+		// Resolve to absolute path first to handle "relative to current dir" vs "relative to folder"
+		abs := p
+		if !filepath.IsAbs(abs) {
+			abs = filepath.Join(folder, p)
+		}
+		abs, err = filepath.Abs(abs)
+		if err == nil {
+			rel, err := filepath.Rel(folderAbs, abs)
 			if err == nil {
-				// Add to map for "parent of" traversal logic
-				includePaths[resolved] = struct{}{}
-
-				// Add to patterns
-				rel, err := filepath.Rel(folderAbs, resolved)
-				if err == nil {
-					rel = filepath.ToSlash(rel)
-					if !strings.HasPrefix(rel, "/") {
-						rel = "/" + rel
-					}
-					includePatterns = append(includePatterns, rel)
+				// Windows backslash to slash
+				rel = filepath.ToSlash(rel)
+				// Anchor to root to preserve "path relative to folder" semantics
+				// and prevent accidental matching of deeply nested files with same name
+				if !strings.HasPrefix(rel, "/") {
+					rel = "/" + rel
 				}
-			} else {
-				utils.PrintWarning(fmt.Sprintf("Could not resolve absolute path for include '%s': %v", p, err), debugMode)
+				excludePatterns = append(excludePatterns, rel)
 			}
+		} else {
+			utils.PrintWarning(fmt.Sprintf("Could not resolve path for exclude '%s': %v", p, err), debugMode)
 		}
+	}
 
-		includeMatcher, err := utils.NewSimpleMatcher(folderAbs, includePatterns)
+	excludeMatcher, err := utils.NewSimpleMatcher(folderAbs, excludePatterns)
+	if err != nil {
+		utils.PrintWarning(fmt.Sprintf("Failed to create exclude matcher: %v", err), debugMode)
+	}
+	if len(excludePatterns) > 0 {
+		utils.PrintDebug("User exclude patterns: "+strings.Join(excludePatterns, ", "), debugMode)
+	}
+
+	// Entry files and the files they import join the include set.
+	if len(entries) > 0 {
+		if entryDepth < 0 {
+			utils.PrintError(fmt.Sprintf("Invalid --depth: %d (must not be negative)", entryDepth))
+			os.Exit(1)
+		}
+		related, err := utils.ResolveEntryImports(folderAbs, entries, entryDepth, debugMode)
 		if err != nil {
-			utils.PrintWarning(fmt.Sprintf("Failed to create include matcher: %v", err), debugMode)
+			utils.PrintError(fmt.Sprintf("Invalid --entry: %v", err))
+			os.Exit(1)
+		}
+		utils.PrintDebug(fmt.Sprintf("Entry files and imports: %d file(s)", len(related)), debugMode)
+		includes = append(includes, related...)
+	}
+
+	// Process includes
+	includePaths := make(map[string]struct{})
+	var includePatterns []string
+
+	for _, p := range includes {
+		// If it contains glob characters, handle anchoring
+		if strings.ContainsAny(p, "*?[]") {
+			pattern := filepath.ToSlash(p)
+			// If it doesn't contain "**" and doesn't start with "/", anchor it to root
+			if !strings.Contains(pattern, "**") && !strings.HasPrefix(pattern, "/") {
+				pattern = "/" + pattern
+			}
+			includePatterns = append(includePatterns, pattern)
+			continue
 		}
-		utils.PrintDebug("User included absolute paths: "+joinSet(includePaths), debugMode)
 
-		// Create GitIgnoreMatcher if --no-gitignore is not set
-		var gitIgnoreMatcher *utils.GitIgnoreMatcher
-		if !noGitignore {
-			matcher, err := utils.NewGitIgnoreMatcher(folderAbs)
-			if err != nil {
-				utils.PrintWarning(fmt.Sprintf("Could not create gitignore matcher: %v", err), debugMode)
-			} else {
-				gitIgnoreMatcher = matcher
-				utils.PrintDebug("GitIgnore matcher created successfully", debugMode)
+		abs := p
+		if !filepath.IsAbs(abs) {
+			abs = filepath.Join(folder, p)
+		}
+		resolved, err := filepath.Abs(abs)
+		if err == nil {
+			// Add to map for "parent of" traversal logic
+			includePaths[resolved] = struct{}{}
+
+			// Add to patterns
+			rel, err := filepath.Rel(folderAbs, resolved)
+			if err == nil {
+				rel = filepath.ToSlash(rel)
+				if !strings.HasPrefix(rel, "/") {
+					rel = "/" + rel
+				}
+				includePatterns = append(includePatterns, rel)
 			}
 		} else {
-			utils.PrintDebug("GitIgnore processing disabled via --no-gitignore flag", debugMode)
+			utils.PrintWarning(fmt.Sprintf("Could not resolve absolute path for include '%s': %v", p, err), debugMode)
 		}
+	}
+
+	includeMatcher, err := utils.NewSimpleMatcher(folderAbs, includePatterns)
+	if err != nil {
+		utils.PrintWarning(fmt.Sprintf("Failed to create include matcher: %v", err), debugMode)
+	}
+	utils.PrintDebug("User included absolute paths: "+joinSet(includePaths), debugMode)
 
-		var outputMD string
-		if readmeOnly {
-			utils.PrintDebug("Mode: Collecting README.md files only.", debugMode)
-			outputMD = utils.CollectReadmeFiles(folderAbs, includePaths, includeMatcher, excludeNames, excludeMatcher, debugMode, gitIgnoreMatcher)
+	// Create GitIgnoreMatcher if --no-gitignore is not set
+	var gitIgnoreMatcher *utils.GitIgnoreMatcher
+	if !noGitignore {
+		matcher, err := utils.NewGitIgnoreMatcher(folderAbs)
+		if err != nil {
+			utils.PrintWarning(fmt.Sprintf("Could not create gitignore matcher: %v", err), debugMode)
 		} else {
-			utils.PrintDebug("Mode: Summarizing project.", debugMode)
-			outputMD = utils.ProcessSourceFiles(folderAbs, maxDepth, includePaths, includeMatcher, excludeNames, excludeMatcher, debugMode, includeTests, gitIgnoreMatcher)
+			gitIgnoreMatcher = matcher
+			utils.PrintDebug("GitIgnore matcher created successfully", debugMode)
 		}
+	} else {
+		utils.PrintDebug("GitIgnore processing disabled via --no-gitignore flag", debugMode)
+	}
 
-		// Apply prompt if specified
-		if prompt != "" {
-			promptText, err := utils.GetPrompt(prompt, debugMode)
-			if err != nil {
-				utils.PrintError(fmt.Sprintf("Could not process prompt '%s': %v", prompt, err))
+	// The output must not replace a file the scan collects, and is never
+	// collected itself. The check runs before --since and --diff narrow the
+	// collection, so a typo cannot overwrite an unchanged source file. The
+	// output of an earlier run is not a source and is replaced.
+	if outputFile != "" {
+		outputs := []string{outputFile}
+		if splitLimit.Enabled() {
+			outputs = existingSplitParts(outputFile)
+		}
+		for _, output := range outputs {
+			if force || utils.IsProjectOutput(folderAbs, output) {
+				continue
+			}
+			if utils.WouldCollect(folderAbs, output, includePaths, includeMatcher, excludeNames, excludeMatcher, readmeOnly, includeTests, gitIgnoreMatcher) {
+				utils.PrintError(fmt.Sprintf("Refusing to overwrite '%s': it is inside the scanned folder and would be collected (use --force to overwrite it)", output))
 				os.Exit(1)
 			}
-			outputMD = utils.FormatWithPrompt(promptText, outputMD)
-			utils.PrintDebug("Applied prompt to output", debugMode)
 		}
+		excludePatterns = append(excludePatterns, outputExcludePatterns(folderAbs, outputFile, splitLimit.Enabled())...)
+		excludeMatcher, err = utils.NewSimpleMatcher(folderAbs, excludePatterns)
+		if err != nil {
+			utils.PrintWarning(fmt.Sprintf("Failed to create exclude matcher: %v", err), debugMode)
+		}
+	}
 
-		if err := utils.SaveToMarkdown(outputMD, outputFile); err != nil {
-			utils.PrintError(fmt.Sprintf("Could not save output to '%s': %v", outputFile, err))
+	if sinceRef != "" || diffRange != "" {
+		if err := loadGitChanges(folderAbs); err != nil {
+			utils.PrintError(fmt.Sprintf("Could not read git changes: %v", err))
 			os.Exit(1)
 		}
+	} else if withPatch {
+		utils.PrintError("--with-patch requires --since or --diff")
+		os.Exit(1)
+	}
 
-		utils.PrintDebug("Processing complete.", debugMode)
-	},
+	if readmeOnly {
+		utils.PrintDebug("Mode: Collecting README.md files only.", debugMode)
+	} else {
+		utils.PrintDebug("Mode: Summarizing project.", debugMode)
+	}
+
+	var promptText string
+	if prompt != "" {
+		promptText, err = utils.GetPrompt(prompt, debugMode)
+		if err != nil {
+			utils.PrintError(fmt.Sprintf("Could not process prompt '%s': %v", prompt, err))
+			os.Exit(1)
+		}
+	}
+
+	return runOptions{
+		folderAbs:        folderAbs,
+		includePaths:     includePaths,
+		includeMatcher:   includeMatcher,
+		excludeNames:     excludeNames,
+		excludeMatcher:   excludeMatcher,
+		gitIgnoreMatcher: gitIgnoreMatcher,
+		promptText:       promptText,
+		splitLimit:       splitLimit,
+	}
+}
+
+// writeOutput writes the README files or the project summary to w.
+func writeOutput(w io.Writer, opts runOptions) error {
+	if readmeOnly {
+		return utils.WriteReadmeFiles(w, opts.promptText, opts.folderAbs, opts.includePaths, opts.includeMatcher, opts.excludeNames, opts.excludeMatcher, debugMode, opts.gitIgnoreMatcher)
+	}
+	return utils.WriteProjectSummary(w, opts.promptText, opts.folderAbs, maxDepth, opts.includePaths, opts.includeMatcher, opts.excludeNames, opts.excludeMatcher, debugMode, includeTests, opts.gitIgnoreMatcher)
+}
+
+// existingSplitParts returns the part files of an earlier --split-size run
+// that a new run may overwrite.
+func existingSplitParts(outputPath string) []string {
+	var parts []string
+	for number := 1; ; number++ {
+		part := utils.SplitPartPath(outputPath, number)
+		if _, err := os.Lstat(part); err != nil {
+			return parts
+		}
+		parts = append(parts, part)
+	}
+}
+
+// outputExcludePatterns anchors the output file, or with split the pattern
+// of its part files, to the scanned folder, together with the temporary
+// files utils.OpenOutput writes next to them while the scan runs. An output
+// outside the folder needs no pattern.
+func outputExcludePatterns(folderAbs, outputPath string, split bool) []string {
+	outputAbs, err := filepath.Abs(outputPath)
+	if err != nil {
+		return nil
+	}
+	rel, err := filepath.Rel(folderAbs, outputAbs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil
+	}
+	rel = "/" + filepath.ToSlash(rel)
+	if split {
+		ext := path.Ext(rel)
+		rel = strings.TrimSuffix(rel, ext) + ".part-*" + ext
+	}
+	return []string{rel, rel + ".tmp-*"}
+}
+
+// loadGitChanges installs the files changed according to --since or --diff.
+func loadGitChanges(folderAbs string) error {
+	changes, err := utils.LoadGitChanges(folderAbs, sinceRef, diffRange, withPatch)
+	if err != nil {
+		return err
+	}
+	if missing := changes.Missing(); len(missing) > 0 {
+		utils.PrintWarning(fmt.Sprintf("Skipping %d file(s) changed in %s that are not in the working tree: %s", len(missing), changes.Range, strings.Join(missing, ", ")), true)
+	}
+	utils.ActiveGitChanges = changes
+	return nil
 }
 
 var completionCmd = &cobra.Command{
@@ -311,6 +704,8 @@ var selectCmd = &cobra.Command{
 			configPath = args[0]
 		}
 
+		applyLanguageFilters()
+
 		opts := tui.BuildTreeOpts{
 			IncludeTests:    includeTests,
 			MaxDepth:        maxDepth,
@@ -325,6 +720,43 @@ var selectCmd = &cobra.Command{
 	},
 }
 
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the file cache",
+}
+
+var cacheCleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Remove the file cache",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, err := utils.CleanCache()
+		if err != nil {
+			utils.PrintError(fmt.Sprintf("Could not remove file cache '%s': %v", dir, err))
+			os.Exit(1)
+		}
+		fmt.Println("Removed file cache", dir)
+	},
+}
+
+// applyLanguageFilters validates --only-lang and --skip-lang and installs them.
+func applyLanguageFilters() {
+	var err error
+	utils.OnlyLanguages, err = utils.ParseLanguageFilter(onlyLangs)
+	if err != nil {
+		utils.PrintError(fmt.Sprintf("Invalid --only-lang: %v", err))
+		os.Exit(1)
+	}
+	utils.SkipLanguages, err = utils.ParseLanguageFilter(skipLangs)
+	if err != nil {
+		utils.PrintError(fmt.Sprintf("Invalid --skip-lang: %v", err))
+		os.Exit(1)
+	}
+	if len(onlyLangs) > 0 || len(skipLangs) > 0 {
+		utils.PrintDebug(fmt.Sprintf("Language filter: only=%v skip=%v", onlyLangs, skipLangs), debugMode)
+	}
+}
+
 func joinSet(m map[string]struct{}) string {
 	keys := make([]string, 0, len(m))
 	for k := range m {
@@ -1,7 +1,6 @@
 package tui
 
 import (
-	"os"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -57,51 +56,40 @@ func BuildTree(rootPath string, opts BuildTreeOpts) (*TreeNode, error) {
 		Depth:    0,
 	}
 
-	buildChildren(root, absRoot, absRoot, excludeNames, gi, opts, 1)
-	return root, nil
-}
-
-func buildChildren(parent *TreeNode, dirPath, rootPath string, excludeNames map[string]struct{}, gi *utils.GitIgnoreMatcher, opts BuildTreeOpts, depth int) {
-	if opts.MaxDepth > 0 && depth > opts.MaxDepth {
-		return
-	}
-
-	entries, err := os.ReadDir(dirPath)
+	children, err := utils.ListProjectTree(absRoot, opts.MaxDepth, excludeNames, gi)
 	if err != nil {
-		return
+		return nil, err
 	}
+	buildChildren(root, absRoot, absRoot, children, 1)
+	return root, nil
+}
 
+func buildChildren(parent *TreeNode, dirPath, rootPath string, children map[string][]utils.ProjectEntry, depth int) {
+	entries := append([]utils.ProjectEntry(nil), children[dirPath]...)
 	sort.Slice(entries, func(i, j int) bool {
 		// directories first, then alphabetical
-		if entries[i].IsDir() != entries[j].IsDir() {
-			return entries[i].IsDir()
+		if entries[i].IsDir != entries[j].IsDir {
+			return entries[i].IsDir
 		}
-		return strings.ToLower(entries[i].Name()) < strings.ToLower(entries[j].Name())
+		return strings.ToLower(entries[i].Name) < strings.ToLower(entries[j].Name)
 	})
 
 	for _, entry := range entries {
-		name := entry.Name()
-		fullPath := filepath.Join(dirPath, name)
-
-		if utils.ShouldSkipEntry(fullPath, name, entry.IsDir(), nil, nil, excludeNames, nil, gi) {
-			continue
-		}
-
-		relPath, _ := filepath.Rel(rootPath, fullPath)
+		relPath, _ := filepath.Rel(rootPath, entry.Path)
 		relPath = filepath.ToSlash(relPath)
 
 		node := &TreeNode{
-			Name:   name,
+			Name:   entry.Name,
 			Path:   relPath,
-			IsDir:  entry.IsDir(),
+			IsDir:  entry.IsDir,
 			State:  Unchecked,
 			Parent: parent,
 			Depth:  depth,
 		}
 
-		if entry.IsDir() {
+		if entry.IsDir {
 			node.Expanded = false
-			buildChildren(node, fullPath, rootPath, excludeNames, gi, opts, depth+1)
+			buildChildren(node, entry.Path, rootPath, children, depth+1)
 		}
 
 		parent.Children = append(parent.Children, node)
@@ -196,6 +184,7 @@ func setInitialStateRecursive(node *TreeNode, includePatterns, excludePatterns [
 		return
 	}
 
+	lang := utils.GetLanguageByExtension(node.Name)
 	if len(includePatterns) > 0 {
 		node.State = Unchecked
 		for _, pattern := range includePatterns {
@@ -205,7 +194,6 @@ func setInitialStateRecursive(node *TreeNode, includePatterns, excludePatterns [
 			}
 		}
 	} else {
-		lang := utils.GetLanguageByExtension(node.Name)
 		if lang != "" && !utils.IsTestFile(node.Path, false) && !utils.IsAssetFile(node.Path, false) {
 			node.State = Checked
 		} else {
@@ -213,6 +201,11 @@ func setInitialStateRecursive(node *TreeNode, includePatterns, excludePatterns [
 		}
 	}
 
+	// --only-lang/--skip-lang narrow the selection like they narrow collection.
+	if node.State == Checked && !utils.IsLanguageAllowed(lang) {
+		node.State = Unchecked
+	}
+
 	if node.State == Checked && len(excludePatterns) > 0 {
 		for _, pattern := range excludePatterns {
 			if matchGlob(pattern, node.Path) {
@@ -1,11 +1,13 @@
 package tui
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"slices"
 	"testing"
 
+	"github.com/luckpoint/list-codes/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -402,3 +404,44 @@ func TestMatchGlobDoubleStar(t *testing.T) {
 		})
 	}
 }
+
+func TestSetInitialState_LanguageFilter(t *testing.T) {
+	origOnly := utils.OnlyLanguages
+	t.Cleanup(func() { utils.OnlyLanguages = origOnly })
+	var err error
+	utils.OnlyLanguages, err = utils.ParseLanguageFilter([]string{"Markdown"})
+	require.NoError(t, err)
+
+	dir := createTestProject(t)
+	root, err := BuildTree(dir, BuildTreeOpts{})
+	require.NoError(t, err)
+
+	SetInitialState(root, nil, nil)
+	assert.Equal(t, Checked, findTreeNode(root, "README.md").State)
+	assert.Equal(t, Unchecked, findTreeNode(root, "src/main.go").State)
+
+	// Include patterns are narrowed by the language filter as well.
+	SetInitialState(root, []string{"**/*"}, nil)
+	assert.Equal(t, Checked, findTreeNode(root, "README.md").State)
+	assert.Equal(t, Unchecked, findTreeNode(root, "cmd/root.go").State)
+}
+
+func BenchmarkBuildTree(b *testing.B) {
+	dir := b.TempDir()
+	for p := 0; p < 40; p++ {
+		for d := 0; d < 5; d++ {
+			sub := filepath.Join(dir, fmt.Sprintf("pkg%02d", p), fmt.Sprintf("dir%d", d))
+			require.NoError(b, os.MkdirAll(sub, 0755))
+			for f := 0; f < 20; f++ {
+				require.NoError(b, os.WriteFile(filepath.Join(sub, fmt.Sprintf("file%02d.go", f)), []byte("package dir\n"), 0644))
+			}
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := BuildTree(dir, BuildTreeOpts{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
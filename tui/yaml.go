@@ -7,15 +7,36 @@ import (
 )
 
 type Config struct {
-	Include []string       `yaml:"include,omitempty"`
-	Exclude []string       `yaml:"exclude,omitempty"`
-	Options *ConfigOptions `yaml:"options,omitempty"`
+	Include  []string          `yaml:"include,omitempty"`
+	Exclude  []string          `yaml:"exclude,omitempty"`
+	Options  *ConfigOptions    `yaml:"options,omitempty"`
+	Redact   []RedactPattern   `yaml:"redact,omitempty"`
+	Priority []PriorityPattern `yaml:"priority,omitempty"`
+}
+
+// RedactPattern is a custom secret regex; matches are replaced with
+// [REDACTED:<name>].
+type RedactPattern struct {
+	Name    string `yaml:"name"`
+	Pattern string `yaml:"pattern"`
+}
+
+// PriorityPattern is a glob whose files gain Weight points when a budget
+// forces a choice; a zero Weight uses the default and a negative one demotes.
+type PriorityPattern struct {
+	Pattern string `yaml:"pattern"`
+	Weight  int    `yaml:"weight,omitempty"`
 }
 
 type ConfigOptions struct {
-	IncludeTests bool   `yaml:"include-tests,omitempty"`
-	MaxFileSize  string `yaml:"max-file-size,omitempty"`
-	MaxDepth     int    `yaml:"max-depth,omitempty"`
+	IncludeTests bool     `yaml:"include-tests,omitempty"`
+	MaxFileSize  string   `yaml:"max-file-size,omitempty"`
+	MaxDepth     int      `yaml:"max-depth,omitempty"`
+	OnlyLang     []string `yaml:"only-lang,omitempty"`
+	SkipLang     []string `yaml:"skip-lang,omitempty"`
+	Strip        []string `yaml:"strip,omitempty"`
+	Outline      bool     `yaml:"outline,omitempty"`
+	OutlineLang  []string `yaml:"outline-lang,omitempty"`
 }
 
 func LoadConfig(path string) (*Config, error) {
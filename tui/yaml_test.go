@@ -17,7 +17,14 @@ func TestLoadSaveConfig_Roundtrip(t *testing.T) {
 			IncludeTests: false,
 			MaxFileSize:  "1m",
 			MaxDepth:     7,
+			OnlyLang:     []string{"Go", "SQL"},
+			SkipLang:     []string{"HTML"},
+			Strip:        []string{"comments", "blank"},
+			Outline:      true,
+			OutlineLang:  []string{"Python"},
 		},
+		Redact:   []RedactPattern{{Name: "internal-token", Pattern: `itk_[a-z0-9]{32}`}},
+		Priority: []PriorityPattern{{Pattern: "internal/core/**"}, {Pattern: "docs/**", Weight: -50}},
 	}
 
 	tmpDir := t.TempDir()
@@ -34,6 +41,13 @@ func TestLoadSaveConfig_Roundtrip(t *testing.T) {
 	assert.Equal(t, cfg.Options.IncludeTests, loaded.Options.IncludeTests)
 	assert.Equal(t, cfg.Options.MaxFileSize, loaded.Options.MaxFileSize)
 	assert.Equal(t, cfg.Options.MaxDepth, loaded.Options.MaxDepth)
+	assert.Equal(t, cfg.Options.OnlyLang, loaded.Options.OnlyLang)
+	assert.Equal(t, cfg.Options.SkipLang, loaded.Options.SkipLang)
+	assert.Equal(t, cfg.Options.Strip, loaded.Options.Strip)
+	assert.Equal(t, cfg.Options.Outline, loaded.Options.Outline)
+	assert.Equal(t, cfg.Options.OutlineLang, loaded.Options.OutlineLang)
+	assert.Equal(t, cfg.Redact, loaded.Redact)
+	assert.Equal(t, cfg.Priority, loaded.Priority)
 }
 
 func TestLoadConfig_NotFound(t *testing.T) {
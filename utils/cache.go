@@ -0,0 +1,401 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheEnabled lets WriteProjectSummary reuse the files prepared by earlier
+// runs. The CLI turns it off with --no-cache.
+var CacheEnabled = false
+
+// fileCacheVersion is part of every cache key; bump it when the way files
+// are prepared changes, so that older entries are not reused.
+const fileCacheVersion = 2
+
+// fileCacheMaxAge is how long the cache of a project and settings is kept
+// when no run uses it.
+const fileCacheMaxAge = 30 * 24 * time.Hour
+
+// activeFileCache serves prepareSourceFile during WriteProjectSummary; nil
+// disables caching.
+var activeFileCache *fileCache
+
+// CacheDir returns the directory of the file cache: $XDG_CACHE_HOME/list-codes
+// when XDG_CACHE_HOME is set, otherwise list-codes in the user cache
+// directory of the platform.
+func CacheDir() (string, error) {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "list-codes"), nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "list-codes"), nil
+}
+
+// CleanCache removes the file cache and returns the removed directory.
+func CleanCache() (string, error) {
+	dir, err := CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return dir, os.RemoveAll(dir)
+}
+
+// fileCacheEntry is what prepareSourceFile learned about one file.
+type fileCacheEntry struct {
+	Size     int64  `json:"size"`
+	ModTime  int64  `json:"mod_time"`
+	Hash     string `json:"hash"`
+	Language string `json:"language"`
+	Binary   bool   `json:"binary,omitempty"`
+	Tokens   int    `json:"tokens"`
+	Outline  bool   `json:"outline,omitempty"`
+	// Text names the content file that holds the prepared text when it
+	// differs from the file, after transcoding, redaction, outlining or
+	// stripping. Such files are not read again while their size and
+	// modification time are unchanged.
+	Text           string `json:"text,omitempty"`
+	StrippedBytes  int    `json:"stripped_bytes,omitempty"`
+	StrippedTokens int    `json:"stripped_tokens,omitempty"`
+
+	// content is the prepared text named by Text, read by lookup and
+	// written by store.
+	content *string
+}
+
+// fileClass is what the start of a file says about it: the language named
+// by a shebang, a modeline or a content heuristic, and whether it holds
+// binary data.
+type fileClass struct {
+	Size     int64  `json:"size"`
+	ModTime  int64  `json:"mod_time"`
+	Language string `json:"language,omitempty"`
+	Binary   bool   `json:"binary,omitempty"`
+}
+
+// fileCacheDocument is the on-disk form of a fileCache. The prepared texts
+// are kept apart, one content file per text, so that loading the cache
+// does not load every file.
+type fileCacheDocument struct {
+	Root    string                    `json:"root"`
+	Entries map[string]fileCacheEntry `json:"entries"`
+	Classes map[string]fileClass      `json:"classes,omitempty"`
+}
+
+// fileCache holds the entries of one project for one combination of the
+// settings that change prepared files, keyed by display path. The cache is
+// stored in path and its prepared texts in contentDir. It is safe for the
+// prepareSourceFilesInOrder workers; the methods of a nil cache do nothing.
+type fileCache struct {
+	path       string
+	contentDir string
+	root       string
+
+	mu      sync.Mutex
+	entries map[string]fileCacheEntry
+	classes map[string]fileClass
+	seen    map[string]struct{}
+	changed bool
+	hits    int
+	misses  int
+}
+
+// fileCacheSettings describes the settings that change prepared files.
+func fileCacheSettings() string {
+	outlineLanguages := make([]string, 0, len(OutlineLanguages))
+	for lang := range OutlineLanguages {
+		outlineLanguages = append(outlineLanguages, lang)
+	}
+	sort.Strings(outlineLanguages)
+
+	tokenizerName := TokenizerChars
+	if ActiveTokenizer != nil {
+		tokenizerName = ActiveTokenizer.Name()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "version=%d\ntokenizer=%s\noutline=%t %s\nstrip=%+v\nredact=%t\n",
+		fileCacheVersion, tokenizerName, OutlineMode, strings.Join(outlineLanguages, ","), ActiveStripOptions, RedactSecrets)
+	for _, rule := range customRedactionRules {
+		fmt.Fprintf(&b, "rule=%s %s\n", rule.Kind, rule.Pattern.String())
+	}
+	return b.String()
+}
+
+// openFileCache loads the cache of rootPath for the current settings. It
+// returns nil when CacheEnabled is off or the cache directory is unknown;
+// an unreadable cache file starts an empty cache.
+func openFileCache(rootPath string, debug bool) *fileCache {
+	if !CacheEnabled {
+		return nil
+	}
+	dir, err := CacheDir()
+	if err != nil {
+		PrintWarning(fmt.Sprintf("File cache disabled: %v", err), debug)
+		return nil
+	}
+	key := sha256.Sum256([]byte(rootPath + "\x00" + fileCacheSettings()))
+	name := hex.EncodeToString(key[:16])
+	cache := &fileCache{
+		path:       filepath.Join(dir, name+".json"),
+		contentDir: filepath.Join(dir, name),
+		root:       rootPath,
+		entries:    make(map[string]fileCacheEntry),
+		classes:    make(map[string]fileClass),
+		seen:       make(map[string]struct{}),
+	}
+
+	data, err := os.ReadFile(cache.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			PrintWarning(fmt.Sprintf("Could not read file cache '%s': %v", cache.path, err), debug)
+		}
+		return cache
+	}
+	var doc fileCacheDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		PrintWarning(fmt.Sprintf("Ignoring corrupt file cache '%s': %v", cache.path, err), debug)
+		return cache
+	}
+	if doc.Root == rootPath && doc.Entries != nil {
+		cache.entries = doc.Entries
+	}
+	if doc.Root == rootPath && doc.Classes != nil {
+		cache.classes = doc.Classes
+	}
+	PrintDebug(fmt.Sprintf("Loaded %d file cache entries from '%s'", len(cache.entries), cache.path), debug)
+	return cache
+}
+
+// lookup returns the entry of a file prepared as language, with its
+// prepared text read from the content file when it has one. Without a hash
+// the entry must match the size and modification time of info; with the
+// contentHash of the file, the entry must match it, and a file that was
+// only touched has its modification time updated.
+func (c *fileCache) lookup(displayPath, language string, info os.FileInfo, hash string) (fileCacheEntry, bool) {
+	if c == nil {
+		return fileCacheEntry{}, false
+	}
+	c.mu.Lock()
+	entry, ok := c.entries[displayPath]
+	c.mu.Unlock()
+	if !ok || entry.Language != language || entry.Size != info.Size() {
+		return fileCacheEntry{}, false
+	}
+	if hash == "" && entry.ModTime != info.ModTime().UnixNano() {
+		return fileCacheEntry{}, false
+	}
+	if hash != "" && entry.Hash != hash {
+		return fileCacheEntry{}, false
+	}
+	if entry.Text != "" {
+		// A content file that went missing makes the entry unusable.
+		data, err := os.ReadFile(filepath.Join(c.contentDir, entry.Text))
+		if err != nil {
+			return fileCacheEntry{}, false
+		}
+		text := string(data)
+		entry.content = &text
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry.ModTime != info.ModTime().UnixNano() {
+		entry.ModTime = info.ModTime().UnixNano()
+		stored := entry
+		stored.content = nil
+		c.entries[displayPath] = stored
+		c.changed = true
+	}
+	c.seen[displayPath] = struct{}{}
+	c.hits++
+	return entry, true
+}
+
+// store records the entry of a file that was prepared without the cache,
+// writing its prepared text, when set, to a content file named by its
+// hash. An entry whose text cannot be written is not stored.
+func (c *fileCache) store(displayPath string, entry fileCacheEntry, debug bool) {
+	if c == nil {
+		return
+	}
+	if entry.content != nil {
+		entry.Text = contentHash([]byte(*entry.content))
+		path := filepath.Join(c.contentDir, entry.Text)
+		// Equal texts share a content file.
+		if _, err := os.Stat(path); err != nil {
+			// The cache holds source code, so it is only readable by the user.
+			err = os.MkdirAll(c.contentDir, 0o700)
+			if err == nil {
+				err = writeFileAtomic(path, []byte(*entry.content), 0o600)
+			}
+			if err != nil {
+				PrintWarning(fmt.Sprintf("Could not write file cache content '%s': %v", path, err), debug)
+				c.mu.Lock()
+				c.misses++
+				c.mu.Unlock()
+				return
+			}
+		}
+		entry.content = nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[displayPath] = entry
+	c.seen[displayPath] = struct{}{}
+	c.changed = true
+	c.misses++
+}
+
+// class returns the class of a file whose size and modification time match
+// info.
+func (c *fileCache) class(displayPath string, info os.FileInfo) (fileClass, bool) {
+	if c == nil {
+		return fileClass{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	class, ok := c.classes[displayPath]
+	if !ok || class.Size != info.Size() || class.ModTime != info.ModTime().UnixNano() {
+		return fileClass{}, false
+	}
+	c.seen[displayPath] = struct{}{}
+	return class, true
+}
+
+// storeClass records the class of a file as of info.
+func (c *fileCache) storeClass(displayPath string, info os.FileInfo, class fileClass) {
+	if c == nil {
+		return
+	}
+	class.Size = info.Size()
+	class.ModTime = info.ModTime().UnixNano()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.classes[displayPath] = class
+	c.seen[displayPath] = struct{}{}
+	c.changed = true
+}
+
+// save writes the cache back when it changed and removes the content files
+// no entry names any more. Entries of files that were not seen in this run
+// are kept while the file exists, so a run with a narrower selection does
+// not evict them. Caches of other projects or settings that were not used
+// for fileCacheMaxAge are removed.
+func (c *fileCache) save(debug bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	PrintDebug(fmt.Sprintf("File cache: %d hit(s), %d miss(es)", c.hits, c.misses), debug)
+
+	exists := func(displayPath string) bool {
+		if _, ok := c.seen[displayPath]; ok {
+			return true
+		}
+		_, err := os.Lstat(filepath.Join(c.root, filepath.FromSlash(displayPath)))
+		return err == nil
+	}
+	for displayPath := range c.entries {
+		if !exists(displayPath) {
+			delete(c.entries, displayPath)
+			c.changed = true
+		}
+	}
+	for displayPath := range c.classes {
+		if !exists(displayPath) {
+			delete(c.classes, displayPath)
+			c.changed = true
+		}
+	}
+	defer pruneFileCaches(filepath.Dir(c.path), c.path, debug)
+	if !c.changed {
+		// A cache in use is not pruned as stale.
+		now := time.Now()
+		if err := os.Chtimes(c.path, now, now); err != nil && !os.IsNotExist(err) {
+			PrintWarning(fmt.Sprintf("Could not update file cache '%s': %v", c.path, err), debug)
+		}
+		return
+	}
+
+	// The cache holds source code, so it is only readable by the user.
+	data, err := json.Marshal(fileCacheDocument{Root: c.root, Entries: c.entries, Classes: c.classes})
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(c.path), 0o700)
+	}
+	if err == nil {
+		err = writeFileAtomic(c.path, data, 0o600)
+	}
+	if err != nil {
+		PrintWarning(fmt.Sprintf("Could not write file cache '%s': %v", c.path, err), debug)
+		return
+	}
+	c.removeUnusedContent(debug)
+}
+
+// removeUnusedContent removes the content files that no entry names.
+func (c *fileCache) removeUnusedContent(debug bool) {
+	files, err := os.ReadDir(c.contentDir)
+	if err != nil {
+		return
+	}
+	used := make(map[string]struct{}, len(c.entries))
+	for _, entry := range c.entries {
+		if entry.Text != "" {
+			used[entry.Text] = struct{}{}
+		}
+	}
+	for _, file := range files {
+		if _, ok := used[file.Name()]; ok {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.contentDir, file.Name())); err != nil {
+			PrintWarning(fmt.Sprintf("Could not remove file cache content '%s': %v", file.Name(), err), debug)
+		}
+	}
+}
+
+// pruneFileCaches removes the caches in dir other than keep, with their
+// content files, when they were not used for fileCacheMaxAge.
+func pruneFileCaches(dir, keep string, debug bool) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		path := filepath.Join(dir, file.Name())
+		if file.IsDir() || filepath.Ext(path) != ".json" || path == keep {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil || time.Since(info.ModTime()) < fileCacheMaxAge {
+			continue
+		}
+		PrintDebug(fmt.Sprintf("Removing stale file cache '%s'", path), debug)
+		if err := os.RemoveAll(strings.TrimSuffix(path, ".json")); err != nil {
+			PrintWarning(fmt.Sprintf("Could not remove stale file cache '%s': %v", path, err), debug)
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			PrintWarning(fmt.Sprintf("Could not remove stale file cache '%s': %v", path, err), debug)
+		}
+	}
+}
+
+// contentHash identifies the content of a file in the cache.
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
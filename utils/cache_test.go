@@ -0,0 +1,262 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withFileCache enables the file cache under a temporary XDG_CACHE_HOME.
+func withFileCache(t *testing.T) string {
+	t.Helper()
+	cacheHome := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cacheHome)
+	orig := CacheEnabled
+	t.Cleanup(func() { CacheEnabled = orig })
+	CacheEnabled = true
+	return filepath.Join(cacheHome, "list-codes")
+}
+
+// prepareWithCache prepares one file with a freshly loaded cache and saves
+// the cache afterwards, like one run of WriteProjectSummary.
+func prepareWithCache(t *testing.T, root, absPath, language string) (preparedSource, *fileCache) {
+	t.Helper()
+	info, err := os.Stat(absPath)
+	require.NoError(t, err)
+	activeFileCache = openFileCache(root, false)
+	t.Cleanup(func() { activeFileCache = nil })
+	cache := activeFileCache
+	prepared := prepareSourceFile(root, sourceCandidate{absPath: absPath, language: language, info: info}, false)
+	cache.save(false)
+	activeFileCache = nil
+	return prepared, cache
+}
+
+func TestCacheDir_UsesXDGCacheHome(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", "/tmp/xdg")
+	dir, err := CacheDir()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("/tmp/xdg", "list-codes"), dir)
+}
+
+func TestOpenFileCache_DisabledByDefault(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	assert.Nil(t, openFileCache(t.TempDir(), false))
+}
+
+func TestPrepareSourceFile_ReusesCachedPreparation(t *testing.T) {
+	withFileCache(t)
+	origStrip := ActiveStripOptions
+	t.Cleanup(func() { ActiveStripOptions = origStrip })
+	ActiveStripOptions = StripOptions{Blank: true}
+
+	root := t.TempDir()
+	path := filepath.Join(root, "main.go")
+	createTestFile(t, path, "package main\n\n\nfunc main() {}\n")
+
+	first, cache := prepareWithCache(t, root, path, "Go")
+	assert.Equal(t, 0, cache.hits)
+	assert.Equal(t, 1, cache.misses)
+	require.True(t, first.text)
+
+	second, cache := prepareWithCache(t, root, path, "Go")
+	assert.Equal(t, 1, cache.hits)
+	assert.Equal(t, 0, cache.misses)
+	assert.Equal(t, first.file, second.file)
+	assert.Equal(t, first.strippedBytes, second.strippedBytes)
+	assert.Equal(t, first.strippedTokens, second.strippedTokens)
+}
+
+func TestPrepareSourceFile_StrippedTextIsNotReadAgain(t *testing.T) {
+	withFileCache(t)
+	origStrip := ActiveStripOptions
+	t.Cleanup(func() { ActiveStripOptions = origStrip })
+	ActiveStripOptions = StripOptions{Blank: true}
+
+	root := t.TempDir()
+	path := filepath.Join(root, "main.go")
+	createTestFile(t, path, "package main\n\n\nfunc main() {}\n")
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	first, _ := prepareWithCache(t, root, path, "Go")
+
+	// With the file gone, only the cache can provide its text.
+	require.NoError(t, os.Remove(path))
+	activeFileCache = openFileCache(root, false)
+	t.Cleanup(func() { activeFileCache = nil })
+	second := prepareSourceFile(root, sourceCandidate{absPath: path, language: "Go", info: info}, false)
+	assert.True(t, second.text)
+	assert.Equal(t, first.file, second.file)
+}
+
+func TestPrepareSourceFile_CacheDetectsChanges(t *testing.T) {
+	withFileCache(t)
+	root := t.TempDir()
+	path := filepath.Join(root, "main.go")
+	createTestFile(t, path, "package main\n")
+	prepareWithCache(t, root, path, "Go")
+
+	t.Run("touched file is matched by content", func(t *testing.T) {
+		later := time.Now().Add(time.Hour)
+		require.NoError(t, os.Chtimes(path, later, later))
+		prepared, cache := prepareWithCache(t, root, path, "Go")
+		assert.Equal(t, 1, cache.hits)
+		assert.Equal(t, "package main\n", prepared.file.Content)
+	})
+
+	t.Run("edited file of the same size is prepared again", func(t *testing.T) {
+		createTestFile(t, path, "package mayn\n")
+		prepared, cache := prepareWithCache(t, root, path, "Go")
+		assert.Equal(t, 0, cache.hits)
+		assert.Equal(t, 1, cache.misses)
+		assert.Equal(t, "package mayn\n", prepared.file.Content)
+	})
+
+	t.Run("another language is prepared again", func(t *testing.T) {
+		_, cache := prepareWithCache(t, root, path, "text")
+		assert.Equal(t, 0, cache.hits)
+	})
+}
+
+func TestPrepareSourceFile_CachesBinaryFiles(t *testing.T) {
+	withFileCache(t)
+	root := t.TempDir()
+	path := filepath.Join(root, "blob.go")
+	createTestFile(t, path, "\x00\x01\x02binary")
+
+	first, _ := prepareWithCache(t, root, path, "Go")
+	assert.False(t, first.text)
+	second, cache := prepareWithCache(t, root, path, "Go")
+	assert.False(t, second.text)
+	assert.Equal(t, 1, cache.hits)
+}
+
+func TestOpenFileCache_KeyedBySettings(t *testing.T) {
+	withFileCache(t)
+	origOutline := OutlineMode
+	t.Cleanup(func() { OutlineMode = origOutline })
+
+	root := t.TempDir()
+	OutlineMode = false
+	full := openFileCache(root, false)
+	OutlineMode = true
+	outlined := openFileCache(root, false)
+	assert.NotEqual(t, full.path, outlined.path, "outlined files are cached apart from full files")
+	assert.NotEqual(t, full.path, openFileCache(t.TempDir(), false).path, "each project has its own cache")
+}
+
+func TestFileCacheSave_DropsDeletedFiles(t *testing.T) {
+	withFileCache(t)
+	root := t.TempDir()
+	keep := filepath.Join(root, "keep.go")
+	gone := filepath.Join(root, "gone.go")
+	createTestFile(t, keep, "package keep\n")
+	createTestFile(t, gone, "package gone\n")
+	prepareWithCache(t, root, keep, "Go")
+	_, cache := prepareWithCache(t, root, gone, "Go")
+	require.Len(t, cache.entries, 2)
+
+	require.NoError(t, os.Remove(gone))
+	cache = openFileCache(root, false)
+	cache.save(false)
+	assert.Contains(t, openFileCache(root, false).entries, "keep.go", "files outside this run stay cached")
+	assert.NotContains(t, openFileCache(root, false).entries, "gone.go")
+}
+
+func TestWriteProjectSummary_CachedRunMatchesUncached(t *testing.T) {
+	cacheDir := withFileCache(t)
+	tempDir := t.TempDir()
+	createTestFile(t, filepath.Join(tempDir, "main.go"), "package main\n\n// Config holds the settings.\ntype Config struct{}\n")
+	createTestFile(t, filepath.Join(tempDir, "app.py"), "API_TOKEN = 'q8Zr2Lk9Xw4Vn7Bt3Hy6'\n")
+
+	run := func() string {
+		var b strings.Builder
+		require.NoError(t, WriteProjectSummary(&b, "", tempDir, 5, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, false, nil))
+		return b.String()
+	}
+	first := run()
+	caches, err := filepath.Glob(filepath.Join(cacheDir, "*.json"))
+	require.NoError(t, err)
+	require.Len(t, caches, 1, "one cache per project and settings")
+	assert.Equal(t, first, run())
+	assert.NotContains(t, first, "q8Zr2Lk9Xw4Vn7Bt3Hy6")
+
+	var cached strings.Builder
+	require.NoError(t, filepath.WalkDir(cacheDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		cached.Write(data)
+		return err
+	}))
+	assert.Contains(t, cached.String(), "[REDACTED:token]", "the redacted text is cached")
+	assert.NotContains(t, cached.String(), "q8Zr2Lk9Xw4Vn7Bt3Hy6", "secrets are not written to the cache")
+}
+
+func TestFileCache_KeepsPreparedTextApart(t *testing.T) {
+	withFileCache(t)
+	origStrip := ActiveStripOptions
+	t.Cleanup(func() { ActiveStripOptions = origStrip })
+	ActiveStripOptions = StripOptions{Blank: true}
+
+	root := t.TempDir()
+	path := filepath.Join(root, "main.go")
+	createTestFile(t, path, "package main\n\n\nfunc first() {}\n")
+	_, cache := prepareWithCache(t, root, path, "Go")
+
+	data, err := os.ReadFile(cache.path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "func first", "the prepared text is not loaded with the cache")
+	entry := openFileCache(root, false).entries["main.go"]
+	require.NotEmpty(t, entry.Text)
+	assert.Nil(t, entry.content)
+	text, err := os.ReadFile(filepath.Join(cache.contentDir, entry.Text))
+	require.NoError(t, err)
+	assert.Equal(t, "package main\nfunc first() {}\n", string(text))
+
+	createTestFile(t, path, "package main\n\n\nfunc second() {}\n")
+	prepared, _ := prepareWithCache(t, root, path, "Go")
+	assert.Contains(t, prepared.file.Content, "func second")
+	files, err := os.ReadDir(cache.contentDir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1, "the text of the earlier version is removed")
+
+	// Without its content file, an entry is prepared again.
+	require.NoError(t, os.RemoveAll(cache.contentDir))
+	prepared, cache = prepareWithCache(t, root, path, "Go")
+	assert.Equal(t, 1, cache.misses)
+	assert.Contains(t, prepared.file.Content, "func second")
+}
+
+func TestFileCacheSave_PrunesStaleCaches(t *testing.T) {
+	cacheDir := withFileCache(t)
+	stale := filepath.Join(cacheDir, "stale.json")
+	recent := filepath.Join(cacheDir, "recent.json")
+	createTestFile(t, stale, "{}")
+	createTestFile(t, filepath.Join(cacheDir, "stale", "text"), "cached")
+	createTestFile(t, recent, "{}")
+	old := time.Now().Add(-fileCacheMaxAge - time.Hour)
+	require.NoError(t, os.Chtimes(stale, old, old))
+
+	root := t.TempDir()
+	path := filepath.Join(root, "main.go")
+	createTestFile(t, path, "package main\n")
+	_, cache := prepareWithCache(t, root, path, "Go")
+
+	assert.NoFileExists(t, stale)
+	assert.NoDirExists(t, filepath.Join(cacheDir, "stale"))
+	assert.FileExists(t, recent)
+
+	// Using a cache keeps it from being pruned.
+	require.NoError(t, os.Chtimes(cache.path, old, old))
+	openFileCache(root, false).save(false)
+	info, err := os.Stat(cache.path)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), info.ModTime(), time.Minute)
+}
@@ -56,7 +56,7 @@ var PROJECT_SIGNATURES = map[string][]string{
 	"CSS":          {".css", ".scss", ".sass"},
 	"Lua":          {".lua"},
 	"C":            {".c"},
-	"C++":          {".cpp", ".hpp", ".cxx", ".hxx", ".cc", ".hh", ".h"},
+	"C++":          {".cpp", ".hpp", ".cxx", ".hxx", ".cc", ".hh"},
 	"Java":         {"build.gradle", "settings.gradle", "pom.xml"},
 	"PHP":          {"composer.json"},
 	"C#":           {".csproj", ".sln", "project.json"},
@@ -84,9 +84,11 @@ var PROJECT_SIGNATURES = map[string][]string{
 }
 
 // EXTENSIONS maps file extensions to programming languages for detection.
+// Extensions listed under several languages (.h, .m, .pl) are resolved by
+// DetectLanguage; see ambiguousExtensionPreference for their default.
 var EXTENSIONS = map[string][]string{
 	"Python":     {".py", ".pyw"},
-	"Ruby":       {".rb", ".rbw"},
+	"Ruby":       {".rb", ".rbw", "Rakefile", "Gemfile", "Vagrantfile", "Podfile", "Brewfile", "Fastfile"},
 	"Javascript": {".js", ".jsx", ".mjs", ".cjs"},
 	"Typescript": {".ts", ".tsx", ".mts", ".cts"},
 	"Go":         {".go"},
@@ -118,7 +120,7 @@ var EXTENSIONS = map[string][]string{
 	"CSS":          {".css", ".scss", ".sass", ".less", ".styl"},
 	"Lua":          {".lua"},
 	"C":            {".c", ".h"},
-	"C++":          {".cpp", ".hpp", ".cxx", ".hxx", ".cc", ".hh", ".c++", ".h++"},
+	"C++":          {".cpp", ".hpp", ".cxx", ".hxx", ".cc", ".hh", ".c++", ".h++", ".h"},
 	"Java":         {".java"},
 	"PHP":          {".php", ".phtml", ".php3", ".php4", ".php5"},
 	"C#":           {".cs", ".csx"},
@@ -132,7 +134,7 @@ var EXTENSIONS = map[string][]string{
 	"Dart":         {".dart"},
 	"R":            {".r", ".R"},
 	"Perl":         {".pl", ".pm", ".perl"},
-	"Objective-C":  {".m", ".mm"},
+	"Objective-C":  {".m", ".mm", ".h"},
 	"Pascal":       {".pas", ".pp"},
 	"Fortran":      {".f", ".f90", ".f95", ".f03", ".f08"},
 	"COBOL":        {".cob", ".cbl"},
@@ -147,7 +149,7 @@ var EXTENSIONS = map[string][]string{
 	"D":            {".d"},
 	"Nim":          {".nim"},
 	"Crystal":      {".cr"},
-	"Groovy":       {".groovy", ".gvy"},
+	"Groovy":       {".groovy", ".gvy", "Jenkinsfile"},
 	"PowerShell":   {".ps1", ".psm1", ".psd1"},
 	"Batch":        {".bat", ".cmd"},
 	"Bash":         {".bash", ".bats"},
@@ -171,7 +173,9 @@ var EXTENSIONS = map[string][]string{
 	"Gradle":       {".gradle", ".gradle.kts"},
 	"INI":          {".ini", ".cfg"},
 	"Properties":   {".properties"},
-	"Makefile":     {"Makefile", "makefile", ".mk"},
+	"Makefile":     {"Makefile", "makefile", "GNUmakefile", ".mk"},
+	"Just":         {"Justfile", ".just"},
+	"Procfile":     {"Procfile"},
 	"CMake":        {".cmake", "CMakeLists.txt"},
 	"Terraform":    {".tf", ".tfvars"},
 	"HCL":          {".hcl"},
@@ -187,6 +191,315 @@ var EXTENSIONS = map[string][]string{
 	"Assembly":     {".asm", ".s"},
 }
 
+// CommentSyntax describes how a language writes comments and strings, so that
+// --strip can drop comments without touching string literals.
+type CommentSyntax struct {
+	// Line lists line comment markers such as "//" and "#".
+	Line []string
+	// Block lists block comment open and close markers such as "/*" and "*/".
+	Block [][2]string
+	// Strings are single-line string delimiters with backslash escapes.
+	Strings []string
+	// MultilineStrings may span lines; backslash escapes apply.
+	MultilineStrings []string
+	// RawStrings may span lines and have no escapes, like Go backquotes.
+	RawStrings []string
+	// Docstrings are MultilineStrings that form a docstring when they start
+	// a line, as in Python.
+	Docstrings []string
+	// DocComments are comment prefixes such as "/**" and "///" that mark
+	// documentation comments.
+	DocComments []string
+	// Directives are comment prefixes such as "//go:build" that carry
+	// meaning for the toolchain and are never stripped.
+	Directives []string
+	// Regexps enables JavaScript-style /regular expression/ literals.
+	Regexps bool
+}
+
+var (
+	cStyleComments = CommentSyntax{
+		Line:        []string{"//"},
+		Block:       [][2]string{{"/*", "*/"}},
+		Strings:     []string{`"`, `'`},
+		DocComments: []string{"/**", "///"},
+	}
+	hashComments = CommentSyntax{
+		Line:    []string{"#"},
+		Strings: []string{`"`, `'`},
+	}
+	markupComments = CommentSyntax{
+		Block: [][2]string{{"<!--", "-->"}},
+	}
+	lispComments = CommentSyntax{
+		Line:    []string{";"},
+		Strings: []string{`"`},
+	}
+)
+
+// COMMENT_SYNTAX maps the languages of EXTENSIONS to their comment syntax.
+// Languages without an entry, such as Markdown and JSON, are never stripped
+// of comments.
+var COMMENT_SYNTAX = map[string]CommentSyntax{
+	"Go": {
+		Line:       []string{"//"},
+		Block:      [][2]string{{"/*", "*/"}},
+		Strings:    []string{`"`, `'`},
+		RawStrings: []string{"`"},
+		Directives: []string{"//go:", "// +build", "//line ", "//export ", "//nolint"},
+	},
+	"C":            cStyleComments,
+	"C++":          cStyleComments,
+	"Objective-C":  cStyleComments,
+	"Java":         cStyleComments,
+	"C#":           cStyleComments,
+	"Solidity":     cStyleComments,
+	"Protobuf/Buf": cStyleComments,
+	"Gradle":       cStyleComments,
+	"D":            cStyleComments,
+	"Zig": {
+		Line:        []string{"//"},
+		Strings:     []string{`"`, `'`},
+		DocComments: []string{"///", "//!"},
+	},
+	"Rust": {
+		Line:        []string{"//"},
+		Block:       [][2]string{{"/*", "*/"}},
+		Strings:     []string{`"`},
+		DocComments: []string{"///", "//!", "/**", "/*!"},
+	},
+	"Javascript": {
+		Line:             []string{"//"},
+		Block:            [][2]string{{"/*", "*/"}},
+		Strings:          []string{`"`, `'`},
+		MultilineStrings: []string{"`"},
+		Regexps:          true,
+		DocComments:      []string{"/**"},
+	},
+	"Typescript": {
+		Line:             []string{"//"},
+		Block:            [][2]string{{"/*", "*/"}},
+		Strings:          []string{`"`, `'`},
+		MultilineStrings: []string{"`"},
+		Regexps:          true,
+		DocComments:      []string{"/**", "///"},
+	},
+	"Kotlin": {
+		Line:             []string{"//"},
+		Block:            [][2]string{{"/*", "*/"}},
+		Strings:          []string{`"`, `'`},
+		MultilineStrings: []string{`"""`},
+		DocComments:      []string{"/**"},
+	},
+	"Swift": {
+		Line:             []string{"//"},
+		Block:            [][2]string{{"/*", "*/"}},
+		Strings:          []string{`"`},
+		MultilineStrings: []string{`"""`},
+		DocComments:      []string{"/**", "///"},
+	},
+	"Scala": {
+		Line:             []string{"//"},
+		Block:            [][2]string{{"/*", "*/"}},
+		Strings:          []string{`"`, `'`},
+		MultilineStrings: []string{`"""`},
+		DocComments:      []string{"/**"},
+	},
+	"Dart": {
+		Line:             []string{"//"},
+		Block:            [][2]string{{"/*", "*/"}},
+		Strings:          []string{`"`, `'`},
+		MultilineStrings: []string{`"""`, `'''`},
+		DocComments:      []string{"/**", "///"},
+	},
+	"Groovy": {
+		Line:             []string{"//"},
+		Block:            [][2]string{{"/*", "*/"}},
+		Strings:          []string{`"`, `'`},
+		MultilineStrings: []string{`"""`, `'''`},
+		DocComments:      []string{"/**"},
+	},
+	"PHP": {
+		Line:        []string{"//", "#"},
+		Block:       [][2]string{{"/*", "*/"}},
+		Strings:     []string{`"`, `'`},
+		DocComments: []string{"/**"},
+	},
+	"CSS": {
+		Block:   [][2]string{{"/*", "*/"}},
+		Strings: []string{`"`, `'`},
+	},
+	"Python": {
+		Line:             []string{"#"},
+		Strings:          []string{`"`, `'`},
+		MultilineStrings: []string{`"""`, `'''`},
+		Docstrings:       []string{`"""`, `'''`},
+	},
+	"Elixir": {
+		Line:             []string{"#"},
+		Strings:          []string{`"`, `'`},
+		MultilineStrings: []string{`"""`},
+	},
+	"Julia": {
+		Line:             []string{"#"},
+		Block:            [][2]string{{"#=", "=#"}},
+		Strings:          []string{`"`},
+		MultilineStrings: []string{`"""`},
+	},
+	"PowerShell": {
+		Line:    []string{"#"},
+		Block:   [][2]string{{"<#", "#>"}},
+		Strings: []string{`"`, `'`},
+	},
+	"Terraform": {
+		Line:    []string{"#", "//"},
+		Block:   [][2]string{{"/*", "*/"}},
+		Strings: []string{`"`},
+	},
+	"HCL": {
+		Line:    []string{"#", "//"},
+		Block:   [][2]string{{"/*", "*/"}},
+		Strings: []string{`"`},
+	},
+	"Ruby":       hashComments,
+	"Perl":       hashComments,
+	"Shell":      hashComments,
+	"Bash":       hashComments,
+	"Zsh":        hashComments,
+	"Fish":       hashComments,
+	"R":          hashComments,
+	"Nim":        hashComments,
+	"Crystal":    hashComments,
+	"Tcl":        hashComments,
+	"YAML":       hashComments,
+	"TOML":       hashComments,
+	"Makefile":   hashComments,
+	"Dockerfile": hashComments,
+	"CMake":      hashComments,
+	"GraphQL":    hashComments,
+	"Just":       hashComments,
+	"Procfile":   hashComments,
+	"INI": {
+		Line:    []string{";", "#"},
+		Strings: []string{`"`},
+	},
+	"Properties": {
+		Line: []string{"#", "!"},
+	},
+	"SQL": {
+		Line:    []string{"--"},
+		Block:   [][2]string{{"/*", "*/"}},
+		Strings: []string{`'`, `"`},
+	},
+	"Lua": {
+		Line:    []string{"--"},
+		Block:   [][2]string{{"--[[", "]]"}},
+		Strings: []string{`"`, `'`},
+	},
+	"Haskell": {
+		Line:    []string{"--"},
+		Block:   [][2]string{{"{-", "-}"}},
+		Strings: []string{`"`},
+	},
+	"Ada": {
+		Line:    []string{"--"},
+		Strings: []string{`"`},
+	},
+	"Pascal": {
+		Line:    []string{"//"},
+		Block:   [][2]string{{"{", "}"}, {"(*", "*)"}},
+		Strings: []string{`'`},
+	},
+	"F#": {
+		Line:        []string{"//"},
+		Block:       [][2]string{{"(*", "*)"}},
+		Strings:     []string{`"`},
+		DocComments: []string{"///"},
+	},
+	"VB.NET": {
+		Line:    []string{"'"},
+		Strings: []string{`"`},
+	},
+	"Fortran": {
+		Line:    []string{"!"},
+		Strings: []string{`"`, `'`},
+	},
+	"Assembly": {
+		Line:    []string{";"},
+		Strings: []string{`"`},
+	},
+	"Lisp":       lispComments,
+	"Scheme":     lispComments,
+	"Clojure":    lispComments,
+	"Emacs Lisp": lispComments,
+	"Erlang": {
+		Line:    []string{"%"},
+		Strings: []string{`"`},
+	},
+	"MATLAB": {
+		Line:    []string{"%"},
+		Block:   [][2]string{{"%{", "%}"}},
+		Strings: []string{`"`, `'`},
+	},
+	"Octave": {
+		Line:    []string{"%", "#"},
+		Block:   [][2]string{{"%{", "%}"}},
+		Strings: []string{`"`, `'`},
+	},
+	"Prolog": {
+		Line:    []string{"%"},
+		Block:   [][2]string{{"/*", "*/"}},
+		Strings: []string{`"`, `'`},
+	},
+	"HTML":   markupComments,
+	"XML":    markupComments,
+	"Vue":    markupComments,
+	"Svelte": markupComments,
+}
+
+// INTERPRETERS maps shebang interpreters to languages for files whose name
+// has no known extension, such as bin/deploy. Keys are lower case, and
+// versions are stripped before lookup, so "python3.11" matches "python".
+var INTERPRETERS = map[string]string{
+	"python":     "Python",
+	"pypy":       "Python",
+	"ruby":       "Ruby",
+	"jruby":      "Ruby",
+	"node":       "Javascript",
+	"nodejs":     "Javascript",
+	"bun":        "Javascript",
+	"deno":       "Typescript",
+	"ts-node":    "Typescript",
+	"tsx":        "Typescript",
+	"perl":       "Perl",
+	"php":        "PHP",
+	"lua":        "Lua",
+	"luajit":     "Lua",
+	"rscript":    "R",
+	"julia":      "Julia",
+	"swift":      "Swift",
+	"scala":      "Scala",
+	"kotlin":     "Kotlin",
+	"groovy":     "Groovy",
+	"elixir":     "Elixir",
+	"escript":    "Erlang",
+	"runghc":     "Haskell",
+	"runhaskell": "Haskell",
+	"crystal":    "Crystal",
+	"tclsh":      "Tcl",
+	"wish":       "Tcl",
+	"pwsh":       "PowerShell",
+	"bash":       "Bash",
+	"zsh":        "Zsh",
+	"fish":       "Fish",
+	"sh":         "Shell",
+	"dash":       "Shell",
+	"ksh":        "Shell",
+	"ash":        "Shell",
+	"make":       "Makefile",
+	"just":       "Just",
+}
+
 // EXCLUDE_TEST_KEYWORDS contains keywords for identifying test files.
 // The IsTestFile function performs a case-insensitive check against the base filename.
 var EXCLUDE_TEST_KEYWORDS = []string{
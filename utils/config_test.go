@@ -89,7 +89,7 @@ func TestExtensions(t *testing.T) {
 		{"Typescript", []string{".ts", ".tsx", ".mts", ".cts"}},
 		{"Rust", []string{".rs"}},
 		{"Java", []string{".java"}},
-		{"C++", []string{".cpp", ".hpp", ".cxx", ".hxx", ".cc", ".hh", ".c++", ".h++"}},
+		{"C++", []string{".cpp", ".hpp", ".cxx", ".hxx", ".cc", ".hh", ".c++", ".h++", ".h"}},
 		{"Batch", []string{".bat", ".cmd"}},
 		{"Dockerfile", []string{"Dockerfile"}},
 		{"Markdown", []string{".md"}},
@@ -121,6 +121,16 @@ func TestExtensions(t *testing.T) {
 	}
 }
 
+func TestCommentSyntaxLanguages(t *testing.T) {
+	for language, syntax := range COMMENT_SYNTAX {
+		if _, ok := EXTENSIONS[language]; !ok {
+			t.Errorf("COMMENT_SYNTAX language %q is not in EXTENSIONS", language)
+		}
+		if len(syntax.Line) == 0 && len(syntax.Block) == 0 {
+			t.Errorf("COMMENT_SYNTAX language %q has no comment markers", language)
+		}
+	}
+}
 
 // TestExcludeTestKeywords はEXCLUDE_TEST_KEYWORDSのテストです。
 func TestExcludeTestKeywords(t *testing.T) {
@@ -0,0 +1,288 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	textunicode "golang.org/x/text/encoding/unicode"
+)
+
+// contentSniffBytes is how much of a file is inspected to tell text from
+// binary data.
+const contentSniffBytes = 8192
+
+// maxControlRatio is the share of control characters above which decoded
+// text is treated as binary data.
+const maxControlRatio = 0.1
+
+// binaryMIMEPrefixes are http.DetectContentType results that identify binary
+// formats by their signature, whatever the file extension says.
+var binaryMIMEPrefixes = []string{
+	"image/",
+	"audio/",
+	"video/",
+	"font/",
+	"application/pdf",
+	"application/zip",
+	"application/x-gzip",
+	"application/x-rar-compressed",
+	"application/wasm",
+	"application/ogg",
+	"application/vnd.ms-fontobject",
+}
+
+// legacyEncoding is a non-UTF-8 text encoding tried when a file is not valid
+// UTF-8. accept rejects decodings that are valid but implausible, such as
+// Latin-1 text read as Shift_JIS.
+type legacyEncoding struct {
+	name     string
+	encoding encoding.Encoding
+	accept   func(text string) bool
+}
+
+// legacyEncodings are tried in order. Windows-1252 decodes almost any bytes,
+// so it comes last.
+var legacyEncodings = []legacyEncoding{
+	{name: "Shift_JIS", encoding: japanese.ShiftJIS, accept: containsScript(unicode.Hiragana, unicode.Katakana)},
+	{name: "EUC-JP", encoding: japanese.EUCJP, accept: containsScript(unicode.Hiragana, unicode.Katakana)},
+	{name: "EUC-KR", encoding: korean.EUCKR, accept: containsScript(unicode.Hangul)},
+	{name: "Windows-1252", encoding: charmap.Windows1252, accept: func(string) bool { return true }},
+}
+
+// decodeFileText decodes a file read for the output with decodeTextContent
+// and reports skipped binaries and transcoded files in debug output.
+func decodeFileText(displayPath string, content []byte, debug bool) (string, bool) {
+	text, encodingName, isText := decodeTextContent(content)
+	if !isText {
+		PrintDebug(fmt.Sprintf("Skipping binary file '%s'", displayPath), debug)
+		return "", false
+	}
+	if encodingName != "UTF-8" {
+		PrintDebug(fmt.Sprintf("Transcoded '%s' from %s to UTF-8", displayPath, encodingName), debug)
+	}
+	return text, true
+}
+
+// fileHead classifies a file by its start, read on first use and at most
+// once, so that language detection and the binary check share one read.
+// With a cache, the class of a file whose size and modification time are
+// unchanged is taken from it instead.
+type fileHead struct {
+	path        string
+	cache       *fileCache
+	displayPath string
+
+	classified bool
+	class      fileClass
+}
+
+func newFileHead(path string) *fileHead {
+	return &fileHead{path: path}
+}
+
+func (h *fileHead) classify() {
+	if h.classified {
+		return
+	}
+	h.classified = true
+
+	var info os.FileInfo
+	if h.cache != nil {
+		var err error
+		if info, err = os.Stat(h.path); err == nil {
+			if class, ok := h.cache.class(h.displayPath, info); ok {
+				h.class = class
+				return
+			}
+		}
+	}
+	head, err := readFileHead(h.path, contentSniffBytes)
+	if err != nil {
+		return
+	}
+	languageHead := head
+	if len(languageHead) > languageSniffBytes {
+		languageHead = languageHead[:languageSniffBytes]
+	}
+	h.class.Language = headLanguage(filepath.Base(h.path), languageHead)
+	_, _, isText := decodeTextContent(head)
+	h.class.Binary = !isText
+	if info != nil {
+		h.cache.storeClass(h.displayPath, info, h.class)
+	}
+}
+
+// language returns the language the start of the file names; see
+// headLanguage.
+func (h *fileHead) language() string {
+	h.classify()
+	return h.class.Language
+}
+
+// binary reports whether decodeTextContent takes the first
+// contentSniffBytes of the file for binary data. Unreadable files are not
+// reported.
+func (h *fileHead) binary() bool {
+	h.classify()
+	return h.class.Binary
+}
+
+// decodeTextContent sniffs content and returns it as UTF-8 text with the name
+// of the encoding it was decoded from. ok is false for binary data: NUL bytes
+// outside UTF-16, a binary file signature, or bytes that decode to neither
+// UTF-8 nor a known legacy encoding.
+func decodeTextContent(content []byte) (text string, encodingName string, ok bool) {
+	switch {
+	case bytes.HasPrefix(content, []byte{0xEF, 0xBB, 0xBF}):
+		return strings.ToValidUTF8(string(content[3:]), "\uFFFD"), "UTF-8", true
+	case bytes.HasPrefix(content, []byte{0xFF, 0xFE}):
+		return decodeUTF16(content, textunicode.LittleEndian, "UTF-16LE")
+	case bytes.HasPrefix(content, []byte{0xFE, 0xFF}):
+		return decodeUTF16(content, textunicode.BigEndian, "UTF-16BE")
+	}
+
+	head := content
+	if len(head) > contentSniffBytes {
+		head = head[:contentSniffBytes]
+	}
+	if endianness, isUTF16 := guessUTF16(head); isUTF16 {
+		name := "UTF-16LE"
+		if endianness == textunicode.BigEndian {
+			name = "UTF-16BE"
+		}
+		return decodeUTF16(content, endianness, name)
+	}
+	if bytes.IndexByte(head, 0) >= 0 || isBinaryMIME(http.DetectContentType(head)) {
+		return "", "", false
+	}
+
+	if utf8.Valid(content) {
+		return string(content), "UTF-8", true
+	}
+	// A head with multi-byte UTF-8 marks a UTF-8 file with a few stray bytes.
+	// An ASCII head says nothing about the encoding of the rest.
+	headIsUTF8 := utf8.Valid(trimIncompleteRune(head))
+	if headIsUTF8 && !isASCII(head) {
+		return strings.ToValidUTF8(string(content), "\uFFFD"), "UTF-8", true
+	}
+	for _, legacy := range legacyEncodings {
+		decoded, err := legacy.encoding.NewDecoder().Bytes(content)
+		if err != nil {
+			continue
+		}
+		text := string(decoded)
+		if strings.ContainsRune(text, utf8.RuneError) || !isPlausibleText(text) || !legacy.accept(text) {
+			continue
+		}
+		return text, legacy.name, true
+	}
+	if headIsUTF8 {
+		return strings.ToValidUTF8(string(content), "\uFFFD"), "UTF-8", true
+	}
+	return "", "", false
+}
+
+func decodeUTF16(content []byte, endianness textunicode.Endianness, name string) (string, string, bool) {
+	// UseBOM strips a BOM and falls back to endianness without one.
+	decoded, err := textunicode.UTF16(endianness, textunicode.UseBOM).NewDecoder().Bytes(content)
+	if err != nil {
+		return "", "", false
+	}
+	text := string(decoded)
+	if !isPlausibleText(text) {
+		return "", "", false
+	}
+	return text, name, true
+}
+
+// guessUTF16 recognizes BOM-less UTF-16 from mostly-ASCII text, where every
+// other byte is NUL.
+func guessUTF16(head []byte) (textunicode.Endianness, bool) {
+	if len(head) < 4 {
+		return textunicode.LittleEndian, false
+	}
+	var evenNUL, oddNUL int
+	for i, b := range head {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			evenNUL++
+		} else {
+			oddNUL++
+		}
+	}
+	pairs := len(head) / 2
+	switch {
+	case oddNUL*10 >= pairs*7 && evenNUL*20 <= pairs:
+		return textunicode.LittleEndian, true
+	case evenNUL*10 >= pairs*7 && oddNUL*20 <= pairs:
+		return textunicode.BigEndian, true
+	}
+	return textunicode.LittleEndian, false
+}
+
+func isBinaryMIME(mime string) bool {
+	for _, prefix := range binaryMIMEPrefixes {
+		if strings.HasPrefix(mime, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// trimIncompleteRune drops a UTF-8 sequence cut off at the end of head.
+func trimIncompleteRune(head []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(head); i++ {
+		if utf8.RuneStart(head[len(head)-i]) {
+			if !utf8.FullRune(head[len(head)-i:]) {
+				return head[:len(head)-i]
+			}
+			break
+		}
+	}
+	return head
+}
+
+func isASCII(data []byte) bool {
+	for _, b := range data {
+		if b >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// isPlausibleText rejects decoded text dominated by control characters other
+// than whitespace and escape sequences.
+func isPlausibleText(text string) bool {
+	var total, control int
+	for _, r := range text {
+		total++
+		if unicode.IsControl(r) && !unicode.IsSpace(r) && r != '\x1b' {
+			control++
+		}
+	}
+	return total == 0 || float64(control)/float64(total) <= maxControlRatio
+}
+
+func containsScript(scripts ...*unicode.RangeTable) func(text string) bool {
+	return func(text string) bool {
+		for _, r := range text {
+			if unicode.In(r, scripts...) {
+				return true
+			}
+		}
+		return false
+	}
+}
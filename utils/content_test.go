@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/japanese"
+	textunicode "golang.org/x/text/encoding/unicode"
+)
+
+func TestDecodeTextContent(t *testing.T) {
+	shiftJIS, err := japanese.ShiftJIS.NewEncoder().Bytes([]byte("// こんにちは、世界\nint main() { return 0; }\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	utf16LE, err := textunicode.UTF16(textunicode.LittleEndian, textunicode.UseBOM).NewEncoder().Bytes([]byte("hello = 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	utf16BENoBOM, err := textunicode.UTF16(textunicode.BigEndian, textunicode.IgnoreBOM).NewEncoder().Bytes([]byte("hello = 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		content      []byte
+		expectedText string
+		expectedEnc  string
+		expectedOK   bool
+	}{
+		{name: "UTF-8", content: []byte("fmt.Println(\"héllo\")\n"), expectedText: "fmt.Println(\"héllo\")\n", expectedEnc: "UTF-8", expectedOK: true},
+		{name: "UTF-8 BOM is stripped", content: []byte("\xEF\xBB\xBFx = 1\n"), expectedText: "x = 1\n", expectedEnc: "UTF-8", expectedOK: true},
+		{name: "Empty file", content: []byte{}, expectedText: "", expectedEnc: "UTF-8", expectedOK: true},
+		{name: "Shift_JIS", content: shiftJIS, expectedText: "// こんにちは、世界\nint main() { return 0; }\n", expectedEnc: "Shift_JIS", expectedOK: true},
+		{name: "Windows-1252", content: []byte("caf\xe9 = 1\n"), expectedText: "café = 1\n", expectedEnc: "Windows-1252", expectedOK: true},
+		{name: "UTF-16LE with BOM", content: utf16LE, expectedText: "hello = 1\n", expectedEnc: "UTF-16LE", expectedOK: true},
+		{name: "UTF-16BE without BOM", content: utf16BENoBOM, expectedText: "hello = 1\n", expectedEnc: "UTF-16BE", expectedOK: true},
+		{name: "NUL bytes", content: []byte("ELF\x00\x01\x02\x00\x00abc\x00def"), expectedOK: false},
+		{name: "PNG signature", content: []byte("\x89PNG\r\n\x1a\nrest of the image"), expectedOK: false},
+		{name: "PDF signature", content: []byte("%PDF-1.7\n1 0 obj\n"), expectedOK: false},
+		{name: "Control characters", content: []byte("\x01\x02\x03\x04\x05\x06\x07\x08\x0e\x0f\x10\x11\xff"), expectedOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, enc, ok := decodeTextContent(tt.content)
+			if ok != tt.expectedOK {
+				t.Fatalf("expected ok=%v, got %v (encoding %q)", tt.expectedOK, ok, enc)
+			}
+			if !ok {
+				return
+			}
+			if text != tt.expectedText || enc != tt.expectedEnc {
+				t.Errorf("expected %q as %s, got %q as %s", tt.expectedText, tt.expectedEnc, text, enc)
+			}
+		})
+	}
+}
+
+func TestProcessSourceFiles_SkipsBinaryContent(t *testing.T) {
+	tempDir := t.TempDir()
+	createTestFile(t, filepath.Join(tempDir, "main.go"), "package main\n")
+	if err := os.WriteFile(filepath.Join(tempDir, "data.json"), []byte("\x00\x01\x02binary\x00"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := japanese.ShiftJIS.NewEncoder().Bytes([]byte("# コメント\nprint(1)\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "legacy.py"), legacy, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output := ProcessSourceFiles(tempDir, 5, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, false, nil)
+
+	if strings.Contains(output, "### data.json") {
+		t.Errorf("expected binary data.json to be skipped:\n%s", output)
+	}
+	if !strings.Contains(output, "# コメント\nprint(1)") {
+		t.Errorf("expected legacy.py to be transcoded to UTF-8:\n%s", output)
+	}
+	if !strings.Contains(output, "### main.go") {
+		t.Errorf("expected main.go to be collected:\n%s", output)
+	}
+}
+
+func TestReadmesAndManifests_AreDecoded(t *testing.T) {
+	tempDir := t.TempDir()
+	readme, err := textunicode.UTF16(textunicode.LittleEndian, textunicode.UseBOM).NewEncoder().Bytes([]byte("# Héllo\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "README.md"), readme, 0644); err != nil {
+		t.Fatal(err)
+	}
+	createTestFile(t, filepath.Join(tempDir, "docs", "README.md"), "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	// "café" in Latin-1.
+	if err := os.WriteFile(filepath.Join(tempDir, "Gemfile"), []byte("gem 'caf\xe9'\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	readmes := CollectReadmeFiles(tempDir, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, nil)
+	if !strings.Contains(readmes, "### README.md\n```markdown\n# Héllo\n") {
+		t.Errorf("expected the UTF-16 README to be transcoded:\n%s", readmes)
+	}
+	if strings.Contains(readmes, "docs/README.md") || strings.Contains(readmes, "PNG") {
+		t.Errorf("expected the binary README to be skipped:\n%s", readmes)
+	}
+
+	output := ProcessSourceFiles(tempDir, 5, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, false, nil)
+	if !strings.Contains(output, "gem 'café'") {
+		t.Errorf("expected the Latin-1 manifest to be transcoded:\n%s", output)
+	}
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// dependencyKindManifest marks a manifest included verbatim.
+	dependencyKindManifest = "manifest"
+	// dependencyKindLockfile marks a lockfile reduced to its direct dependencies.
+	dependencyKindLockfile = "lockfile"
+)
+
+// nonManifestSignatures are PROJECT_SIGNATURES file names that identify a
+// project but are documentation or source rather than manifests.
+var nonManifestSignatures = map[string]struct{}{
+	"README.md": {},
+	"main.tf":   {},
+}
+
+// manifestSignatureExtensions are the PROJECT_SIGNATURES extensions that
+// belong to project files (.csproj, .cabal, ...) rather than to source code.
+var manifestSignatureExtensions = map[string]struct{}{
+	".csproj": {},
+	".fsproj": {},
+	".vbproj": {},
+	".sln":    {},
+	".cabal":  {},
+}
+
+// isDependencyManifest reports whether a file name is a manifest listed in
+// PROJECT_SIGNATURES, such as go.mod, package.json or Cargo.toml.
+func isDependencyManifest(name string) bool {
+	if _, ok := nonManifestSignatures[name]; ok {
+		return false
+	}
+	if _, ok := manifestSignatureExtensions[strings.ToLower(filepath.Ext(name))]; ok {
+		return true
+	}
+	for _, signatures := range PROJECT_SIGNATURES {
+		for _, signature := range signatures {
+			if signature == name && !strings.HasPrefix(signature, ".") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// renderDependencyMarkdown renders a manifest as a code block and a lockfile
+// as its list of direct dependencies.
+func renderDependencyMarkdown(file dependencyFile) string {
+	if file.Kind == dependencyKindManifest {
+		return renderMarkdownFile(sourceFile{
+			Path:     file.Path,
+			Language: GetLanguageByExtension(filepath.Base(file.Path)),
+			Content:  file.Content,
+		})
+	}
+	content := lockfileSummaryText(file.Dependencies)
+	fence := markdownFence(content)
+	return fmt.Sprintf("### %s (%d direct of %d locked)\n%stext\n%s\n%s\n", file.Path, len(file.Dependencies), file.Locked, fence, content, fence)
+}
+
+// lockfileSummaryText lists one "name version" line per direct dependency,
+// marking development-only dependencies.
+func lockfileSummaryText(deps []lockedDependency) string {
+	if len(deps) == 0 {
+		return "no direct dependencies"
+	}
+	lines := make([]string, 0, len(deps))
+	for _, dep := range deps {
+		line := dep.Name
+		if dep.Version != "" {
+			line += " " + dep.Version
+		}
+		if dep.Dev {
+			line += " (dev)"
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsDependencyManifest(t *testing.T) {
+	for _, name := range []string{"go.mod", "package.json", "Cargo.toml", "pyproject.toml", "Gemfile", "pom.xml", "App.csproj"} {
+		assert.True(t, isDependencyManifest(name), name)
+	}
+	for _, name := range []string{"README.md", "main.tf", "main.go", "node.json", "index.html"} {
+		assert.False(t, isDependencyManifest(name), name)
+	}
+}
+
+func TestCollectDependencyFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	createTestFile(t, filepath.Join(tempDir, "go.mod"), "module example.com/app\n\nrequire github.com/spf13/cobra v1.9.1\n")
+	createTestFile(t, filepath.Join(tempDir, "go.sum"), "github.com/spf13/cobra v1.9.1 h1:x=\ngithub.com/spf13/pflag v1.0.6 h1:y=\n")
+	createTestFile(t, filepath.Join(tempDir, "web", "package.json"), `{"dependencies": {"react": "^18"}}`)
+	createTestFile(t, filepath.Join(tempDir, "web", "package-lock.json"),
+		`{"packages": {"": {"dependencies": {"react": "^18"}}, "node_modules/react": {"version": "18.2.0"}}}`)
+	createTestFile(t, filepath.Join(tempDir, "node_modules", "dep", "package.json"), `{}`)
+	createTestFile(t, filepath.Join(tempDir, "main.go"), "package main\n")
+
+	depFileContents, processed, depFiles := collectDependencyFiles(tempDir, nil, nil, map[string]struct{}{}, nil, DefaultExcludeNames, nil, false, nil)
+
+	snippets := depFileContents[DependencyFilesCategory]
+	require.Len(t, snippets, 4)
+	require.Len(t, depFiles, 4)
+	assert.Len(t, processed, 4)
+	assert.NotContains(t, processed, filepath.Join(tempDir, "main.go"))
+
+	all := strings.Join(snippets, "\n")
+	assert.Contains(t, all, "### go.mod\n```\nmodule example.com/app")
+	assert.Contains(t, all, "### go.sum (1 direct of 2 locked)\n```text\ngithub.com/spf13/cobra v1.9.1\n```")
+	assert.Contains(t, all, "### web/package.json\n```json\n")
+	assert.Contains(t, all, "### web/package-lock.json (1 direct of 1 locked)\n```text\nreact 18.2.0\n```")
+	assert.NotContains(t, all, "h1:x=", "lockfiles must not be dumped verbatim")
+	assert.NotContains(t, all, "node_modules")
+}
+
+func TestCollectDependencyFiles_ManifestSizeLimit(t *testing.T) {
+	origMaxFileSize := MaxFileSizeBytes
+	t.Cleanup(func() { MaxFileSizeBytes = origMaxFileSize })
+	MaxFileSizeBytes = 10
+
+	tempDir := t.TempDir()
+	createTestFile(t, filepath.Join(tempDir, "package.json"), `{"dependencies": {"react": "^18"}}`)
+	createTestFile(t, filepath.Join(tempDir, "package-lock.json"),
+		`{"packages": {"": {"dependencies": {"react": "^18"}}, "node_modules/react": {"version": "18.2.0"}}}`)
+
+	depFileContents, processed, _ := collectDependencyFiles(tempDir, nil, nil, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, nil)
+	snippets := depFileContents[DependencyFilesCategory]
+	require.Len(t, snippets, 1, "only the lockfile summary is exempt from --max-file-size")
+	assert.True(t, strings.HasPrefix(snippets[0], "### package-lock.json"))
+	assert.Len(t, processed, 2)
+}
+
+func TestRenderDependencyMarkdown_EmptyLockfile(t *testing.T) {
+	output := renderDependencyMarkdown(dependencyFile{Path: "Gemfile.lock", Kind: dependencyKindLockfile})
+	assert.Equal(t, "### Gemfile.lock (0 direct of 0 locked)\n```text\nno direct dependencies\n```\n", output)
+}
+
+func TestProcessSourceFiles_DependencyRecords(t *testing.T) {
+	withOutputFormat(t, FormatJSON)
+
+	tempDir := t.TempDir()
+	createTestFile(t, filepath.Join(tempDir, "Cargo.toml"), "[package]\nname = \"app\"\n")
+	createTestFile(t, filepath.Join(tempDir, "Cargo.lock"), "[[package]]\nname = \"app\"\ndependencies = [\n \"serde\",\n]\n\n[[package]]\nname = \"serde\"\nversion = \"1.0.197\"\nsource = \"registry\"\n")
+	createTestFile(t, filepath.Join(tempDir, "src", "main.rs"), "fn main() {}\n")
+
+	output := ProcessSourceFiles(tempDir, 5, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, false, nil)
+
+	var doc jsonDocument
+	require.NoError(t, json.Unmarshal([]byte(output), &doc), output)
+	require.Len(t, doc.Files, 1)
+	assert.Equal(t, "src/main.rs", doc.Files[0].Path)
+	assert.Equal(t, []dependencyFile{
+		{Path: "Cargo.lock", Kind: dependencyKindLockfile, Dependencies: []lockedDependency{{Name: "serde", Version: "1.0.197"}}, Locked: 1},
+		{Path: "Cargo.toml", Kind: dependencyKindManifest, Content: "[package]\nname = \"app\"\n"},
+	}, doc.Dependencies)
+}
+
+func TestProcessSourceFiles_ManifestsCountTowardBudgets(t *testing.T) {
+	withOutputFormat(t, FormatJSON)
+	origTotal, origTokens := TotalMaxFileSizeBytes, MaxTokens
+	t.Cleanup(func() { TotalMaxFileSizeBytes, MaxTokens = origTotal, origTokens })
+
+	tempDir := t.TempDir()
+	manifest := "module app\n\n// " + strings.Repeat("x", 286) + "\n"
+	createTestFile(t, filepath.Join(tempDir, "go.mod"), manifest)
+	createTestFile(t, filepath.Join(tempDir, "main.go"), "package main\n\nfunc main() {}\n")
+
+	collect := func() jsonDocument {
+		t.Helper()
+		var doc jsonDocument
+		output := ProcessSourceFiles(tempDir, 5, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, false, nil)
+		require.NoError(t, json.Unmarshal([]byte(output), &doc), output)
+		return doc
+	}
+
+	t.Run("size", func(t *testing.T) {
+		TotalMaxFileSizeBytes, MaxTokens = 310, 0
+		doc := collect()
+		require.Len(t, doc.Dependencies, 1)
+		assert.Empty(t, doc.Files, "the manifest leaves no room for main.go")
+		assert.Equal(t, int64(len(manifest)), doc.TotalSize)
+		assert.True(t, doc.LimitHit)
+		require.Len(t, doc.Omitted, 1)
+		assert.Equal(t, "main.go", doc.Omitted[0].Path)
+	})
+
+	t.Run("tokens", func(t *testing.T) {
+		TotalMaxFileSizeBytes, MaxTokens = 0, int64(CountTokens(manifest)-1)
+		doc := collect()
+		assert.Empty(t, doc.Dependencies, "the manifest does not fit the token budget")
+		require.Len(t, doc.Files, 1)
+		assert.True(t, doc.TokenLimitHit)
+		require.Len(t, doc.Omitted, 1)
+		assert.Equal(t, "go.mod", doc.Omitted[0].Path)
+		assert.Equal(t, doc.Files[0].Tokens, doc.TotalTokens)
+	})
+}
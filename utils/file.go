@@ -1,11 +1,11 @@
 package utils
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
-	"os"
+	"io"
 	"path/filepath"
-	"sort"
 	"strings"
 )
 
@@ -56,20 +56,36 @@ func IsTestFile(filePath string, debug bool) bool {
 
 // IsAssetFile determines if the specified file path is an asset file that should be excluded.
 // Asset files include images, fonts, media files, archives, and other binary files
-// that are not useful for source code analysis.
+// that are not useful for source code analysis. Files that no language claims,
+// such as compiled executables without an extension, are sniffed for binary content.
 func IsAssetFile(filePath string, debug bool) bool {
-	ext := strings.ToLower(filepath.Ext(filePath))
-	if ext == "" {
-		return false
+	if hasAssetExtension(filePath, debug) {
+		return true
 	}
+	head := newFileHead(filePath)
+	return isBinaryAsset(filePath, activeLanguageDetector.detectWithHead(filePath, head), head, debug)
+}
 
-	if _, isAsset := ASSET_EXTENSIONS[ext]; isAsset {
+func hasAssetExtension(filePath string, debug bool) bool {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if _, isAsset := ASSET_EXTENSIONS[ext]; isAsset && ext != "" {
 		if debug {
 			PrintDebug(fmt.Sprintf("IsAssetFile: Path '%s' identified as asset file (extension: %s)", filePath, ext), true)
 		}
 		return true
 	}
+	return false
+}
 
+// isBinaryAsset reports whether a file detected as language is an asset
+// by its content: binary data without a language.
+func isBinaryAsset(filePath, language string, head *fileHead, debug bool) bool {
+	if language == "" && head.binary() {
+		if debug {
+			PrintDebug(fmt.Sprintf("IsAssetFile: Path '%s' identified as asset file (binary content)", filePath), true)
+		}
+		return true
+	}
 	return false
 }
 
@@ -87,7 +103,13 @@ func ShouldSkipEntry(fullPath, name string, isDir bool, includePaths map[string]
 		PrintWarning(fmt.Sprintf("Could not get absolute path for %s: %v", fullPath, err), true)
 		return true // Failsafe skip
 	}
+	return shouldSkipPath(absPath, name, isDir, includePaths, includeMatcher, excludeNames, excludeMatcher, gi)
+}
 
+// shouldSkipPath applies the ShouldSkipEntry rules to a clean absolute path,
+// as filepath.WalkDir yields below an absolute root. isDir is trusted for
+// the .gitignore rules, so no entry is stat'ed.
+func shouldSkipPath(absPath, name string, isDir bool, includePaths map[string]struct{}, includeMatcher *SimpleMatcher, excludeNames map[string]struct{}, excludeMatcher *SimpleMatcher, gi *GitIgnoreMatcher) bool {
 	// Priority 1: Explicit --exclude options and hardcoded names always cause a skip.
 	if _, ok := excludeNames[name]; ok {
 		return true
@@ -125,7 +147,7 @@ func ShouldSkipEntry(fullPath, name string, isDir bool, includePaths map[string]
 	}
 
 	// Priority 3: .gitignore matcher - skip files/directories matching .gitignore patterns.
-	if gi != nil && gi.Match(absPath) {
+	if gi != nil && gi.MatchWithType(absPath, isDir) {
 		return true
 	}
 
@@ -139,36 +161,6 @@ func ShouldSkipEntry(fullPath, name string, isDir bool, includePaths map[string]
 	return false
 }
 
-func shouldSkipPath(absPath, name string, isDir bool, includePaths, excludeNames, excludePaths map[string]struct{}, gi *GitIgnoreMatcher) bool {
-	// Priority 1: Explicit --exclude options and hardcoded names always cause a skip.
-	if _, ok := excludeNames[name]; ok {
-		return true
-	}
-	if _, ok := excludePaths[absPath]; ok {
-		return true
-	}
-
-	// Priority 2: include-only behavior for scanner-based paths.
-	if len(includePaths) > 0 {
-		if isPathRelatedToIncludes(absPath, includePaths) {
-			return false
-		}
-		return true
-	}
-
-	// Priority 3: .gitignore matcher - skip files/directories matching .gitignore patterns.
-	if gi != nil && gi.MatchWithType(absPath, isDir) {
-		return true
-	}
-
-	// Priority 4: Default exclusion for dotfiles and dot-directories.
-	if strings.HasPrefix(name, ".") && name != "." && name != ".." {
-		return true
-	}
-
-	return false
-}
-
 func normalizeForPathMatch(path string) string {
 	return filepath.ToSlash(filepath.Clean(path))
 }
@@ -194,303 +186,168 @@ func isPathRelatedToIncludes(absPath string, includePaths map[string]struct{}) b
 // GenerateDirectoryStructure generates the project directory structure in Markdown format.
 func GenerateDirectoryStructure(startPath string, maxDepth int, debugMode bool, includePaths map[string]struct{}, includeMatcher *SimpleMatcher, excludeNames map[string]struct{}, excludeMatcher *SimpleMatcher, includeTests bool, gi *GitIgnoreMatcher) string {
 	PrintDebug("Generating directory structure...", debugMode)
-	var structureLines []string
-	structureLines = append(structureLines, "## Project Structure", "```text")
-
-	absStartPath, err := filepath.Abs(startPath)
-	if err != nil {
+	scanner := &projectScanner{
+		rootPath:         startPath,
+		includePaths:     includePaths,
+		includeMatcher:   includeMatcher,
+		excludeNames:     excludeNames,
+		excludeMatcher:   excludeMatcher,
+		debug:            debugMode,
+		includeTests:     includeTests,
+		gi:               gi,
+		walkDepth:        max(maxDepth, 0) + 1, // one level more to tell whether "..." is needed
+		collectStructure: true,
+	}
+	result, err := scanner.scan()
+	if result == nil {
 		PrintError(fmt.Sprintf("Could not get absolute path for %s: %v", startPath, err))
 		return ""
 	}
-	rootDisplayName := filepath.Base(absStartPath)
-	structureLines = append(structureLines, fmt.Sprintf(". (%s)", rootDisplayName))
-
-	var generateTreeRecursive func(currentPath, prefix string, depth int)
-	generateTreeRecursive = func(currentPath, prefix string, depth int) {
-		entries, err := os.ReadDir(currentPath)
-		if err != nil {
-			PrintWarning(fmt.Sprintf("Could not list directory '%s': %v", currentPath, err), debugMode)
-			return
-		}
-
-		sort.Slice(entries, func(i, j int) bool {
-			return strings.ToLower(entries[i].Name()) < strings.ToLower(entries[j].Name())
-		})
-
-		var filteredEntries []os.DirEntry
-		for _, entry := range entries {
-			itemPath := filepath.Join(currentPath, entry.Name())
-			if ShouldSkipEntry(itemPath, entry.Name(), entry.IsDir(), includePaths, includeMatcher, excludeNames, excludeMatcher, gi) {
-				continue
-			}
-			// Skip test files from directory structure
-			if !entry.IsDir() && !includeTests && IsTestFile(itemPath, debugMode) {
-				continue
-			}
-			// Skip asset files from directory structure unless explicitly included
-			if !entry.IsDir() && IsAssetFile(itemPath, debugMode) {
-				// Check if this file is explicitly included
-				absItemPath, _ := filepath.Abs(itemPath)
-				isExplicitlyIncluded := false
-
-				if includeMatcher != nil && includeMatcher.Match(absItemPath) {
-					isExplicitlyIncluded = true
-				}
-
-				if !isExplicitlyIncluded && len(includePaths) > 0 {
-					for incPath := range includePaths {
-						if strings.HasPrefix(absItemPath, incPath) || strings.HasPrefix(incPath, absItemPath) {
-							isExplicitlyIncluded = true
-							break
-						}
-					}
-				}
-				if !isExplicitlyIncluded {
-					continue
-				}
-			}
-			filteredEntries = append(filteredEntries, entry)
-		}
-
-		// Separate files and directories, and handle maxDepth logic
-		var filesToShow []os.DirEntry
-		var dirsToShow []os.DirEntry
-		var hasHiddenDirs bool
-
-		for _, entry := range filteredEntries {
-			if entry.IsDir() {
-				if maxDepth > 0 && depth < maxDepth {
-					dirsToShow = append(dirsToShow, entry)
-				} else {
-					hasHiddenDirs = true
-				}
-			} else {
-				filesToShow = append(filesToShow, entry)
-			}
-		}
-
-		// Combine files and visible directories
-		entriesToShow := append(filesToShow, dirsToShow...)
-
-		// Add ellipsis if there are hidden directories
-		showEllipsis := hasHiddenDirs
-
-		for i, entry := range entriesToShow {
-			pointer := "├── "
-			if i == len(entriesToShow)-1 && !showEllipsis {
-				pointer = "└── "
-			}
-
-			structureLines = append(structureLines, prefix+pointer+entry.Name())
-
-			if entry.IsDir() {
-				extension := "│   "
-				if pointer == "└── " && !showEllipsis {
-					extension = "    "
-				}
-				generateTreeRecursive(filepath.Join(currentPath, entry.Name()), prefix+extension, depth+1)
-			}
-		}
+	PrintDebug("Directory structure generation complete.", debugMode)
+	return buildDirectoryStructureMarkdown(result.rootPath, maxDepth, result.structureChildren)
+}
 
-		// Add ellipsis at the end if there are hidden directories
-		if showEllipsis {
-			structureLines = append(structureLines, prefix+"└── ...")
-		}
+// directoryStructureMarkdown wraps tree lines in the "## Project Structure" section.
+func directoryStructureMarkdown(treeLines []string) string {
+	if treeLines == nil {
+		return ""
 	}
-
-	generateTreeRecursive(absStartPath, "", 0)
-	structureLines = append(structureLines, "```")
-	PrintDebug("Directory structure generation complete.", debugMode)
+	fence := markdownFence(strings.Join(treeLines, "\n"))
+	structureLines := append([]string{"## Project Structure", fence + "text"}, treeLines...)
+	structureLines = append(structureLines, fence)
 	return strings.Join(structureLines, "\n") + "\n\n"
 }
 
-// CollectReadmeFiles collects README.md files in the project and returns their content in Markdown format.
-func CollectReadmeFiles(folderAbs string, includePaths map[string]struct{}, includeMatcher *SimpleMatcher, excludeNames map[string]struct{}, excludeMatcher *SimpleMatcher, debug bool, gi *GitIgnoreMatcher) string {
-	PrintDebug("Searching for README.md files...", debug)
-	var readmeFiles []string
+// ListProjectTree walks rootPath once with the exclude names and .gitignore
+// rules and returns the entries of every listed directory, keyed by its
+// absolute path. Tests, assets and files of filtered languages are listed
+// too, since the caller lets the user pick files. Directories at maxDepth
+// are listed but not entered; 0 lists the whole tree.
+func ListProjectTree(rootPath string, maxDepth int, excludeNames map[string]struct{}, gi *GitIgnoreMatcher) (map[string][]ProjectEntry, error) {
+	scanner := &projectScanner{
+		rootPath:         rootPath,
+		excludeNames:     excludeNames,
+		gi:               gi,
+		walkDepth:        max(maxDepth, 0),
+		collectStructure: true,
+		listAllFiles:     true,
+	}
+	result, err := scanner.scan()
+	if result == nil {
+		return nil, err
+	}
+	// An unreadable root is listed as empty, so the selector still opens.
+	return result.structureChildren, nil
+}
 
-	filepath.WalkDir(folderAbs, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			PrintWarning(fmt.Sprintf("Error accessing path %s: %v", path, err), debug)
-			return nil
-		}
-		if path == folderAbs {
-			return nil
-		}
+// WriteReadmeFiles writes every README.md in the project to w in the active
+// OutputFormat, preceded by prompt unless it is empty. It returns an error
+// when folderAbs cannot be scanned, or the first error from writing to w.
+func WriteReadmeFiles(w io.Writer, prompt string, folderAbs string, includePaths map[string]struct{}, includeMatcher *SimpleMatcher, excludeNames map[string]struct{}, excludeMatcher *SimpleMatcher, debug bool, gi *GitIgnoreMatcher) error {
+	PrintDebug("Searching for README.md files...", debug)
+	scanner := &projectScanner{
+		rootPath:       folderAbs,
+		includePaths:   includePaths,
+		includeMatcher: includeMatcher,
+		excludeNames:   excludeNames,
+		excludeMatcher: excludeMatcher,
+		debug:          debug,
+		gi:             gi,
+		collectReadmes: true,
+	}
+	scanned, err := scanner.scan()
+	if err != nil {
+		return fmt.Errorf("could not scan %s: %w", folderAbs, err)
+	}
 
-		if d.IsDir() {
-			if ShouldSkipEntry(path, d.Name(), true, includePaths, includeMatcher, excludeNames, excludeMatcher, gi) {
-				return filepath.SkipDir
-			}
-			return nil
-		}
+	readmeResult := &scanResult{rootPath: folderAbs, files: scanned.readmes}
+	for _, readme := range scanned.readmes {
+		readmeResult.totalFileSize += readme.Size
+		readmeResult.totalTokens += readme.Tokens
+	}
+	PrintDebug(fmt.Sprintf("Found %d README.md file(s).", len(scanned.readmeFiles)), debug)
 
-		if strings.ToLower(d.Name()) == "readme.md" {
-			if ShouldSkipEntry(path, d.Name(), false, includePaths, includeMatcher, excludeNames, excludeMatcher, gi) {
-				return nil
-			}
+	out := bufio.NewWriter(w)
+	if OutputFormat != FormatMarkdown {
+		writeDocument(newDocumentWriter(out, debug), prompt, filepath.Base(folderAbs), nil, readmeResult)
+		return out.Flush()
+	}
+	readmeMD := "# Project README Files\n\nNo README.md files found in the project."
+	if len(scanned.readmeFiles) > 0 {
+		readmeMD = "# Project README Files\n\n" + strings.Join(scanned.readmeFiles, "\n")
+	}
+	out.WriteString(FormatWithPrompt(prompt, readmeMD))
+	return out.Flush()
+}
 
-			content, err := os.ReadFile(path)
-			if err != nil {
-				PrintWarning(fmt.Sprintf("Could not read README file '%s': %v", path, err), debug)
-				return nil
-			}
-			fileDisplayName, relErr := filepath.Rel(folderAbs, path)
-			if relErr != nil {
-				PrintWarning(fmt.Sprintf("Could not get relative path for %s: %v", path, relErr), debug)
-				fileDisplayName = path
-			}
-			fileDisplayName = filepath.ToSlash(fileDisplayName)
-			markdownContent := fmt.Sprintf("### %s\n```markdown\n%s\n```\n", fileDisplayName, string(content))
-			readmeFiles = append(readmeFiles, markdownContent)
-		}
-		return nil
-	})
+// CollectReadmeFiles returns the README files written by WriteReadmeFiles,
+// without a prompt, as a string.
+func CollectReadmeFiles(folderAbs string, includePaths map[string]struct{}, includeMatcher *SimpleMatcher, excludeNames map[string]struct{}, excludeMatcher *SimpleMatcher, debug bool, gi *GitIgnoreMatcher) string {
+	var b strings.Builder
+	// Writing to a strings.Builder does not fail, so an error is from the scan.
+	if err := WriteReadmeFiles(&b, "", folderAbs, includePaths, includeMatcher, excludeNames, excludeMatcher, debug, gi); err != nil {
+		PrintError(err.Error())
+	}
+	return b.String()
+}
 
-	PrintDebug(fmt.Sprintf("Found %d README.md file(s).", len(readmeFiles)), debug)
-	if len(readmeFiles) == 0 {
-		return "# Project README Files\n\nNo README.md files found in the project."
+// collectDependencyFiles collects the manifests listed in PROJECT_SIGNATURES
+// and summarizes lockfiles as their direct dependencies. The collected paths
+// are returned so that collectSourceFiles does not repeat them.
+// ProcessSourceFiles collects them in the same walk as the sources instead.
+func collectDependencyFiles(folderAbs string, primaryLangs []string, fallbackLangs map[string]int, includePaths map[string]struct{}, includeMatcher *SimpleMatcher, excludeNames map[string]struct{}, excludeMatcher *SimpleMatcher, debug bool, gi *GitIgnoreMatcher) (map[string][]string, map[string]struct{}, []dependencyFile) {
+	PrintDebug("Processing dependency files...", debug)
+	scanner := &projectScanner{
+		rootPath:            folderAbs,
+		includePaths:        includePaths,
+		includeMatcher:      includeMatcher,
+		excludeNames:        excludeNames,
+		excludeMatcher:      excludeMatcher,
+		debug:               debug,
+		gi:                  gi,
+		processedDepFiles:   make(map[string]struct{}),
+		collectDependencies: true,
 	}
-	return "# Project README Files\n\n" + strings.Join(readmeFiles, "\n")
+	result, _ := scanner.scan()
+	if result == nil {
+		return map[string][]string{}, scanner.processedDepFiles, nil
+	}
+	return dependencyFileContents(result.dependencies), scanner.processedDepFiles, result.dependencies
 }
 
-// collectDependencyFiles collects dependency files.
-func collectDependencyFiles(folderAbs string, primaryLangs []string, fallbackLangs map[string]int, includePaths map[string]struct{}, includeMatcher *SimpleMatcher, excludeNames map[string]struct{}, excludeMatcher *SimpleMatcher, debug bool, gi *GitIgnoreMatcher) (map[string][]string, map[string]struct{}) {
+// dependencyFileContents renders dependency files for buildMarkdownOutput.
+func dependencyFileContents(depFiles []dependencyFile) map[string][]string {
 	depFileContents := make(map[string][]string)
-	processedDepFiles := make(map[string]struct{})
-	// FRAMEWORK_DEPENDENCY_FILES has been removed, so this function no longer collects dependency files
-	return depFileContents, processedDepFiles
+	for _, file := range depFiles {
+		depFileContents[DependencyFilesCategory] = append(depFileContents[DependencyFilesCategory], renderDependencyMarkdown(file))
+	}
+	return depFileContents
 }
 
-// collectSourceFiles collects source code files.
-func collectSourceFiles(folderAbs string, primaryLangs []string, fallbackLangs map[string]int, processedDepFiles map[string]struct{}, includePaths map[string]struct{}, includeMatcher *SimpleMatcher, excludeNames map[string]struct{}, excludeMatcher *SimpleMatcher, debug bool, includeTests bool, gi *GitIgnoreMatcher) (map[string][]string, int64, []string, bool) {
-	sourceFileContents := make(map[string][]string)
-	var totalFileSize int64
-	var skippedFileMessages []string
-	var limitHit bool
+// collectSourceFiles collects source code files, leaving out
+// processedDepFiles. ProcessSourceFiles collects them in the same walk as the
+// structure and dependency files instead.
+// primaryLangs and fallbackLangs come from detectProjectLanguages and feed the
+// "Project Overview" section.
+func collectSourceFiles(folderAbs string, primaryLangs []string, fallbackLangs map[string]int, processedDepFiles map[string]struct{}, includePaths map[string]struct{}, includeMatcher *SimpleMatcher, excludeNames map[string]struct{}, excludeMatcher *SimpleMatcher, debug bool, includeTests bool, gi *GitIgnoreMatcher) *scanResult {
 	PrintDebug("Processing source files...", debug)
-
-	walkErr := filepath.WalkDir(folderAbs, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			PrintWarning(fmt.Sprintf("Error accessing path %s: %v", path, debug), debug)
-			return nil
-		}
-		if path == folderAbs {
-			return nil
-		}
-
-		if d.IsDir() {
-			if ShouldSkipEntry(path, d.Name(), true, includePaths, includeMatcher, excludeNames, excludeMatcher, gi) {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-
-		if ShouldSkipEntry(path, d.Name(), false, includePaths, includeMatcher, excludeNames, excludeMatcher, gi) {
-			return nil
-		}
-
-		absPath, _ := filepath.Abs(path)
-		if _, ok := processedDepFiles[absPath]; ok {
-			return nil
-		}
-		if !includeTests && IsTestFile(path, debug) {
-			return nil
-		}
-		language := GetLanguageByExtension(d.Name())
-
-		// Skip asset files unless explicitly included
-		if IsAssetFile(path, debug) {
-			// Check if this file is explicitly included
-			absPath, _ := filepath.Abs(path)
-			isExplicitlyIncluded := false
-
-			if includeMatcher != nil && includeMatcher.Match(absPath) {
-				isExplicitlyIncluded = true
-			}
-
-			if !isExplicitlyIncluded && len(includePaths) > 0 {
-				for incPath := range includePaths {
-					if strings.HasPrefix(absPath, incPath) || strings.HasPrefix(incPath, absPath) {
-						isExplicitlyIncluded = true
-						break
-					}
-				}
-			}
-			if !isExplicitlyIncluded {
-				return nil
-			}
-			// For explicitly included asset files, treat them as plain text
-			if language == "" {
-				language = "text"
-			}
-		} else if language == "" {
-			return nil
-		}
-
-		// By default, process all source files even if they belong to languages
-		// that are not part of the detected primaryLangs. This change broadens
-		// coverage so that files like JavaScript alongside HTML are included
-		// without requiring additional configuration. If future behaviour needs
-		// to restrict languages, the caller should introduce an explicit filter
-		// rather than relying on this internal function.
-		_ = primaryLangs // keep referenced to avoid unused parameter compile errors
-		_ = fallbackLangs
-		processThisFile := true
-
-		if !processThisFile {
-			return nil
-		}
-
-		fileInfo, err := d.Info()
-		if err != nil {
-			PrintWarning(fmt.Sprintf("Could not get file info for '%s': %v", path, err), debug)
-			return nil
-		}
-
-		if fileInfo.Size() > MaxFileSizeBytes {
-			PrintDebug(fmt.Sprintf("Skipping file '%s' due to size (%d bytes > %d bytes)", path, fileInfo.Size(), MaxFileSizeBytes), debug)
-			relPath, relErr := filepath.Rel(folderAbs, path)
-			if relErr != nil {
-				relPath = path
-			}
-			relPath = filepath.ToSlash(relPath)
-			fileSizeMB := float64(fileInfo.Size()) / (1024 * 1024)
-			skippedMessage := fmt.Sprintf("`%s` (%.2f MB)", relPath, fileSizeMB)
-			skippedFileMessages = append(skippedFileMessages, skippedMessage)
-			return nil
-		}
-
-		if TotalMaxFileSizeBytes > 0 && totalFileSize+fileInfo.Size() > TotalMaxFileSizeBytes {
-			PrintDebug(fmt.Sprintf("Stopping scan as total size limit of %d bytes would be exceeded.", TotalMaxFileSizeBytes), debug)
-			limitHit = true
-			return errTotalSizeLimitExceeded
-		}
-
-		totalFileSize += fileInfo.Size()
-
-		content, err := os.ReadFile(path)
-		if err != nil {
-			PrintWarning(fmt.Sprintf("Could not read source file '%s': %v", path, debug), debug)
-			return nil
-		}
-		fileDisplayName, relErr := filepath.Rel(folderAbs, path)
-		if relErr != nil {
-			PrintWarning(fmt.Sprintf("Could not get relative path for %s: %v", path, relErr), debug)
-			fileDisplayName = path
-		}
-		fileDisplayName = filepath.ToSlash(fileDisplayName)
-		codeBlockLangHint := strings.ToLower(language)
-		codeBlockLangHint = strings.ReplaceAll(codeBlockLangHint, "/", "")
-		codeBlockLangHint = strings.ReplaceAll(codeBlockLangHint, "+", "p")
-		markdownContent := fmt.Sprintf("### %s\n```%s\n%s\n```\n", fileDisplayName, codeBlockLangHint, string(content))
-		sourceFileContents[language] = append(sourceFileContents[language], markdownContent)
-		return nil
-	})
-
-	if walkErr != nil && !errors.Is(walkErr, errTotalSizeLimitExceeded) {
-		PrintWarning(fmt.Sprintf("Error during file walk: %v", walkErr), debug)
+	scanner := &projectScanner{
+		rootPath:          folderAbs,
+		includePaths:      includePaths,
+		includeMatcher:    includeMatcher,
+		excludeNames:      excludeNames,
+		excludeMatcher:    excludeMatcher,
+		debug:             debug,
+		includeTests:      includeTests,
+		gi:                gi,
+		processedDepFiles: processedDepFiles,
+		collectSources:    true,
+	}
+	result, _ := scanner.scan()
+	if result == nil {
+		result = &scanResult{rootPath: folderAbs, sourceFileContents: make(map[string][]string)}
 	}
 
-	return sourceFileContents, totalFileSize, skippedFileMessages, limitHit
+	result.overview = newProjectOverview(primaryLangs, fallbackLangs)
+	return result
 }
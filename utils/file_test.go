@@ -715,6 +715,23 @@ func TestIsAssetFile(t *testing.T) {
 	}
 }
 
+func TestIsAssetFile_SniffsUnclaimedFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	createTestFile(t, filepath.Join(tempDir, "app"), "\x7fELF\x02\x01\x01\x00\x00\x00")
+	createTestFile(t, filepath.Join(tempDir, "firmware.xyz"), "\x00\x01\x02\x03")
+	createTestFile(t, filepath.Join(tempDir, "NOTES"), "plain text notes\n")
+
+	if !IsAssetFile(filepath.Join(tempDir, "app"), false) {
+		t.Errorf("expected an extensionless executable to be an asset")
+	}
+	if !IsAssetFile(filepath.Join(tempDir, "firmware.xyz"), false) {
+		t.Errorf("expected binary content with an unknown extension to be an asset")
+	}
+	if IsAssetFile(filepath.Join(tempDir, "NOTES"), false) {
+		t.Errorf("expected a text file without extension not to be an asset")
+	}
+}
+
 func TestCollectReadmeFiles(t *testing.T) {
 	tempDir := t.TempDir()
 
@@ -902,7 +919,7 @@ func TestCollectSourceFilesWithIncludeTests(t *testing.T) {
 			includePaths := make(map[string]struct{})
 			excludeNames := make(map[string]struct{})
 
-			sourceFileContents, _, _, _ := collectSourceFiles(tempDir, primaryLangs, fallbackLangs, processedDepFiles, includePaths, nil, excludeNames, nil, false, tt.includeTests, nil)
+			sourceFileContents := collectSourceFiles(tempDir, primaryLangs, fallbackLangs, processedDepFiles, includePaths, nil, excludeNames, nil, false, tt.includeTests, nil).sourceFileContents
 
 			// Convert collected files to a flat string for easier testing
 
@@ -961,7 +978,7 @@ func TestCollectSourceFiles_NoBlankLineBetweenHeaderAndCodeFence(t *testing.T) {
 		t.Fatalf("Failed to create main.go: %v", err)
 	}
 
-	sourceFileContents, _, _, _ := collectSourceFiles(
+	result := collectSourceFiles(
 		tempDir,
 		[]string{"Go"},
 		map[string]int{"Go": 1},
@@ -976,7 +993,7 @@ func TestCollectSourceFiles_NoBlankLineBetweenHeaderAndCodeFence(t *testing.T) {
 	)
 
 	var allOutput strings.Builder
-	for _, contents := range sourceFileContents {
+	for _, contents := range result.sourceFileContents {
 		allOutput.WriteString(strings.Join(contents, "\n"))
 	}
 	output := allOutput.String()
@@ -1026,7 +1043,8 @@ func TestCollectSourceFilesFileSizeLimits(t *testing.T) {
 		includePaths := make(map[string]struct{})
 		excludeNames := make(map[string]struct{})
 
-		sourceFileContents, _, skippedMessages, limitHit := collectSourceFiles(tempDir, primaryLangs, fallbackLangs, processedDepFiles, includePaths, nil, excludeNames, nil, false, false, nil)
+		result := collectSourceFiles(tempDir, primaryLangs, fallbackLangs, processedDepFiles, includePaths, nil, excludeNames, nil, false, false, nil)
+		sourceFileContents, skippedMessages, limitHit := result.sourceFileContents, result.skippedFileMessages, result.limitHit
 
 		// Should not hit total limit
 
@@ -1068,7 +1086,8 @@ func TestCollectSourceFilesFileSizeLimits(t *testing.T) {
 		includePaths := make(map[string]struct{})
 		excludeNames := make(map[string]struct{})
 
-		sourceFileContents, _, skippedMessages, limitHit := collectSourceFiles(tempDir, primaryLangs, fallbackLangs, processedDepFiles, includePaths, nil, excludeNames, nil, false, false, nil)
+		result := collectSourceFiles(tempDir, primaryLangs, fallbackLangs, processedDepFiles, includePaths, nil, excludeNames, nil, false, false, nil)
+		sourceFileContents, skippedMessages, limitHit := result.sourceFileContents, result.skippedFileMessages, result.limitHit
 
 		// Should hit total limit
 
@@ -0,0 +1,218 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// FocusQuery restricts collection to the source files most relevant to a
+// question or keywords. It is empty when --focus is not set.
+var FocusQuery string
+
+// FocusTop is how many of the most relevant files --focus collects.
+var FocusTop = 10
+
+// BM25 parameters. Path terms count focusPathBoost times, so a file named
+// after the topic ranks above one that only mentions it.
+const (
+	bm25K1         = 1.2
+	bm25B          = 0.75
+	focusPathBoost = 3
+)
+
+var focusWordPattern = regexp.MustCompile(`[\p{L}\p{N}_]+`)
+
+// focusStopWords are question words that carry no topic.
+var focusStopWords = map[string]struct{}{
+	"a": {}, "an": {}, "and": {}, "are": {}, "as": {}, "at": {}, "be": {}, "by": {}, "do": {}, "does": {},
+	"for": {}, "from": {}, "how": {}, "in": {}, "is": {}, "it": {}, "of": {}, "on": {}, "or": {}, "the": {},
+	"this": {}, "to": {}, "what": {}, "when": {}, "where": {}, "which": {}, "who": {}, "why": {}, "with": {},
+}
+
+// focusTerms splits text into lower-case search terms. Identifiers are split
+// at camelCase humps, digits and underscores, and the adjacent pairs and the
+// whole identifier are kept too, so "gitignore" matches GitIgnoreMatcher and
+// "ignore" matches git_ignore.
+func focusTerms(text string) []string {
+	var terms []string
+	for _, word := range focusWordPattern.FindAllString(text, -1) {
+		parts := splitIdentifier(word)
+		for i, part := range parts {
+			terms = appendFocusTerm(terms, part)
+			if i > 0 {
+				terms = appendFocusTerm(terms, parts[i-1]+part)
+			}
+		}
+		if len(parts) > 2 {
+			terms = appendFocusTerm(terms, strings.Join(parts, ""))
+		}
+	}
+	return terms
+}
+
+func appendFocusTerm(terms []string, term string) []string {
+	if len(term) < 2 {
+		return terms
+	}
+	if _, ok := focusStopWords[term]; ok {
+		return terms
+	}
+	// A plural "s" is dropped so that "files" matches "file".
+	if len(term) > 3 && strings.HasSuffix(term, "s") && !strings.HasSuffix(term, "ss") {
+		term = term[:len(term)-1]
+	}
+	return append(terms, term)
+}
+
+// splitIdentifier splits a word into lower-case parts at underscores, digit
+// boundaries and camelCase humps; "parseHTTPRequest2" gives parse, http,
+// request and 2.
+func splitIdentifier(word string) []string {
+	var parts []string
+	runes := []rune(word)
+	start := 0
+	flush := func(end int) {
+		if end > start {
+			parts = append(parts, strings.ToLower(string(runes[start:end])))
+		}
+		start = end
+	}
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == '_' {
+			flush(i)
+			start = i + 1
+			continue
+		}
+		if i == start {
+			continue
+		}
+		prev := runes[i-1]
+		switch {
+		case unicode.IsDigit(r) != unicode.IsDigit(prev):
+			flush(i)
+		case unicode.IsUpper(r) && unicode.IsLower(prev):
+			flush(i)
+		case unicode.IsUpper(r) && unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1]):
+			flush(i)
+		}
+	}
+	flush(len(runes))
+	return parts
+}
+
+// focusDocument is the bag of terms of one candidate file.
+type focusDocument struct {
+	terms  map[string]int
+	length int
+}
+
+func newFocusDocument(relPath, text string) focusDocument {
+	doc := focusDocument{terms: make(map[string]int)}
+	for _, term := range focusTerms(relPath) {
+		doc.terms[term] += focusPathBoost
+		doc.length += focusPathBoost
+	}
+	for _, term := range focusTerms(text) {
+		doc.terms[term]++
+		doc.length++
+	}
+	return doc
+}
+
+// bm25Scores scores each document against the query terms with Okapi BM25.
+func bm25Scores(query []string, docs []focusDocument) []float64 {
+	scores := make([]float64, len(docs))
+	if len(docs) == 0 {
+		return scores
+	}
+	totalLength := 0
+	for _, doc := range docs {
+		totalLength += doc.length
+	}
+	avgLength := math.Max(float64(totalLength)/float64(len(docs)), 1)
+
+	seen := make(map[string]struct{})
+	for _, term := range query {
+		if _, dup := seen[term]; dup {
+			continue
+		}
+		seen[term] = struct{}{}
+		containing := 0
+		for _, doc := range docs {
+			if doc.terms[term] > 0 {
+				containing++
+			}
+		}
+		if containing == 0 {
+			continue
+		}
+		idf := math.Log(1 + (float64(len(docs))-float64(containing)+0.5)/(float64(containing)+0.5))
+		for i, doc := range docs {
+			tf := float64(doc.terms[term])
+			if tf == 0 {
+				continue
+			}
+			norm := bm25K1 * (1 - bm25B + bm25B*float64(doc.length)/avgLength)
+			scores[i] += idf * tf * (bm25K1 + 1) / (tf + norm)
+		}
+	}
+	return scores
+}
+
+// focusCandidates keeps the FocusTop candidates most relevant to FocusQuery,
+// in walk order. Files that match no query term are dropped even when fewer
+// than FocusTop remain. Dropped files still appear in the project tree.
+func focusCandidates(rootPath string, candidates []sourceCandidate, debug bool) []sourceCandidate {
+	query := focusTerms(FocusQuery)
+	if len(query) == 0 {
+		PrintWarning(fmt.Sprintf("--focus '%s' has no search terms; collecting all files", FocusQuery), debug)
+		return candidates
+	}
+
+	docs := make([]focusDocument, len(candidates))
+	for i, candidate := range candidates {
+		relPath := relativeDisplayPath(rootPath, candidate.absPath, debug)
+		text := ""
+		// Files over the size limit are skipped later anyway.
+		if candidate.info.Size() <= MaxFileSizeBytes {
+			if content, err := os.ReadFile(candidate.absPath); err == nil {
+				if decoded, _, isText := decodeTextContent(content); isText {
+					text = decoded
+				}
+			}
+		}
+		docs[i] = newFocusDocument(relPath, text)
+	}
+	scores := bm25Scores(query, docs)
+
+	order := make([]int, len(candidates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return scores[order[a]] > scores[order[b]]
+	})
+	keep := make(map[int]struct{})
+	for _, i := range order {
+		if len(keep) == FocusTop || scores[i] <= 0 {
+			break
+		}
+		keep[i] = struct{}{}
+		PrintDebug(fmt.Sprintf("Focus score of '%s': %.2f", relativeDisplayPath(rootPath, candidates[i].absPath, debug), scores[i]), debug)
+	}
+
+	focused := make([]sourceCandidate, 0, len(keep))
+	for i, candidate := range candidates {
+		if _, ok := keep[i]; ok {
+			focused = append(focused, candidate)
+		}
+	}
+	PrintDebug(fmt.Sprintf("Focus '%s': collecting %d of %d file(s)", FocusQuery, len(focused), len(candidates)), debug)
+	return focused
+}
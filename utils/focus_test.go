@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFocusTerms(t *testing.T) {
+	assert.Equal(t, []string{"parse", "http", "parsehttp", "request", "httprequest", "parsehttprequest"}, focusTerms("parseHTTPRequest"))
+	assert.Equal(t, []string{"git", "ignore", "gitignore", "file", "ignorefile", "gitignorefile"}, focusTerms("git_ignore_files"))
+	assert.Equal(t, []string{"gitignore", "handled"}, focusTerms("How is .gitignore handled?"))
+	assert.Equal(t, []string{"class"}, focusTerms("class"))
+}
+
+func TestBM25Scores(t *testing.T) {
+	docs := []focusDocument{
+		newFocusDocument("gitignore.go", "func loadGitIgnore() { // parse .gitignore rules\n}"),
+		newFocusDocument("token.go", "func CountTokens(text string) int"),
+		newFocusDocument("file.go", "// skip entries matched by gitignore\nfunc ShouldSkipEntry() {}"),
+	}
+	scores := bm25Scores(focusTerms("how is gitignore handled"), docs)
+	assert.Greater(t, scores[0], scores[2])
+	assert.Greater(t, scores[2], 0.0)
+	assert.Equal(t, 0.0, scores[1])
+}
+
+func TestCollectSourceFiles_Focus(t *testing.T) {
+	origQuery, origTop := FocusQuery, FocusTop
+	t.Cleanup(func() { FocusQuery, FocusTop = origQuery, origTop })
+
+	tempDir := t.TempDir()
+	createTestFile(t, filepath.Join(tempDir, "gitignore.go"), "package demo\n\n// GitIgnoreMatcher matches .gitignore rules.\ntype GitIgnoreMatcher struct{}\n")
+	createTestFile(t, filepath.Join(tempDir, "walk.go"), "package demo\n\n// walk skips paths ignored by gitignore.\nfunc walk() {}\n")
+	createTestFile(t, filepath.Join(tempDir, "token.go"), "package demo\n\nfunc CountTokens(text string) int { return len(text) }\n")
+	createTestFile(t, filepath.Join(tempDir, "render.go"), "package demo\n\nfunc render() string { return \"\" }\n")
+
+	FocusQuery = "How is gitignore handled?"
+	FocusTop = 1
+	result := collectSourceFiles(tempDir, nil, nil, map[string]struct{}{}, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, false, nil)
+	sourceMarkdown := flattenSourceMarkdownByLanguage(result.sourceFileContents)
+	assert.Contains(t, sourceMarkdown, "### gitignore.go")
+	assert.NotContains(t, sourceMarkdown, "walk.go")
+
+	FocusTop = 10
+	result = collectSourceFiles(tempDir, nil, nil, map[string]struct{}{}, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, false, nil)
+	sourceMarkdown = flattenSourceMarkdownByLanguage(result.sourceFileContents)
+	require.Len(t, result.files, 2, "files without a query term are dropped")
+	assert.Contains(t, sourceMarkdown, "### walk.go")
+	assert.False(t, strings.Contains(sourceMarkdown, "token.go") || strings.Contains(sourceMarkdown, "render.go"))
+
+	FocusQuery = "how is it"
+	result = collectSourceFiles(tempDir, nil, nil, map[string]struct{}{}, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, false, nil)
+	assert.Len(t, result.files, 4, "a query of stop words collects everything")
+}
@@ -0,0 +1,274 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ActiveGitChanges limits source collection to files changed in a revision
+// range. It is nil when diff mode is not enabled.
+var ActiveGitChanges *GitChanges
+
+// GitChanges holds the files changed in a revision range of a local repository.
+type GitChanges struct {
+	// Range is the revision range passed to git diff (for example "main" or "main..feature").
+	Range string
+	// IncludePatch adds each file's unified diff next to its content.
+	IncludePatch bool
+
+	repoRoot string
+	// head is the revision a --diff range is read at; it is empty with
+	// --since, whose changes are in the working tree.
+	head   string
+	status map[string]string // absolute path -> git status letter
+}
+
+var errNotGitRepository = errors.New("not a git repository")
+
+// LoadGitChanges reads the changed files of the repository containing folderAbs.
+// since compares a ref against the working tree (untracked files count as added);
+// diffRange compares two refs such as "main..feature", and the changed files
+// are read at the second one. Files are still found by walking the working
+// tree, so a changed file that does not exist there is not collected; see
+// Missing. Exactly one must be set. Only the local repository is read;
+// nothing is fetched.
+func LoadGitChanges(folderAbs, since, diffRange string, includePatch bool) (*GitChanges, error) {
+	if since != "" && diffRange != "" {
+		return nil, fmt.Errorf("--since and --diff cannot be used together")
+	}
+	revRange, head := since, ""
+	if diffRange != "" {
+		var ok bool
+		if _, head, ok = strings.Cut(diffRange, ".."); !ok {
+			return nil, fmt.Errorf("invalid diff range '%s' (expected <base>..<head>)", diffRange)
+		}
+		// "base...head" names the same head, and an empty one means HEAD.
+		if head = strings.TrimPrefix(head, "."); head == "" {
+			head = "HEAD"
+		}
+		revRange = diffRange
+	}
+	if revRange == "" {
+		return nil, fmt.Errorf("a ref is required for diff mode")
+	}
+
+	topLevel, err := runGit(folderAbs, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errNotGitRepository, err)
+	}
+	repoRoot, err := normalizeAbsolutePath(strings.TrimSpace(topLevel))
+	if err != nil {
+		return nil, err
+	}
+	if resolved, err := filepath.EvalSymlinks(repoRoot); err == nil {
+		repoRoot = resolved
+	}
+
+	changes := &GitChanges{
+		Range:        revRange,
+		IncludePatch: includePatch,
+		repoRoot:     repoRoot,
+		head:         head,
+		status:       make(map[string]string),
+	}
+
+	// --diff-filter=d drops deletions: those files no longer exist to collect.
+	out, err := runGit(repoRoot, "diff", "--name-status", "-z", "--diff-filter=d", revRange, "--")
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	for i := 0; i < len(fields); i++ {
+		status := fields[i]
+		if status == "" {
+			continue
+		}
+		// Renames and copies carry both the old and the new path.
+		if strings.HasPrefix(status, "R") || strings.HasPrefix(status, "C") {
+			i++
+		}
+		i++
+		if i >= len(fields) {
+			break
+		}
+		changes.status[filepath.Join(repoRoot, filepath.FromSlash(fields[i]))] = status[:1]
+	}
+
+	if since != "" {
+		untracked, err := runGit(repoRoot, "ls-files", "--others", "--exclude-standard", "-z")
+		if err != nil {
+			return nil, err
+		}
+		for _, rel := range strings.Split(untracked, "\x00") {
+			if rel != "" {
+				changes.status[filepath.Join(repoRoot, filepath.FromSlash(rel))] = "A"
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+// Len returns the number of changed files.
+func (c *GitChanges) Len() int {
+	if c == nil {
+		return 0
+	}
+	return len(c.status)
+}
+
+// Missing returns the changed files, relative to the repository root, that
+// do not exist in the working tree, such as files added at the head of a
+// --diff range that is not checked out. The scan cannot reach them.
+func (c *GitChanges) Missing() []string {
+	if c == nil {
+		return nil
+	}
+	var missing []string
+	for absPath := range c.status {
+		if _, err := os.Lstat(absPath); err != nil {
+			if rel, err := c.relPath(absPath); err == nil {
+				missing = append(missing, rel)
+			}
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// Contains reports whether absPath is one of the changed files.
+func (c *GitChanges) Contains(absPath string) bool {
+	_, ok := c.Status(absPath)
+	return ok
+}
+
+// Status returns the git status letter (A, M, R, C, T) for a changed file.
+func (c *GitChanges) Status(absPath string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	status, ok := c.status[c.repoPath(absPath)]
+	return status, ok
+}
+
+// StatusName describes how absPath changed ("added", "modified", ...), or
+// returns "" when it is unchanged.
+func (c *GitChanges) StatusName(absPath string) string {
+	status, ok := c.Status(absPath)
+	if !ok {
+		return ""
+	}
+	switch status {
+	case "A":
+		return "added"
+	case "R":
+		return "renamed"
+	case "C":
+		return "copied"
+	case "T":
+		return "type changed"
+	default:
+		return "modified"
+	}
+}
+
+// Marker returns the tree annotation for absPath, or "" when it is unchanged.
+func (c *GitChanges) Marker(absPath string) string {
+	name := c.StatusName(absPath)
+	if name == "" {
+		return ""
+	}
+	return " (" + name + ")"
+}
+
+// ReadFile returns the content of a changed file at the head of a --diff
+// range. ok is false with --since and for unchanged files, which are read
+// from the working tree.
+func (c *GitChanges) ReadFile(absPath string) (content []byte, ok bool, err error) {
+	if c == nil || c.head == "" || !c.Contains(absPath) {
+		return nil, false, nil
+	}
+	rel, err := c.relPath(absPath)
+	if err != nil {
+		return nil, true, err
+	}
+	out, err := runGit(c.repoRoot, "show", c.head+":"+rel)
+	if err != nil {
+		return nil, true, err
+	}
+	return []byte(out), true, nil
+}
+
+// Patch returns the unified diff of a changed file within the range.
+func (c *GitChanges) Patch(absPath string) (string, error) {
+	status, ok := c.Status(absPath)
+	if !ok {
+		return "", nil
+	}
+	rel, err := c.relPath(absPath)
+	if err != nil {
+		return "", err
+	}
+
+	// Untracked files have no history; diff them against an empty file.
+	// Only --since counts them, as a --diff range has no working tree.
+	if status == "A" && c.head == "" {
+		if _, err := runGit(c.repoRoot, "ls-files", "--error-unmatch", "--", rel); err != nil {
+			out, diffErr := runGitAllowDiff(c.repoRoot, "diff", "--no-index", "--", "/dev/null", rel)
+			return strings.TrimRight(out, "\n"), diffErr
+		}
+	}
+
+	out, err := runGit(c.repoRoot, "diff", c.Range, "--", rel)
+	return strings.TrimRight(out, "\n"), err
+}
+
+// relPath returns the slash-separated path of absPath in the repository.
+func (c *GitChanges) relPath(absPath string) (string, error) {
+	rel, err := filepath.Rel(c.repoRoot, c.repoPath(absPath))
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// repoPath maps a path under the scanned folder onto the repository root,
+// resolving symlinks such as /tmp -> /private/tmp on macOS.
+func (c *GitChanges) repoPath(absPath string) string {
+	if pathWithinRoot(c.repoRoot, absPath) {
+		return absPath
+	}
+	if resolved, err := filepath.EvalSymlinks(absPath); err == nil {
+		return resolved
+	}
+	return absPath
+}
+
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// runGitAllowDiff runs git diff --no-index, which exits with status 1 when
+// the inputs differ.
+func runGitAllowDiff(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return string(out), nil
+	}
+	return string(out), err
+}
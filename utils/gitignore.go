@@ -210,6 +210,18 @@ func (m *GitIgnoreMatcher) MatchWithType(path string, isDir bool) bool {
 	return false
 }
 
+// forget drops the rules loaded from the .gitignore in dir, so the next
+// match below dir reads the file again.
+func (m *GitIgnoreMatcher) forget(dir string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	delete(m.tree, dir)
+	delete(m.loadedDirs, dir)
+	m.mu.Unlock()
+}
+
 func (m *GitIgnoreMatcher) loadGitIgnoreForDir(dir string) {
 	m.mu.RLock()
 	_, loaded := m.loadedDirs[dir]
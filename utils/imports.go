@@ -0,0 +1,266 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ecmaScriptExtensions are tried in order when a relative import names a
+// file without its extension or a directory with an index file.
+var ecmaScriptExtensions = []string{".ts", ".tsx", ".js", ".jsx", ".mjs", ".cjs", ".mts", ".cts"}
+
+// ecmaScriptImportPatterns capture the relative specifier of static imports,
+// re-exports, require() calls and dynamic import().
+var ecmaScriptImportPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\bfrom\s*['"](\.[^'"]*)['"]`),
+	regexp.MustCompile(`\bimport\s*['"](\.[^'"]*)['"]`),
+	regexp.MustCompile(`\b(?:require|import)\s*\(\s*['"](\.[^'"]*)['"]\s*\)`),
+}
+
+// ResolveEntryImports follows the imports of the entry files that stay
+// inside folderAbs, up to depth levels, and returns the absolute paths of
+// the entries and every file reached, sorted. Go files are resolved through
+// the module path in the nearest go.mod, and an entry or imported Go package
+// brings all of its non-test files. JavaScript and TypeScript follow relative
+// imports. Other languages contribute only the entry itself.
+func ResolveEntryImports(folderAbs string, entries []string, depth int, debug bool) ([]string, error) {
+	type node struct {
+		path  string
+		depth int
+	}
+	resolver := &importResolver{root: folderAbs, debug: debug, modules: make(map[string]goModule)}
+	seen := make(map[string]struct{})
+	var queue []node
+	visit := func(path string, level int) {
+		if _, ok := seen[path]; ok {
+			return
+		}
+		seen[path] = struct{}{}
+		queue = append(queue, node{path: path, depth: level})
+	}
+
+	for _, entry := range entries {
+		abs := entry
+		if !filepath.IsAbs(abs) {
+			abs = filepath.Join(folderAbs, entry)
+		}
+		abs = filepath.Clean(abs)
+		info, err := os.Stat(abs)
+		if err != nil {
+			return nil, fmt.Errorf("entry '%s' not found", entry)
+		}
+		if info.IsDir() {
+			return nil, fmt.Errorf("entry '%s' is a directory, not a file", entry)
+		}
+		visit(abs, 0)
+		// A Go file cannot be read without the rest of its package.
+		if strings.HasSuffix(abs, ".go") {
+			for _, sibling := range goPackageFiles(filepath.Dir(abs)) {
+				visit(sibling, 0)
+			}
+		}
+	}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current.depth >= depth {
+			continue
+		}
+		for _, imported := range resolver.imports(current.path) {
+			PrintDebug(fmt.Sprintf("Import '%s' -> '%s'", relativeDisplayPath(folderAbs, current.path, debug), relativeDisplayPath(folderAbs, imported, debug)), debug)
+			visit(imported, current.depth+1)
+		}
+	}
+
+	paths := make([]string, 0, len(seen))
+	for path := range seen {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// goModule is a go.mod found above a Go file.
+type goModule struct {
+	dir  string
+	path string
+}
+
+type importResolver struct {
+	root  string
+	debug bool
+	// modules caches the go.mod lookup by directory.
+	modules map[string]goModule
+}
+
+// imports returns the files inside the root that file imports directly.
+func (r *importResolver) imports(file string) []string {
+	switch {
+	case strings.HasSuffix(file, ".go"):
+		return r.goImports(file)
+	case isECMAScriptFile(file):
+		return r.ecmaScriptImports(file)
+	default:
+		return nil
+	}
+}
+
+func (r *importResolver) goImports(file string) []string {
+	parsed, err := parser.ParseFile(token.NewFileSet(), file, nil, parser.ImportsOnly)
+	if err != nil {
+		PrintWarning(fmt.Sprintf("Could not parse imports of '%s': %v", file, err), r.debug)
+		return nil
+	}
+	module := r.findGoModule(filepath.Dir(file))
+	if module.path == "" {
+		return nil
+	}
+
+	var files []string
+	for _, spec := range parsed.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		var dir string
+		switch {
+		case importPath == module.path:
+			dir = module.dir
+		case strings.HasPrefix(importPath, module.path+"/"):
+			dir = filepath.Join(module.dir, filepath.FromSlash(strings.TrimPrefix(importPath, module.path+"/")))
+		default:
+			continue
+		}
+		if !pathWithinRoot(r.root, dir) {
+			continue
+		}
+		files = append(files, goPackageFiles(dir)...)
+	}
+	return files
+}
+
+// findGoModule returns the go.mod closest above dir. The module may start
+// above the scanned folder, but only packages inside it are followed.
+func (r *importResolver) findGoModule(dir string) goModule {
+	if module, ok := r.modules[dir]; ok {
+		return module
+	}
+	var module goModule
+	if path := readGoModulePath(filepath.Join(dir, "go.mod")); path != "" {
+		module = goModule{dir: dir, path: path}
+	} else if parent := filepath.Dir(dir); parent != dir {
+		module = r.findGoModule(parent)
+	}
+	r.modules[dir] = module
+	return module
+}
+
+// readGoModulePath returns the module path declared in a go.mod file, or ""
+// when the file is missing or has no module directive.
+func readGoModulePath(goModPath string) string {
+	file, err := os.Open(goModPath)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "module" {
+			path, err := strconv.Unquote(fields[1])
+			if err != nil {
+				path = fields[1]
+			}
+			return path
+		}
+	}
+	return ""
+}
+
+// goPackageFiles lists the non-test Go files of the package in dir.
+func goPackageFiles(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		files = append(files, filepath.Join(dir, name))
+	}
+	return files
+}
+
+func isECMAScriptFile(path string) bool {
+	ext := filepath.Ext(path)
+	for _, candidate := range ecmaScriptExtensions {
+		if ext == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *importResolver) ecmaScriptImports(file string) []string {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		PrintWarning(fmt.Sprintf("Could not read imports of '%s': %v", file, err), r.debug)
+		return nil
+	}
+	// Comments are blanked so that commented-out imports are not followed.
+	comments, _ := scanSource(string(content), COMMENT_SYNTAX["Javascript"])
+	for _, span := range comments {
+		maskBytes(content, span.start, span.end)
+	}
+
+	var files []string
+	for _, pattern := range ecmaScriptImportPatterns {
+		for _, match := range pattern.FindAllSubmatch(content, -1) {
+			target := resolveRelativeImport(filepath.Dir(file), string(match[1]))
+			if target == "" {
+				PrintDebug(fmt.Sprintf("Could not resolve import '%s' in '%s'", match[1], relativeDisplayPath(r.root, file, r.debug)), r.debug)
+				continue
+			}
+			if pathWithinRoot(r.root, target) {
+				files = append(files, target)
+			}
+		}
+	}
+	return files
+}
+
+// resolveRelativeImport finds the file a relative specifier names: the file
+// itself, the file with one of ecmaScriptExtensions, a TypeScript source
+// imported by its compiled ".js" name, or an index file in a directory.
+func resolveRelativeImport(dir, specifier string) string {
+	base := filepath.Join(dir, filepath.FromSlash(specifier))
+	candidates := []string{base}
+	for _, ext := range ecmaScriptExtensions {
+		candidates = append(candidates, base+ext)
+	}
+	if ext := filepath.Ext(base); ext == ".js" || ext == ".jsx" || ext == ".mjs" || ext == ".cjs" {
+		stem := strings.TrimSuffix(base, ext)
+		candidates = append(candidates, stem+".ts", stem+".tsx", stem+".mts", stem+".cts")
+	}
+	for _, ext := range ecmaScriptExtensions {
+		candidates = append(candidates, filepath.Join(base, "index"+ext))
+	}
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate
+		}
+	}
+	return ""
+}
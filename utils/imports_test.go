@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func relPaths(t *testing.T, root string, paths []string) []string {
+	t.Helper()
+	rel := make([]string, 0, len(paths))
+	for _, path := range paths {
+		rel = append(rel, relativeDisplayPath(root, path, false))
+	}
+	return rel
+}
+
+func TestResolveEntryImports_Go(t *testing.T) {
+	root := t.TempDir()
+	createTestFile(t, filepath.Join(root, "go.mod"), "module example.com/app\n\ngo 1.22\n")
+	createTestFile(t, filepath.Join(root, "cmd", "app", "main.go"), "package main\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/app/internal/store\"\n)\n\nfunc main() { fmt.Println(store.Open()) }\n")
+	createTestFile(t, filepath.Join(root, "cmd", "app", "flags.go"), "package main\n")
+	createTestFile(t, filepath.Join(root, "cmd", "app", "main_test.go"), "package main\n")
+	createTestFile(t, filepath.Join(root, "internal", "store", "store.go"), "package store\n\nimport \"example.com/app/internal/db\"\n\nfunc Open() string { return db.Name }\n")
+	createTestFile(t, filepath.Join(root, "internal", "store", "cache.go"), "package store\n")
+	createTestFile(t, filepath.Join(root, "internal", "db", "db.go"), "package db\n\nconst Name = \"db\"\n")
+	createTestFile(t, filepath.Join(root, "internal", "unused", "unused.go"), "package unused\n")
+
+	direct, err := ResolveEntryImports(root, []string{"cmd/app/main.go"}, 1, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cmd/app/flags.go", "cmd/app/main.go", "internal/store/cache.go", "internal/store/store.go"}, relPaths(t, root, direct))
+
+	transitive, err := ResolveEntryImports(root, []string{"cmd/app/main.go"}, 5, false)
+	require.NoError(t, err)
+	assert.Contains(t, relPaths(t, root, transitive), "internal/db/db.go")
+	assert.NotContains(t, relPaths(t, root, transitive), "internal/unused/unused.go")
+
+	entryOnly, err := ResolveEntryImports(root, []string{"cmd/app/main.go"}, 0, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cmd/app/flags.go", "cmd/app/main.go"}, relPaths(t, root, entryOnly))
+}
+
+func TestResolveEntryImports_ECMAScript(t *testing.T) {
+	root := t.TempDir()
+	createTestFile(t, filepath.Join(root, "src", "index.ts"), "import { a } from './a';\nimport type { B } from \"./lib/b.js\";\nimport './styles.css';\n// import { gone } from './gone';\nconst c = require('./c');\nconst lazy = () => import('./widgets');\nimport React from 'react';\n")
+	createTestFile(t, filepath.Join(root, "src", "a.ts"), "export * from '../shared/util';\n")
+	createTestFile(t, filepath.Join(root, "src", "lib", "b.ts"), "export type B = string;\n")
+	createTestFile(t, filepath.Join(root, "src", "c.js"), "module.exports = {};\n")
+	createTestFile(t, filepath.Join(root, "src", "styles.css"), "body {}\n")
+	createTestFile(t, filepath.Join(root, "src", "gone.ts"), "export const gone = 1;\n")
+	createTestFile(t, filepath.Join(root, "src", "widgets", "index.tsx"), "export const W = 1;\n")
+	createTestFile(t, filepath.Join(root, "shared", "util.ts"), "export const util = 1;\n")
+
+	direct, err := ResolveEntryImports(root, []string{"src/index.ts"}, 1, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"src/a.ts", "src/c.js", "src/index.ts", "src/lib/b.ts", "src/styles.css", "src/widgets/index.tsx"}, relPaths(t, root, direct))
+
+	transitive, err := ResolveEntryImports(root, []string{"src/index.ts"}, 2, false)
+	require.NoError(t, err)
+	assert.Contains(t, relPaths(t, root, transitive), "shared/util.ts")
+}
+
+func TestResolveEntryImports_InvalidEntry(t *testing.T) {
+	root := t.TempDir()
+	_, err := ResolveEntryImports(root, []string{"missing.go"}, 1, false)
+	assert.ErrorContains(t, err, "entry 'missing.go' not found")
+
+	createTestFile(t, filepath.Join(root, "pkg", "a.go"), "package pkg\n")
+	_, err = ResolveEntryImports(root, []string{"pkg"}, 1, false)
+	assert.ErrorContains(t, err, "is a directory")
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Jobs is how many source files are read and prepared at once. Zero or less
+// uses the number of CPUs.
+var Jobs = 0
+
+// prepareWindowPerJob bounds how many prepared files per worker may wait for
+// their turn to be committed, which bounds memory on large trees.
+const prepareWindowPerJob = 4
+
+func jobCount() int {
+	if Jobs > 0 {
+		return Jobs
+	}
+	return runtime.NumCPU()
+}
+
+// prepareSourceFilesInOrder prepares candidates on a pool of jobCount()
+// workers and calls commit with each result in the order of candidates, so
+// budgets and output are the same as when files are read one by one.
+func prepareSourceFilesInOrder(rootPath string, candidates []sourceCandidate, debug bool, commit func(sourceCandidate, preparedSource)) {
+	if len(candidates) == 0 {
+		return
+	}
+	workers := jobCount()
+	if workers > len(candidates) {
+		workers = len(candidates)
+	}
+	window := workers * prepareWindowPerJob
+
+	// Candidate i is handed out only after candidate i-window was committed,
+	// so slot i%window is free by then.
+	slots := make([]chan preparedSource, window)
+	for i := range slots {
+		slots[i] = make(chan preparedSource, 1)
+	}
+	free := make(chan struct{}, window)
+	indexes := make(chan int)
+	go func() {
+		for i := range candidates {
+			free <- struct{}{}
+			indexes <- i
+		}
+		close(indexes)
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				slots[i%window] <- prepareSourceFile(rootPath, candidates[i], debug)
+			}
+		}()
+	}
+
+	for i, candidate := range candidates {
+		prepared := <-slots[i%window]
+		<-free
+		commit(candidate, prepared)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectSourceFiles_JobsDoNotChangeOutput(t *testing.T) {
+	origJobs, origTotal, origMaxFile := Jobs, TotalMaxFileSizeBytes, MaxFileSizeBytes
+	t.Cleanup(func() { Jobs, TotalMaxFileSizeBytes, MaxFileSizeBytes = origJobs, origTotal, origMaxFile })
+
+	tempDir := t.TempDir()
+	for i := 0; i < 60; i++ {
+		content := fmt.Sprintf("package p%d\n\n// %s\n", i, strings.Repeat("x", (i*37)%200))
+		createTestFile(t, filepath.Join(tempDir, fmt.Sprintf("d%d", i%7), fmt.Sprintf("f%02d.go", i)), content)
+	}
+	createTestFile(t, filepath.Join(tempDir, "big.go"), strings.Repeat("y", 3000))
+	MaxFileSizeBytes = 2048
+	TotalMaxFileSizeBytes = 4000
+
+	collect := func(jobs int) (*scanResult, string) {
+		Jobs = jobs
+		result := collectSourceFiles(tempDir, nil, nil, map[string]struct{}{}, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, false, nil)
+		return result, buildMarkdownOutput("## Project Structure", map[string][]string{}, result, true)
+	}
+	sequential, expected := collect(1)
+	require.True(t, sequential.limitHit)
+	require.NotEmpty(t, sequential.omittedFiles)
+	require.Len(t, sequential.skippedFiles, 1)
+	assert.LessOrEqual(t, sequential.totalFileSize, TotalMaxFileSizeBytes)
+
+	for _, jobs := range []int{2, 8, 64} {
+		parallel, actual := collect(jobs)
+		assert.Equal(t, expected, actual, "jobs=%d", jobs)
+		assert.Equal(t, sequential.omittedFiles, parallel.omittedFiles, "jobs=%d", jobs)
+		assert.Equal(t, sequential.totalFileSize, parallel.totalFileSize, "jobs=%d", jobs)
+	}
+}
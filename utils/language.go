@@ -1,11 +1,86 @@
 package utils
 
 import (
+	"fmt"
+	"io"
+	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
 )
 
+// languageSniffBytes is how much of a file is read to resolve its language.
+const languageSniffBytes = 4096
+
+// ambiguousExtensionPreference orders the candidates of extensions shared by
+// several languages. The first candidate is used when nothing else decides.
+var ambiguousExtensionPreference = map[string][]string{
+	".h":  {"C", "C++", "Objective-C"},
+	".m":  {"Objective-C", "MATLAB", "Octave"},
+	".pl": {"Perl", "Prolog"},
+}
+
+// extensionLanguages maps a lower-cased extension or file name to its
+// candidate languages, most preferred first. It is built from EXTENSIONS once
+// so that lookups never depend on map iteration order.
+var extensionLanguages = buildExtensionIndex(EXTENSIONS)
+
+func buildExtensionIndex(extensions map[string][]string) map[string][]string {
+	languages := make([]string, 0, len(extensions))
+	for lang := range extensions {
+		languages = append(languages, lang)
+	}
+	sort.Strings(languages)
+
+	index := make(map[string][]string)
+	for _, lang := range languages {
+		seen := make(map[string]struct{})
+		for _, ext := range extensions[lang] {
+			key := strings.ToLower(ext)
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			index[key] = append(index[key], lang)
+		}
+	}
+
+	for ext, candidates := range index {
+		if len(candidates) < 2 {
+			continue
+		}
+		preference := ambiguousExtensionPreference[ext]
+		rank := func(lang string) int {
+			for i, preferred := range preference {
+				if preferred == lang {
+					return i
+				}
+			}
+			return len(preference)
+		}
+		// Candidates are already alphabetical, which breaks ties between
+		// languages missing from the preference list.
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return rank(candidates[i]) < rank(candidates[j])
+		})
+	}
+	return index
+}
+
+// extensionKey returns the index key for a file name or extension.
+func extensionKey(fileNameOrExtension string) string {
+	ext := strings.ToLower(filepath.Ext(fileNameOrExtension))
+	if ext == "" {
+		ext = strings.ToLower(fileNameOrExtension)
+	}
+	return ext
+}
+
 // GetLanguageByExtension detects the language from a file name or extension.
+// Ambiguous extensions such as .h, .m and .pl always resolve to the same
+// preferred language; DetectLanguage also looks at the file to pick one.
 func GetLanguageByExtension(fileNameOrExtension string) string {
 	if filepath.Base(fileNameOrExtension) == "Dockerfile" {
 		return "Dockerfile"
@@ -15,17 +90,211 @@ func GetLanguageByExtension(fileNameOrExtension string) string {
 	if strings.HasSuffix(lowerName, ".blade.php") {
 		return "HTML"
 	}
-	ext := strings.ToLower(filepath.Ext(fileNameOrExtension))
-	if ext == "" {
-		ext = strings.ToLower(fileNameOrExtension)
+	if candidates := extensionLanguages[extensionKey(fileNameOrExtension)]; len(candidates) > 0 {
+		return candidates[0]
 	}
+	return ""
+}
 
-	for lang, extensions := range EXTENSIONS {
-		for _, e := range extensions {
-			if ext == e {
-				return lang
-			}
+// languageHeuristic picks Language for an ambiguous extension when Pattern
+// matches the start of the file.
+type languageHeuristic struct {
+	Language string
+	Pattern  *regexp.Regexp
+}
+
+// languageHeuristics are tried in order; the first match wins.
+var languageHeuristics = map[string][]languageHeuristic{
+	".h": {
+		{Language: "Objective-C", Pattern: regexp.MustCompile(`(?m)^\s*(@(interface|protocol|property|end|class)\b|#import\b)`)},
+		{Language: "C++", Pattern: regexp.MustCompile(`(?m)^\s*(class\s+\w+|namespace\b|template\s*<|using\s+namespace\b|#include\s*<(iostream|string|vector|memory|map|utility|algorithm)>)|std::`)},
+	},
+	".m": {
+		{Language: "Objective-C", Pattern: regexp.MustCompile(`(?m)^\s*(@(interface|implementation|protocol|property|end|import)\b|#import\b|#include\b)`)},
+		{Language: "Octave", Pattern: regexp.MustCompile(`(?m)^\s*(endfunction|endif|endwhile|endfor|end_try_catch|end_unwind_protect|unwind_protect)\b`)},
+		{Language: "MATLAB", Pattern: regexp.MustCompile(`(?m)^\s*(%|function\b|classdef\b|end\s*$)`)},
+	},
+	".pl": {
+		{Language: "Perl", Pattern: regexp.MustCompile(`(?m)^\s*(use\s+(strict|warnings)\b|my\s+[$@%]|sub\s+\w+\s*\{|package\s+[\w:]+;)|^#!.*\bperl`)},
+		{Language: "Prolog", Pattern: regexp.MustCompile(`(?m)^\s*:-|^\s*[a-z]\w*(\(.*\))?\s*:-`)},
+	},
+}
+
+// languageDetector resolves ambiguous extensions by file content, by the
+// files next to them and by the languages detected for the project.
+type languageDetector struct {
+	// projectLanguages holds the primary languages and the fallback
+	// extension counts from detectProjectLanguages.
+	projectLanguages map[string]int
+
+	mu sync.Mutex
+	// siblingLanguages caches, per directory, how many files have an
+	// extension that belongs to exactly one language.
+	siblingLanguages map[string]map[string]int
+}
+
+// activeLanguageDetector is replaced by ProcessSourceFiles once the project
+// languages are known.
+var activeLanguageDetector = newLanguageDetector(nil, nil)
+
+func newLanguageDetector(primaryLangs []string, fallbackLangs map[string]int) *languageDetector {
+	projectLanguages := make(map[string]int)
+	for lang, count := range fallbackLangs {
+		projectLanguages[lang] = count
+	}
+	// A signature file outweighs any number of matching extensions.
+	for _, lang := range primaryLangs {
+		projectLanguages[lang] = int(^uint(0) >> 1)
+	}
+	return &languageDetector{
+		projectLanguages: projectLanguages,
+		siblingLanguages: make(map[string]map[string]int),
+	}
+}
+
+// DetectLanguage returns the language of the file at path. Unambiguous names
+// are classified like GetLanguageByExtension. For ambiguous extensions the
+// start of the file is checked for telltale keywords, then the extensions of
+// the files in the same directory, then the languages detected for the
+// project; the preferred language is the last resort. Files whose name has
+// no known language, such as bin/deploy, are classified by their shebang or
+// a Vim/Emacs modeline.
+func DetectLanguage(path string) string {
+	return activeLanguageDetector.detect(path)
+}
+
+func (d *languageDetector) detect(path string) string {
+	return d.detectWithHead(path, newFileHead(path))
+}
+
+// detectWithHead is detect with the start of the file read through file,
+// so that callers can check the same read for binary data.
+func (d *languageDetector) detectWithHead(path string, file *fileHead) string {
+	name := filepath.Base(path)
+	language := GetLanguageByExtension(name)
+	if language == "" {
+		return file.language()
+	}
+	ext := extensionKey(name)
+	candidates := extensionLanguages[ext]
+	if len(candidates) < 2 || language != candidates[0] {
+		return language
+	}
+
+	if lang := file.language(); lang != "" {
+		return lang
+	}
+	if lang := pickMostCommon(candidates, d.siblings(filepath.Dir(path))); lang != "" {
+		return lang
+	}
+	if lang := pickMostCommon(candidates, d.projectLanguages); lang != "" {
+		return lang
+	}
+	return language
+}
+
+// siblings counts the files in dir by the language of their unambiguous
+// extension.
+func (d *languageDetector) siblings(dir string) map[string]int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if counts, ok := d.siblingLanguages[dir]; ok {
+		return counts
+	}
+
+	counts := make(map[string]int)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		PrintWarning(fmt.Sprintf("Could not list directory '%s' for language detection: %v", dir, err), false)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if candidates := extensionLanguages[extensionKey(entry.Name())]; len(candidates) == 1 {
+			counts[candidates[0]]++
 		}
 	}
-	return ""
+	d.siblingLanguages[dir] = counts
+	return counts
+}
+
+// pickMostCommon returns the candidate with the highest count, or "" when no
+// candidate is counted or the best ones are tied.
+func pickMostCommon(candidates []string, counts map[string]int) string {
+	best, bestCount, tied := "", 0, false
+	for _, lang := range candidates {
+		count := counts[lang]
+		switch {
+		case count > bestCount:
+			best, bestCount, tied = lang, count, false
+		case count == bestCount && count > 0:
+			tied = true
+		}
+	}
+	if tied {
+		return ""
+	}
+	return best
+}
+
+// readFileHead reads at most n bytes from the start of the file at path.
+func readFileHead(path string, n int) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, n)
+	read, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return buf[:read], nil
+}
+
+// OnlyLanguages limits collection to these languages when it is not empty.
+// Keys are lower-cased language names as listed in EXTENSIONS.
+var OnlyLanguages map[string]struct{}
+
+// SkipLanguages excludes these languages from collection.
+var SkipLanguages map[string]struct{}
+
+// ParseLanguageFilter validates language names for --only-lang and
+// --skip-lang, case-insensitively, and returns them as a lookup set.
+func ParseLanguageFilter(names []string) (map[string]struct{}, error) {
+	known := map[string]struct{}{"dockerfile": {}, "text": {}}
+	for lang := range EXTENSIONS {
+		known[strings.ToLower(lang)] = struct{}{}
+	}
+
+	filter := make(map[string]struct{})
+	for _, name := range names {
+		key := strings.ToLower(strings.TrimSpace(name))
+		if key == "" {
+			continue
+		}
+		if _, ok := known[key]; !ok {
+			return nil, fmt.Errorf("unknown language '%s' (use names such as Go, Python, Typescript, SQL)", name)
+		}
+		filter[key] = struct{}{}
+	}
+	return filter, nil
+}
+
+// IsLanguageAllowed reports whether files of language pass OnlyLanguages and
+// SkipLanguages. Files without a detected language are never filtered here.
+func IsLanguageAllowed(language string) bool {
+	if language == "" {
+		return true
+	}
+	key := strings.ToLower(language)
+	if len(OnlyLanguages) > 0 {
+		if _, ok := OnlyLanguages[key]; !ok {
+			return false
+		}
+	}
+	_, skipped := SkipLanguages[key]
+	return !skipped
 }
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -29,6 +31,10 @@ func TestGetLanguageByExtension(t *testing.T) {
 		{name: "JSP", fileName: "view.jsp", expected: "HTML"},
 		{name: "Bats file", fileName: "e2e_basic_flow.bats", expected: "Bash"},
 		{name: "Windows batch file", fileName: "install.bat", expected: "Batch"},
+		{name: "Jenkinsfile", fileName: "Jenkinsfile", expected: "Groovy"},
+		{name: "Rakefile", fileName: "Rakefile", expected: "Ruby"},
+		{name: "Justfile", fileName: "Justfile", expected: "Just"},
+		{name: "Procfile", fileName: "Procfile", expected: "Procfile"},
 	}
 
 	for _, tt := range tests {
@@ -40,3 +46,144 @@ func TestGetLanguageByExtension(t *testing.T) {
 		})
 	}
 }
+
+func withLanguageFilter(t *testing.T, only, skip []string) {
+	t.Helper()
+	origOnly, origSkip := OnlyLanguages, SkipLanguages
+	t.Cleanup(func() { OnlyLanguages, SkipLanguages = origOnly, origSkip })
+
+	var err error
+	OnlyLanguages, err = ParseLanguageFilter(only)
+	if err != nil {
+		t.Fatalf("ParseLanguageFilter(%v): %v", only, err)
+	}
+	SkipLanguages, err = ParseLanguageFilter(skip)
+	if err != nil {
+		t.Fatalf("ParseLanguageFilter(%v): %v", skip, err)
+	}
+}
+
+func TestParseLanguageFilter(t *testing.T) {
+	filter, err := ParseLanguageFilter([]string{"go", " SQL ", "C++", "dockerfile", ""})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, key := range []string{"go", "sql", "c++", "dockerfile"} {
+		if _, ok := filter[key]; !ok {
+			t.Errorf("expected %q in filter %v", key, filter)
+		}
+	}
+	if len(filter) != 4 {
+		t.Errorf("expected 4 languages, got %v", filter)
+	}
+
+	if _, err := ParseLanguageFilter([]string{"golang"}); err == nil {
+		t.Error("expected an error for an unknown language")
+	}
+}
+
+func TestIsLanguageAllowed(t *testing.T) {
+	tests := []struct {
+		name     string
+		only     []string
+		skip     []string
+		language string
+		expected bool
+	}{
+		{name: "No filter", language: "Go", expected: true},
+		{name: "Only matches case-insensitively", only: []string{"go", "sql"}, language: "Go", expected: true},
+		{name: "Only rejects others", only: []string{"Go", "SQL"}, language: "Python", expected: false},
+		{name: "Skip rejects", skip: []string{"HTML"}, language: "HTML", expected: false},
+		{name: "Skip keeps others", skip: []string{"HTML"}, language: "CSS", expected: true},
+		{name: "Skip wins over only", only: []string{"Go"}, skip: []string{"go"}, language: "Go", expected: false},
+		{name: "No language is never filtered", only: []string{"Go"}, language: "", expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withLanguageFilter(t, tt.only, tt.skip)
+			if actual := IsLanguageAllowed(tt.language); actual != tt.expected {
+				t.Errorf("IsLanguageAllowed(%q) with only=%v skip=%v: expected %v, got %v", tt.language, tt.only, tt.skip, tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestProcessSourceFiles_LanguageFilter(t *testing.T) {
+	withLanguageFilter(t, []string{"Go", "SQL"}, nil)
+
+	tempDir := t.TempDir()
+	createTestFile(t, filepath.Join(tempDir, "main.go"), "package main\n")
+	createTestFile(t, filepath.Join(tempDir, "db", "schema.sql"), "SELECT 1;\n")
+	createTestFile(t, filepath.Join(tempDir, "tools", "gen.py"), "print(1)\n")
+	createTestFile(t, filepath.Join(tempDir, "LICENSE"), "MIT\n")
+
+	output := ProcessSourceFiles(tempDir, 5, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, false, nil)
+
+	for _, want := range []string{"### main.go", "### db/schema.sql", "schema.sql", "LICENSE", "tools"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "gen.py") {
+		t.Errorf("expected Python files to be filtered from the tree and the sources:\n%s", output)
+	}
+}
+
+func TestGetLanguageByExtension_AmbiguousIsDeterministic(t *testing.T) {
+	expected := map[string]string{".h": "C", ".m": "Objective-C", ".pl": "Perl"}
+	for i := 0; i < 50; i++ {
+		for ext, want := range expected {
+			if got := GetLanguageByExtension("file" + ext); got != want {
+				t.Fatalf("GetLanguageByExtension(%q) on run %d: expected %q, got %q", "file"+ext, i, want, got)
+			}
+		}
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		path     string
+		expected string
+	}{
+		{name: "C++ header by content", files: map[string]string{"a/util.h": "namespace util {\nclass Thing;\n}\n"}, path: "a/util.h", expected: "C++"},
+		{name: "Objective-C header by content", files: map[string]string{"a/View.h": "#import <UIKit/UIKit.h>\n@interface View : UIView\n@end\n"}, path: "a/View.h", expected: "Objective-C"},
+		{name: "C++ header by sibling", files: map[string]string{"a/util.h": "int add(int, int);\n", "a/util.cpp": "", "a/main.cc": ""}, path: "a/util.h", expected: "C++"},
+		{name: "C header by sibling", files: map[string]string{"a/util.h": "int add(int, int);\n", "a/util.c": ""}, path: "a/util.h", expected: "C"},
+		{name: "Plain header defaults to C", files: map[string]string{"a/util.h": "int add(int, int);\n"}, path: "a/util.h", expected: "C"},
+		{name: "MATLAB script", files: map[string]string{"m/solve.m": "% Solve the system\nfunction x = solve(A, b)\nx = A \\ b;\nend\n"}, path: "m/solve.m", expected: "MATLAB"},
+		{name: "Octave script", files: map[string]string{"m/solve.m": "function x = solve(A, b)\n  x = A \\ b;\nendfunction\n"}, path: "m/solve.m", expected: "Octave"},
+		{name: "Objective-C implementation", files: map[string]string{"m/App.m": "#import \"App.h\"\n@implementation App\n@end\n"}, path: "m/App.m", expected: "Objective-C"},
+		{name: "Perl script", files: map[string]string{"p/run.pl": "use strict;\nmy $x = 1;\n"}, path: "p/run.pl", expected: "Perl"},
+		{name: "Prolog program", files: map[string]string{"p/family.pl": "parent(tom, bob).\ngrandparent(X, Z) :- parent(X, Y), parent(Y, Z).\n"}, path: "p/family.pl", expected: "Prolog"},
+		{name: "Unambiguous extension", files: map[string]string{"main.go": "package main\n"}, path: "main.go", expected: "Go"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			for name, content := range tt.files {
+				createTestFile(t, filepath.Join(tempDir, name), content)
+			}
+			detector := newLanguageDetector(nil, nil)
+			if actual := detector.detect(filepath.Join(tempDir, tt.path)); actual != tt.expected {
+				t.Errorf("detect(%q): expected %q, got %q", tt.path, tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestDetectLanguage_ProjectLanguages(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "include", "api.h")
+	createTestFile(t, path, "int api_version(void);\n")
+
+	if actual := newLanguageDetector(nil, map[string]int{"C++": 12, "C": 3}).detect(path); actual != "C++" {
+		t.Errorf("expected the more common project language C++, got %q", actual)
+	}
+	if actual := newLanguageDetector([]string{"C"}, map[string]int{"C++": 12}).detect(path); actual != "C" {
+		t.Errorf("expected a signature language to win over extension counts, got %q", actual)
+	}
+}
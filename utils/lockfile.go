@@ -0,0 +1,714 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// lockedDependency is a direct dependency with the version its lockfile pins.
+type lockedDependency struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	Dev     bool   `json:"dev,omitempty"`
+}
+
+// lockfileSummary reduces a lockfile to the dependencies the project declares
+// itself. Locked is the number of packages the lockfile pins in total.
+type lockfileSummary struct {
+	Direct []lockedDependency
+	Locked int
+}
+
+// lockfileSummarizer summarizes a lockfile. dir is the lockfile's directory,
+// where the manifest naming the direct dependencies is looked up.
+type lockfileSummarizer func(dir string, content []byte) (lockfileSummary, error)
+
+// lockfileSummarizers maps lockfile names to their summarizers. When the
+// manifest next to a lockfile is missing, every locked package is listed.
+var lockfileSummarizers = map[string]lockfileSummarizer{
+	"go.sum":              summarizeGoSum,
+	"package-lock.json":   summarizePackageLock,
+	"npm-shrinkwrap.json": summarizePackageLock,
+	"yarn.lock":           summarizeYarnLock,
+	"pnpm-lock.yaml":      summarizePnpmLock,
+	"Cargo.lock":          summarizeCargoLock,
+	"poetry.lock":         summarizePoetryLock,
+	"Pipfile.lock":        summarizePipfileLock,
+	"Gemfile.lock":        summarizeGemfileLock,
+	"composer.lock":       summarizeComposerLock,
+}
+
+// summarizeGoSum lists the requirements of go.mod that are not marked
+// "// indirect". go.sum itself does not tell direct and indirect modules apart.
+func summarizeGoSum(dir string, content []byte) (lockfileSummary, error) {
+	versions := make(map[string]string)
+	var all []lockedDependency
+	for _, line := range lockfileLines(content) {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || strings.HasSuffix(fields[1], "/go.mod") {
+			continue
+		}
+		if _, ok := versions[fields[0]]; !ok {
+			all = append(all, lockedDependency{Name: fields[0], Version: fields[1]})
+		}
+		versions[fields[0]] = fields[1]
+	}
+
+	direct := all
+	if gomod, ok := readSiblingFile(dir, "go.mod"); ok {
+		direct = goModDirectRequirements(gomod)
+	}
+	return lockfileSummary{Direct: resolveDirectDependencies(direct, versions, nil), Locked: len(versions)}, nil
+}
+
+// goModDirectRequirements parses the require directives of a go.mod file.
+func goModDirectRequirements(gomod []byte) []lockedDependency {
+	direct := []lockedDependency{}
+	inBlock := false
+	for _, raw := range lockfileLines(gomod) {
+		line := strings.TrimSpace(raw)
+		indirect := strings.Contains(line, "// indirect")
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		switch {
+		case inBlock:
+			if line == ")" {
+				inBlock = false
+				continue
+			}
+		case strings.HasPrefix(line, "require") && strings.HasSuffix(line, "("):
+			inBlock = true
+			continue
+		case strings.HasPrefix(line, "require "):
+			line = strings.TrimSpace(strings.TrimPrefix(line, "require "))
+		default:
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 || indirect {
+			continue
+		}
+		direct = append(direct, lockedDependency{Name: strings.Trim(fields[0], `"`), Version: fields[1]})
+	}
+	return direct
+}
+
+// packageJSONManifest holds the dependency maps of a package.json file.
+type packageJSONManifest struct {
+	Dependencies         map[string]string `json:"dependencies"`
+	DevDependencies      map[string]string `json:"devDependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+}
+
+func readPackageJSON(dir string) (packageJSONManifest, bool) {
+	var manifest packageJSONManifest
+	content, ok := readSiblingFile(dir, "package.json")
+	if !ok || json.Unmarshal(content, &manifest) != nil {
+		return manifest, false
+	}
+	return manifest, true
+}
+
+// forEachDeclared calls fn with every declared dependency and its version range.
+func (m packageJSONManifest) forEachDeclared(fn func(name, spec string, dev bool)) {
+	for name, spec := range m.Dependencies {
+		fn(name, spec, false)
+	}
+	for name, spec := range m.OptionalDependencies {
+		fn(name, spec, false)
+	}
+	for name, spec := range m.DevDependencies {
+		fn(name, spec, true)
+	}
+}
+
+// summarizePackageLock handles npm lockfiles. Version 2 and later record the
+// direct dependencies on the root package; version 1 needs package.json.
+func summarizePackageLock(dir string, content []byte) (lockfileSummary, error) {
+	var lock struct {
+		Packages map[string]struct {
+			Version              string            `json:"version"`
+			Dependencies         map[string]string `json:"dependencies"`
+			DevDependencies      map[string]string `json:"devDependencies"`
+			OptionalDependencies map[string]string `json:"optionalDependencies"`
+		} `json:"packages"`
+		Dependencies map[string]struct {
+			Version string `json:"version"`
+			Dev     bool   `json:"dev"`
+		} `json:"dependencies"`
+	}
+	if err := json.Unmarshal(content, &lock); err != nil {
+		return lockfileSummary{}, err
+	}
+
+	versions := make(map[string]string)
+	var direct []lockedDependency
+	if root, ok := lock.Packages[""]; ok {
+		locked := 0
+		for key, pkg := range lock.Packages {
+			i := strings.LastIndex(key, "node_modules/")
+			if i < 0 {
+				continue
+			}
+			locked++
+			// Hoisted packages win over copies nested under another package.
+			name := key[i+len("node_modules/"):]
+			if _, seen := versions[name]; !seen || i == 0 {
+				versions[name] = pkg.Version
+			}
+		}
+		packageJSONManifest{
+			Dependencies:         root.Dependencies,
+			DevDependencies:      root.DevDependencies,
+			OptionalDependencies: root.OptionalDependencies,
+		}.forEachDeclared(func(name, _ string, dev bool) {
+			direct = append(direct, lockedDependency{Name: name, Dev: dev})
+		})
+		return lockfileSummary{Direct: resolveDirectDependencies(direct, versions, nil), Locked: locked}, nil
+	}
+
+	for name, pkg := range lock.Dependencies {
+		versions[name] = pkg.Version
+		direct = append(direct, lockedDependency{Name: name, Version: pkg.Version, Dev: pkg.Dev})
+	}
+	if manifest, ok := readPackageJSON(dir); ok {
+		direct = nil
+		manifest.forEachDeclared(func(name, _ string, dev bool) {
+			direct = append(direct, lockedDependency{Name: name, Dev: dev})
+		})
+	}
+	return lockfileSummary{Direct: resolveDirectDependencies(direct, versions, nil), Locked: len(versions)}, nil
+}
+
+// summarizeYarnLock handles both classic and Berry yarn.lock files. Entries
+// are keyed by "name@range", so package.json ranges select the exact version.
+func summarizeYarnLock(dir string, content []byte) (lockfileSummary, error) {
+	bySpec := make(map[string]string)
+	byName := make(map[string]string)
+	locked := make(map[string]struct{})
+	var all []lockedDependency
+	var specs []string
+	for _, line := range lockfileLines(content) {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, " ") {
+			specs = nil
+			for _, spec := range strings.Split(strings.TrimSuffix(line, ":"), ",") {
+				spec = strings.Trim(strings.TrimSpace(spec), `"`)
+				if spec != "" && spec != "__metadata" {
+					specs = append(specs, spec)
+				}
+			}
+			continue
+		}
+		field := strings.TrimSpace(line)
+		if strings.HasPrefix(line, "    ") || !strings.HasPrefix(field, "version") {
+			continue
+		}
+		version := strings.TrimPrefix(field, "version")
+		if version == "" || (version[0] != ' ' && version[0] != ':') {
+			continue
+		}
+		version = strings.Trim(strings.TrimSpace(strings.TrimPrefix(version, ":")), `"`)
+		for _, spec := range specs {
+			name := yarnSpecName(spec)
+			bySpec[spec] = version
+			if _, seen := byName[name]; !seen {
+				byName[name] = version
+				all = append(all, lockedDependency{Name: name, Version: version})
+			}
+			locked[name+"@"+version] = struct{}{}
+		}
+	}
+
+	direct := all
+	if manifest, ok := readPackageJSON(dir); ok {
+		direct = nil
+		manifest.forEachDeclared(func(name, spec string, dev bool) {
+			version, found := bySpec[name+"@"+spec]
+			if !found {
+				version, found = bySpec[name+"@npm:"+spec]
+			}
+			if !found {
+				version = byName[name]
+			}
+			direct = append(direct, lockedDependency{Name: name, Version: version, Dev: dev})
+		})
+	}
+	return lockfileSummary{Direct: resolveDirectDependencies(direct, byName, nil), Locked: len(locked)}, nil
+}
+
+// yarnSpecName strips the range from a "name@range" spec, keeping the scope
+// of names such as "@babel/core".
+func yarnSpecName(spec string) string {
+	if i := strings.Index(spec[1:], "@"); i >= 0 {
+		return spec[:i+1]
+	}
+	return spec
+}
+
+type pnpmImporter struct {
+	Dependencies         map[string]yaml.Node `yaml:"dependencies"`
+	DevDependencies      map[string]yaml.Node `yaml:"devDependencies"`
+	OptionalDependencies map[string]yaml.Node `yaml:"optionalDependencies"`
+}
+
+// summarizePnpmLock reads the root importer, which lists the direct
+// dependencies with their resolved versions.
+func summarizePnpmLock(dir string, content []byte) (lockfileSummary, error) {
+	var lock struct {
+		pnpmImporter `yaml:",inline"`
+		Importers    map[string]pnpmImporter `yaml:"importers"`
+		Packages     map[string]yaml.Node    `yaml:"packages"`
+	}
+	if err := yaml.Unmarshal(content, &lock); err != nil {
+		return lockfileSummary{}, err
+	}
+
+	root := lock.pnpmImporter
+	if importer, ok := lock.Importers["."]; ok {
+		root = importer
+	}
+	var direct []lockedDependency
+	add := func(deps map[string]yaml.Node, dev bool) {
+		for name, node := range deps {
+			direct = append(direct, lockedDependency{Name: name, Version: pnpmVersion(node), Dev: dev})
+		}
+	}
+	add(root.Dependencies, false)
+	add(root.OptionalDependencies, false)
+	add(root.DevDependencies, true)
+	return lockfileSummary{Direct: resolveDirectDependencies(direct, nil, nil), Locked: len(lock.Packages)}, nil
+}
+
+// pnpmVersion reads a version that is either a plain scalar (lockfile v5) or
+// a {specifier, version} mapping (v6 and later), minus any peer suffix.
+func pnpmVersion(node yaml.Node) string {
+	version := node.Value
+	if node.Kind == yaml.MappingNode {
+		version = ""
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == "version" {
+				version = node.Content[i+1].Value
+			}
+		}
+	}
+	version, _, _ = strings.Cut(version, "(")
+	return version
+}
+
+// summarizeCargoLock needs no Cargo.toml: workspace members are the packages
+// without a source, and their dependency lists are the direct dependencies.
+func summarizeCargoLock(dir string, content []byte) (lockfileSummary, error) {
+	versions := make(map[string]string)
+	members := make(map[string]struct{})
+	var memberDeps []string
+	locked := 0
+	for _, table := range parseTOMLTables(content) {
+		if table.name != "package" {
+			continue
+		}
+		name := tomlString(table.values["name"])
+		if _, remote := table.values["source"]; !remote {
+			members[name] = struct{}{}
+			memberDeps = append(memberDeps, tomlStrings(table.values["dependencies"])...)
+			continue
+		}
+		locked++
+		if _, seen := versions[name]; !seen {
+			versions[name] = tomlString(table.values["version"])
+		}
+	}
+
+	var direct []lockedDependency
+	for _, dep := range memberDeps {
+		// Entries read "name", "name version" or "name version (source)".
+		fields := strings.Fields(dep)
+		if len(fields) == 0 {
+			continue
+		}
+		if _, member := members[fields[0]]; member {
+			continue
+		}
+		dependency := lockedDependency{Name: fields[0]}
+		if len(fields) > 1 {
+			dependency.Version = fields[1]
+		}
+		direct = append(direct, dependency)
+	}
+	return lockfileSummary{Direct: resolveDirectDependencies(direct, versions, nil), Locked: locked}, nil
+}
+
+// summarizePoetryLock takes the direct dependencies from pyproject.toml.
+func summarizePoetryLock(dir string, content []byte) (lockfileSummary, error) {
+	versions := make(map[string]string)
+	var all []lockedDependency
+	for _, table := range parseTOMLTables(content) {
+		if table.name != "package" {
+			continue
+		}
+		name := tomlString(table.values["name"])
+		version := tomlString(table.values["version"])
+		versions[normalizePythonName(name)] = version
+		all = append(all, lockedDependency{Name: name, Version: version, Dev: tomlString(table.values["category"]) == "dev"})
+	}
+
+	direct := all
+	if pyproject, ok := readSiblingFile(dir, "pyproject.toml"); ok {
+		direct = pyprojectDependencies(pyproject)
+	}
+	return lockfileSummary{Direct: resolveDirectDependencies(direct, versions, normalizePythonName), Locked: len(versions)}, nil
+}
+
+// pyprojectDependencies reads PEP 621 [project] dependencies, PEP 735
+// dependency groups, and Poetry dependency tables.
+func pyprojectDependencies(content []byte) []lockedDependency {
+	direct := []lockedDependency{}
+	for _, table := range parseTOMLTables(content) {
+		switch {
+		case table.name == "project":
+			direct = appendRequirements(direct, tomlStrings(table.values["dependencies"]), false)
+		case table.name == "dependency-groups":
+			for _, group := range table.values {
+				direct = appendRequirements(direct, tomlStrings(group), true)
+			}
+		case table.name == "tool.poetry.dependencies":
+			direct = appendTableKeys(direct, table, false)
+		case table.name == "tool.poetry.dev-dependencies":
+			direct = appendTableKeys(direct, table, true)
+		case strings.HasPrefix(table.name, "tool.poetry.group.") && strings.HasSuffix(table.name, ".dependencies"):
+			direct = appendTableKeys(direct, table, table.name != "tool.poetry.group.main.dependencies")
+		}
+	}
+	return direct
+}
+
+// summarizePipfileLock takes the direct dependencies from Pipfile.
+func summarizePipfileLock(dir string, content []byte) (lockfileSummary, error) {
+	type pipfilePackage struct {
+		Version string `json:"version"`
+	}
+	var lock struct {
+		Default map[string]pipfilePackage `json:"default"`
+		Develop map[string]pipfilePackage `json:"develop"`
+	}
+	if err := json.Unmarshal(content, &lock); err != nil {
+		return lockfileSummary{}, err
+	}
+
+	versions := make(map[string]string)
+	var all []lockedDependency
+	for _, group := range []struct {
+		packages map[string]pipfilePackage
+		dev      bool
+	}{{lock.Default, false}, {lock.Develop, true}} {
+		for name, pkg := range group.packages {
+			version := strings.TrimPrefix(pkg.Version, "==")
+			if _, seen := versions[normalizePythonName(name)]; !seen {
+				versions[normalizePythonName(name)] = version
+			}
+			all = append(all, lockedDependency{Name: name, Version: version, Dev: group.dev})
+		}
+	}
+
+	direct := all
+	if pipfile, ok := readSiblingFile(dir, "Pipfile"); ok {
+		direct = []lockedDependency{}
+		for _, table := range parseTOMLTables(pipfile) {
+			switch table.name {
+			case "packages":
+				direct = appendTableKeys(direct, table, false)
+			case "dev-packages":
+				direct = appendTableKeys(direct, table, true)
+			}
+		}
+	}
+	return lockfileSummary{Direct: resolveDirectDependencies(direct, versions, normalizePythonName), Locked: len(versions)}, nil
+}
+
+// summarizeGemfileLock reads the DEPENDENCIES section, which Bundler writes
+// from the Gemfile, and resolves versions from the GEM, GIT and PATH specs.
+func summarizeGemfileLock(dir string, content []byte) (lockfileSummary, error) {
+	versions := make(map[string]string)
+	var direct []lockedDependency
+	section := ""
+	for _, line := range lockfileLines(content) {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, " ") {
+			section = strings.TrimSpace(line)
+			continue
+		}
+		switch section {
+		case "DEPENDENCIES":
+			if !strings.HasPrefix(line, "   ") {
+				name := strings.Fields(line)[0]
+				direct = append(direct, lockedDependency{Name: strings.TrimSuffix(name, "!")})
+			}
+		case "GEM", "GIT", "PATH":
+			// "    rails (7.0.4)" pins a gem; deeper lines list its requirements.
+			if strings.HasPrefix(line, "    ") && !strings.HasPrefix(line, "     ") {
+				name, version, ok := strings.Cut(strings.TrimSpace(line), " ")
+				if _, seen := versions[name]; ok && !seen {
+					versions[name] = strings.Trim(version, "()")
+				}
+			}
+		}
+	}
+	return lockfileSummary{Direct: resolveDirectDependencies(direct, versions, nil), Locked: len(versions)}, nil
+}
+
+// summarizeComposerLock takes the direct dependencies from composer.json,
+// leaving out platform requirements such as "php" and "ext-json".
+func summarizeComposerLock(dir string, content []byte) (lockfileSummary, error) {
+	type composerPackage struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	var lock struct {
+		Packages    []composerPackage `json:"packages"`
+		PackagesDev []composerPackage `json:"packages-dev"`
+	}
+	if err := json.Unmarshal(content, &lock); err != nil {
+		return lockfileSummary{}, err
+	}
+
+	versions := make(map[string]string)
+	var all []lockedDependency
+	for _, pkg := range lock.Packages {
+		versions[pkg.Name] = pkg.Version
+		all = append(all, lockedDependency{Name: pkg.Name, Version: pkg.Version})
+	}
+	for _, pkg := range lock.PackagesDev {
+		versions[pkg.Name] = pkg.Version
+		all = append(all, lockedDependency{Name: pkg.Name, Version: pkg.Version, Dev: true})
+	}
+
+	direct := all
+	var manifest struct {
+		Require    map[string]string `json:"require"`
+		RequireDev map[string]string `json:"require-dev"`
+	}
+	if content, ok := readSiblingFile(dir, "composer.json"); ok && json.Unmarshal(content, &manifest) == nil {
+		direct = []lockedDependency{}
+		for _, group := range []struct {
+			require map[string]string
+			dev     bool
+		}{{manifest.Require, false}, {manifest.RequireDev, true}} {
+			for name := range group.require {
+				if strings.Contains(name, "/") {
+					direct = append(direct, lockedDependency{Name: name, Dev: group.dev})
+				}
+			}
+		}
+	}
+	return lockfileSummary{Direct: resolveDirectDependencies(direct, versions, nil), Locked: len(versions)}, nil
+}
+
+// resolveDirectDependencies fills in locked versions, sorts runtime
+// dependencies before development ones, and drops duplicates. key normalizes
+// names for ecosystems where they are case- or separator-insensitive.
+func resolveDirectDependencies(direct []lockedDependency, versions map[string]string, key func(string) string) []lockedDependency {
+	if key == nil {
+		key = func(name string) string { return name }
+	}
+	sorted := append([]lockedDependency(nil), direct...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Dev != sorted[j].Dev {
+			return !sorted[i].Dev
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	resolved := make([]lockedDependency, 0, len(sorted))
+	seen := make(map[string]struct{})
+	for _, dep := range sorted {
+		if _, dup := seen[key(dep.Name)]; dup {
+			continue
+		}
+		seen[key(dep.Name)] = struct{}{}
+		if dep.Version == "" {
+			dep.Version = versions[key(dep.Name)]
+		}
+		resolved = append(resolved, dep)
+	}
+	return resolved
+}
+
+var (
+	pythonNameSeparators = regexp.MustCompile(`[-_.]+`)
+	pep508Name           = regexp.MustCompile(`^\s*([A-Za-z0-9][A-Za-z0-9._-]*)`)
+)
+
+// normalizePythonName applies the PEP 503 name normalization.
+func normalizePythonName(name string) string {
+	return pythonNameSeparators.ReplaceAllString(strings.ToLower(name), "-")
+}
+
+// appendRequirements adds the names of PEP 508 requirement strings such as
+// "requests>=2.31; python_version > '3.8'".
+func appendRequirements(direct []lockedDependency, requirements []string, dev bool) []lockedDependency {
+	for _, requirement := range requirements {
+		if match := pep508Name.FindStringSubmatch(requirement); match != nil {
+			direct = append(direct, lockedDependency{Name: match[1], Dev: dev})
+		}
+	}
+	return direct
+}
+
+// appendTableKeys adds the keys of a dependency table, skipping the Python
+// version constraint Poetry keeps alongside the packages.
+func appendTableKeys(direct []lockedDependency, table tomlTable, dev bool) []lockedDependency {
+	for name := range table.values {
+		if name != "python" {
+			direct = append(direct, lockedDependency{Name: name, Dev: dev})
+		}
+	}
+	return direct
+}
+
+// readSiblingFile reads a manifest next to a lockfile.
+func readSiblingFile(dir, name string) ([]byte, bool) {
+	content, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return nil, false
+	}
+	return content, true
+}
+
+// lockfileLines splits content into lines without their line endings.
+func lockfileLines(content []byte) []string {
+	lines := strings.Split(string(content), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines
+}
+
+// tomlTable is one [table] or [[array of tables]] entry of a TOML document.
+// Values are kept raw; tomlString and tomlStrings interpret the small subset
+// of TOML that lockfiles and manifests use.
+type tomlTable struct {
+	name   string
+	values map[string]string
+}
+
+// parseTOMLTables splits a TOML document into its tables. Keys before the
+// first header belong to a table with an empty name.
+func parseTOMLTables(content []byte) []tomlTable {
+	tables := []tomlTable{{values: make(map[string]string)}}
+	lines := lockfileLines(content)
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			name := strings.TrimLeft(line, "[")
+			if end := strings.Index(name, "]"); end >= 0 {
+				name = name[:end]
+			}
+			tables = append(tables, tomlTable{name: strings.TrimSpace(name), values: make(map[string]string)})
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		// Arrays and multi-line strings continue on the following lines.
+		for i+1 < len(lines) && tomlValueOpen(value) {
+			i++
+			value += "\n" + lines[i]
+		}
+		tables[len(tables)-1].values[strings.Trim(strings.TrimSpace(key), `"'`)] = value
+	}
+	return tables
+}
+
+// tomlValueOpen reports whether a raw value continues on the next line.
+func tomlValueOpen(value string) bool {
+	for _, delim := range []string{`"""`, `'''`} {
+		if strings.HasPrefix(value, delim) {
+			return strings.Count(value, delim) < 2
+		}
+	}
+
+	depth := 0
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '"', '\'':
+			i = tomlStringEnd(value, i)
+		case '#':
+			for i < len(value) && value[i] != '\n' {
+				i++
+			}
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+		}
+	}
+	return depth > 0
+}
+
+// tomlString returns a raw value as a string, without quotes or comment.
+func tomlString(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if raw != "" && (raw[0] == '"' || raw[0] == '\'') {
+		return raw[1:tomlStringEnd(raw, 0)]
+	}
+	value, _, _ := strings.Cut(raw, "#")
+	return strings.TrimSpace(value)
+}
+
+// tomlStrings returns the string elements of a raw TOML array, skipping
+// strings nested in inline tables.
+func tomlStrings(raw string) []string {
+	var values []string
+	braces := 0
+	for i := 0; i < len(raw); i++ {
+		switch c := raw[i]; c {
+		case '{':
+			braces++
+		case '}':
+			braces--
+		case '#':
+			for i < len(raw) && raw[i] != '\n' {
+				i++
+			}
+		case '"', '\'':
+			end := tomlStringEnd(raw, i)
+			if braces == 0 {
+				values = append(values, raw[i+1:end])
+			}
+			i = end
+		}
+	}
+	return values
+}
+
+// tomlStringEnd returns the index of the quote closing the string that
+// starts at start, or len(s) when the string is unterminated.
+func tomlStringEnd(s string, start int) int {
+	quote := s[start]
+	for i := start + 1; i < len(s); i++ {
+		if quote == '"' && s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == quote {
+			return i
+		}
+	}
+	return len(s)
+}
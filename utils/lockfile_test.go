@@ -0,0 +1,355 @@
+package utils
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func summarizeLockfileForTest(t *testing.T, lockfile, content string, siblings map[string]string) lockfileSummary {
+	t.Helper()
+	dir := t.TempDir()
+	for name, body := range siblings {
+		createTestFile(t, filepath.Join(dir, name), body)
+	}
+	summary, err := lockfileSummarizers[lockfile](dir, []byte(content))
+	require.NoError(t, err)
+	return summary
+}
+
+func TestSummarizeGoSum(t *testing.T) {
+	gomod := `module example.com/app
+
+go 1.22
+
+require github.com/spf13/cobra v1.9.1
+
+require (
+	github.com/stretchr/testify v1.10.0
+	github.com/davecgh/go-spew v1.1.1 // indirect
+)
+`
+	gosum := `github.com/davecgh/go-spew v1.1.1 h1:abc=
+github.com/davecgh/go-spew v1.1.1/go.mod h1:def=
+github.com/spf13/cobra v1.9.1 h1:ghi=
+github.com/spf13/cobra v1.9.1/go.mod h1:jkl=
+github.com/stretchr/testify v1.10.0 h1:mno=
+github.com/stretchr/testify v1.10.0/go.mod h1:pqr=
+`
+	summary := summarizeLockfileForTest(t, "go.sum", gosum, map[string]string{"go.mod": gomod})
+	assert.Equal(t, 3, summary.Locked)
+	assert.Equal(t, []lockedDependency{
+		{Name: "github.com/spf13/cobra", Version: "v1.9.1"},
+		{Name: "github.com/stretchr/testify", Version: "v1.10.0"},
+	}, summary.Direct)
+}
+
+func TestSummarizePackageLock(t *testing.T) {
+	t.Run("v3 root package", func(t *testing.T) {
+		lock := `{
+  "lockfileVersion": 3,
+  "packages": {
+    "": {"dependencies": {"react": "^18.2.0"}, "devDependencies": {"jest": "^29.0.0"}},
+    "node_modules/react": {"version": "18.2.0"},
+    "node_modules/loose-envify": {"version": "1.4.0"},
+    "node_modules/jest": {"version": "29.7.0", "dev": true},
+    "node_modules/jest/node_modules/react": {"version": "17.0.2"}
+  }
+}`
+		summary := summarizeLockfileForTest(t, "package-lock.json", lock, nil)
+		assert.Equal(t, 4, summary.Locked)
+		assert.Equal(t, []lockedDependency{
+			{Name: "react", Version: "18.2.0"},
+			{Name: "jest", Version: "29.7.0", Dev: true},
+		}, summary.Direct)
+	})
+
+	t.Run("v1 with package.json", func(t *testing.T) {
+		lock := `{"lockfileVersion": 1, "dependencies": {
+  "lodash": {"version": "4.17.21"},
+  "left-pad": {"version": "1.3.0"}
+}}`
+		summary := summarizeLockfileForTest(t, "package-lock.json", lock, map[string]string{
+			"package.json": `{"dependencies": {"lodash": "^4.17.0"}}`,
+		})
+		assert.Equal(t, 2, summary.Locked)
+		assert.Equal(t, []lockedDependency{{Name: "lodash", Version: "4.17.21"}}, summary.Direct)
+	})
+
+	t.Run("v1 without package.json lists every package", func(t *testing.T) {
+		lock := `{"lockfileVersion": 1, "dependencies": {"b": {"version": "2.0.0", "dev": true}, "a": {"version": "1.0.0"}}}`
+		summary := summarizeLockfileForTest(t, "package-lock.json", lock, nil)
+		assert.Equal(t, []lockedDependency{
+			{Name: "a", Version: "1.0.0"},
+			{Name: "b", Version: "2.0.0", Dev: true},
+		}, summary.Direct)
+	})
+}
+
+func TestSummarizeYarnLock(t *testing.T) {
+	lock := `# THIS IS AN AUTOGENERATED FILE.
+# yarn lockfile v1
+
+
+"@babel/core@^7.0.0", "@babel/core@^7.12.0":
+  version "7.23.0"
+  resolved "https://registry.yarnpkg.com/@babel/core/-/core-7.23.0.tgz"
+  dependencies:
+    debug "^4.1.0"
+
+debug@^4.1.0:
+  version "4.3.4"
+
+debug@^2.6.9:
+  version "2.6.9"
+`
+	summary := summarizeLockfileForTest(t, "yarn.lock", lock, map[string]string{
+		"package.json": `{"dependencies": {"debug": "^2.6.9"}, "devDependencies": {"@babel/core": "^7.12.0"}}`,
+	})
+	assert.Equal(t, 3, summary.Locked)
+	assert.Equal(t, []lockedDependency{
+		{Name: "debug", Version: "2.6.9"},
+		{Name: "@babel/core", Version: "7.23.0", Dev: true},
+	}, summary.Direct)
+}
+
+func TestSummarizeYarnBerryLock(t *testing.T) {
+	lock := `__metadata:
+  version: 6
+
+"lodash@npm:^4.17.21":
+  version: 4.17.21
+  resolution: "lodash@npm:4.17.21"
+`
+	summary := summarizeLockfileForTest(t, "yarn.lock", lock, map[string]string{
+		"package.json": `{"dependencies": {"lodash": "^4.17.21"}}`,
+	})
+	assert.Equal(t, 1, summary.Locked)
+	assert.Equal(t, []lockedDependency{{Name: "lodash", Version: "4.17.21"}}, summary.Direct)
+}
+
+func TestSummarizePnpmLock(t *testing.T) {
+	t.Run("v9 importers", func(t *testing.T) {
+		lock := `lockfileVersion: '9.0'
+importers:
+  .:
+    dependencies:
+      react-dom:
+        specifier: ^18.2.0
+        version: 18.2.0(react@18.2.0)
+    devDependencies:
+      typescript:
+        specifier: ^5.0.0
+        version: 5.4.5
+packages:
+  react-dom@18.2.0:
+    resolution: {integrity: sha512-x}
+  react@18.2.0:
+    resolution: {integrity: sha512-y}
+  typescript@5.4.5:
+    resolution: {integrity: sha512-z}
+`
+		summary := summarizeLockfileForTest(t, "pnpm-lock.yaml", lock, nil)
+		assert.Equal(t, 3, summary.Locked)
+		assert.Equal(t, []lockedDependency{
+			{Name: "react-dom", Version: "18.2.0"},
+			{Name: "typescript", Version: "5.4.5", Dev: true},
+		}, summary.Direct)
+	})
+
+	t.Run("v5 top level", func(t *testing.T) {
+		lock := `lockfileVersion: 5.4
+dependencies:
+  lodash: 4.17.21
+packages:
+  /lodash/4.17.21:
+    dev: false
+`
+		summary := summarizeLockfileForTest(t, "pnpm-lock.yaml", lock, nil)
+		assert.Equal(t, []lockedDependency{{Name: "lodash", Version: "4.17.21"}}, summary.Direct)
+	})
+}
+
+func TestSummarizeCargoLock(t *testing.T) {
+	lock := `# This file is automatically @generated by Cargo.
+version = 3
+
+[[package]]
+name = "app"
+version = "0.1.0"
+dependencies = [
+ "serde",
+ "rand 0.8.5",
+ "shared",
+]
+
+[[package]]
+name = "shared"
+version = "0.1.0"
+dependencies = [
+ "libc",
+]
+
+[[package]]
+name = "rand"
+version = "0.8.5"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+dependencies = [
+ "libc",
+]
+
+[[package]]
+name = "rand"
+version = "0.7.3"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+
+[[package]]
+name = "serde"
+version = "1.0.197"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+
+[[package]]
+name = "libc"
+version = "0.2.153"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+`
+	summary := summarizeLockfileForTest(t, "Cargo.lock", lock, nil)
+	assert.Equal(t, 4, summary.Locked)
+	assert.Equal(t, []lockedDependency{
+		{Name: "libc", Version: "0.2.153"},
+		{Name: "rand", Version: "0.8.5"},
+		{Name: "serde", Version: "1.0.197"},
+	}, summary.Direct)
+}
+
+func TestSummarizePoetryLock(t *testing.T) {
+	lock := `[[package]]
+name = "Requests"
+version = "2.31.0"
+description = """
+A multi-line description with [brackets]
+"""
+
+[[package]]
+name = "urllib3"
+version = "2.2.1"
+
+[[package]]
+name = "pytest"
+version = "8.1.1"
+
+[[package]]
+name = "typing_extensions"
+version = "4.10.0"
+`
+	pyproject := `[tool.poetry]
+name = "app"
+
+[tool.poetry.dependencies]
+python = "^3.11"
+requests = "^2.31"
+
+[tool.poetry.group.dev.dependencies]
+pytest = { version = "^8.0", optional = true }
+
+[project]
+dependencies = [
+    "typing-extensions>=4.0; python_version < '3.12'",  # backport
+]
+`
+	summary := summarizeLockfileForTest(t, "poetry.lock", lock, map[string]string{"pyproject.toml": pyproject})
+	assert.Equal(t, 4, summary.Locked)
+	assert.Equal(t, []lockedDependency{
+		{Name: "requests", Version: "2.31.0"},
+		{Name: "typing-extensions", Version: "4.10.0"},
+		{Name: "pytest", Version: "8.1.1", Dev: true},
+	}, summary.Direct)
+}
+
+func TestSummarizePipfileLock(t *testing.T) {
+	lock := `{
+  "_meta": {"hash": {"sha256": "x"}},
+  "default": {"flask": {"version": "==3.0.2"}, "werkzeug": {"version": "==3.0.1"}},
+  "develop": {"black": {"version": "==24.2.0"}}
+}`
+	pipfile := `[packages]
+Flask = "*"
+
+[dev-packages]
+black = "*"
+`
+	summary := summarizeLockfileForTest(t, "Pipfile.lock", lock, map[string]string{"Pipfile": pipfile})
+	assert.Equal(t, 3, summary.Locked)
+	assert.Equal(t, []lockedDependency{
+		{Name: "Flask", Version: "3.0.2"},
+		{Name: "black", Version: "24.2.0", Dev: true},
+	}, summary.Direct)
+}
+
+func TestSummarizeGemfileLock(t *testing.T) {
+	lock := `GEM
+  remote: https://rubygems.org/
+  specs:
+    actionpack (7.1.3)
+      rack (>= 2.2.4)
+    rack (3.0.9)
+    rails (7.1.3)
+      actionpack (= 7.1.3)
+
+PLATFORMS
+  ruby
+
+DEPENDENCIES
+  rack
+  rails (~> 7.1)
+
+BUNDLED WITH
+   2.5.6
+`
+	summary := summarizeLockfileForTest(t, "Gemfile.lock", lock, nil)
+	assert.Equal(t, 3, summary.Locked)
+	assert.Equal(t, []lockedDependency{
+		{Name: "rack", Version: "3.0.9"},
+		{Name: "rails", Version: "7.1.3"},
+	}, summary.Direct)
+}
+
+func TestSummarizeComposerLock(t *testing.T) {
+	lock := `{
+  "packages": [{"name": "monolog/monolog", "version": "3.5.0"}, {"name": "psr/log", "version": "3.0.0"}],
+  "packages-dev": [{"name": "phpunit/phpunit", "version": "10.5.0"}]
+}`
+	summary := summarizeLockfileForTest(t, "composer.lock", lock, map[string]string{
+		"composer.json": `{"require": {"php": ">=8.1", "ext-json": "*", "monolog/monolog": "^3.0"}, "require-dev": {"phpunit/phpunit": "^10"}}`,
+	})
+	assert.Equal(t, 3, summary.Locked)
+	assert.Equal(t, []lockedDependency{
+		{Name: "monolog/monolog", Version: "3.5.0"},
+		{Name: "phpunit/phpunit", Version: "10.5.0", Dev: true},
+	}, summary.Direct)
+}
+
+func TestSummarizeLockfile_InvalidJSON(t *testing.T) {
+	_, err := summarizePackageLock(t.TempDir(), []byte("{not json"))
+	assert.Error(t, err)
+}
+
+func TestParseTOMLTables(t *testing.T) {
+	tables := parseTOMLTables([]byte(`title = "root" # comment
+[server]
+"quoted.key" = 'single'
+ports = [ 8000,
+  8001 ]
+[[item]]
+name = "a"
+`))
+	require.Len(t, tables, 3)
+	assert.Equal(t, "root", tomlString(tables[0].values["title"]))
+	assert.Equal(t, "server", tables[1].name)
+	assert.Equal(t, "single", tomlString(tables[1].values["quoted.key"]))
+	assert.Contains(t, tables[1].values["ports"], "8001 ]")
+	assert.Equal(t, "item", tables[2].name)
+	assert.Equal(t, []string{"x", "y"}, tomlStrings(`["x", { include = "skip" }, 'y']`))
+}
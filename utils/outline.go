@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// OutlineMode replaces collected source files with their outline: the
+// declarations and signatures, without function bodies.
+var OutlineMode bool
+
+// OutlineLanguages limits OutlineMode to these languages, keyed in lower
+// case; empty outlines every language that has an outliner.
+var OutlineLanguages map[string]struct{}
+
+// outliners build the outline of a file's text, keyed by language. Languages
+// without an outliner are collected in full.
+var outliners = map[string]func(text string) (string, error){
+	"Go": outlineGo,
+}
+
+// ParseOutlineLanguages validates language names for --outline-lang,
+// case-insensitively, and returns them as a lookup set.
+func ParseOutlineLanguages(names []string) (map[string]struct{}, error) {
+	available := make(map[string]struct{}, len(outliners))
+	availableNames := make([]string, 0, len(outliners))
+	for lang := range outliners {
+		available[strings.ToLower(lang)] = struct{}{}
+		availableNames = append(availableNames, lang)
+	}
+	sort.Strings(availableNames)
+
+	languages := make(map[string]struct{})
+	for _, name := range names {
+		key := strings.ToLower(strings.TrimSpace(name))
+		if key == "" {
+			continue
+		}
+		if _, ok := available[key]; !ok {
+			return nil, fmt.Errorf("no outline available for '%s' (available: %s)", name, strings.Join(availableNames, ", "))
+		}
+		languages[key] = struct{}{}
+	}
+	return languages, nil
+}
+
+// outlineSource returns the outline of text when OutlineMode is set and
+// language has an outliner. ok is false when text was left unchanged.
+func outlineSource(displayPath, language, text string, debug bool) (string, bool) {
+	if !OutlineMode {
+		return text, false
+	}
+	if len(OutlineLanguages) > 0 {
+		if _, selected := OutlineLanguages[strings.ToLower(language)]; !selected {
+			return text, false
+		}
+	}
+	outliner, found := outliners[language]
+	if !found {
+		return text, false
+	}
+	outline, err := outliner(text)
+	if err != nil {
+		PrintDebug(fmt.Sprintf("Could not outline '%s', using full content: %v", displayPath, err), debug)
+		return text, false
+	}
+	PrintDebug(fmt.Sprintf("Outlined '%s' (%d of %d bytes)", displayPath, len(outline), len(text)), debug)
+	return outline, true
+}
+
+// outlineGo keeps the package clause, imports, type declarations and
+// function and method signatures of a Go file, each with its doc comment, in
+// their original formatting. Function bodies, constants and variables are
+// left out.
+func outlineGo(text string) (string, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", text, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return "", err
+	}
+	source := func(from, to token.Pos) string {
+		return text[fset.Position(from).Offset:fset.Position(to).Offset]
+	}
+	withDoc := func(doc *ast.CommentGroup, decl string) string {
+		if doc == nil {
+			return decl
+		}
+		return source(doc.Pos(), doc.End()) + "\n" + decl
+	}
+
+	parts := []string{withDoc(file.Doc, "package "+file.Name.Name)}
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.GenDecl:
+			if decl.Tok != token.IMPORT && decl.Tok != token.TYPE {
+				continue
+			}
+			parts = append(parts, withDoc(decl.Doc, source(decl.Pos(), decl.End())))
+		case *ast.FuncDecl:
+			end := decl.End()
+			if decl.Body != nil {
+				end = decl.Body.Lbrace
+			}
+			parts = append(parts, withDoc(decl.Doc, strings.TrimSpace(source(decl.Pos(), end))))
+		}
+	}
+	return strings.Join(parts, "\n\n") + "\n", nil
+}
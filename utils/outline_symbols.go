@@ -0,0 +1,282 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxSignatureLines bounds how far a signature is followed across lines.
+const maxSignatureLines = 20
+
+// errNoSymbols makes outlineSource fall back to the full content of a file
+// without declarations, such as a script of top-level statements.
+var errNoSymbols = errors.New("no declarations found")
+
+// symbolPattern recognizes a declaration by the start of its first line.
+type symbolPattern struct {
+	re *regexp.Regexp
+	// container marks declarations whose body holds further declarations,
+	// such as classes, interfaces and impl blocks.
+	container bool
+	// member patterns only apply directly inside a container, where a line
+	// such as "run(task) {" declares a method rather than calling a function.
+	member bool
+}
+
+// symbolOutliner lists the class, function and interface declarations of a
+// language, one signature per line prefixed with its line number. Comments
+// and literals are masked before matching, so declarations inside them are
+// ignored; declarations inside function bodies are left out.
+type symbolOutliner struct {
+	language string
+	patterns []symbolPattern
+	// indentScoped languages such as Python nest by indentation and end a
+	// signature with ":"; the others nest by braces.
+	indentScoped bool
+}
+
+var (
+	pythonOutliner = symbolOutliner{
+		language:     "Python",
+		indentScoped: true,
+		patterns: []symbolPattern{
+			{re: regexp.MustCompile(`^\s*class\s+\w+`), container: true},
+			{re: regexp.MustCompile(`^\s*(async\s+)?def\s+\w+`)},
+		},
+	}
+	// ecmaScriptPatterns serve both JavaScript and TypeScript; the
+	// TypeScript-only forms never match plain JavaScript.
+	ecmaScriptPatterns = []symbolPattern{
+		{re: regexp.MustCompile(`^\s*(export\s+)?(default\s+)?(declare\s+)?(abstract\s+)?class\b`), container: true},
+		{re: regexp.MustCompile(`^\s*(export\s+)?(declare\s+)?interface\s+\w+`), container: true},
+		{re: regexp.MustCompile(`^\s*(export\s+)?(declare\s+)?(namespace|module)\s+\S`), container: true},
+		{re: regexp.MustCompile(`^\s*(export\s+)?(declare\s+)?(const\s+)?enum\s+\w+`)},
+		{re: regexp.MustCompile(`^\s*(export\s+)?(declare\s+)?type\s+\w+[^=]*=`)},
+		{re: regexp.MustCompile(`^\s*(export\s+)?(default\s+)?(declare\s+)?(async\s+)?function\b`)},
+		{re: regexp.MustCompile(`^\s*(export\s+)?(const|let|var)\s+\w+[^=]*=\s*(async\s+)?(function\b|\([^)]*$|(\([^)]*\)|\w+)\s*(:[^=]*)?=>)`)},
+		{re: regexp.MustCompile(`^\s*((public|private|protected|static|readonly|async|abstract|override|declare|get|set)\s+)*[*#]?\w+\??\s*(<[^>]*>)?\s*\(`), member: true},
+	}
+	javaAnnotations = `(@\w+(\([^)]*\))?\s+)*`
+	javaOutliner    = symbolOutliner{
+		language: "Java",
+		patterns: []symbolPattern{
+			{re: regexp.MustCompile(`^\s*` + javaAnnotations + `((public|protected|private|static|final|abstract|sealed|non-sealed|strictfp)\s+)*(class|interface|enum|record|@interface)\s+\w+`), container: true},
+			{re: regexp.MustCompile(`^\s*` + javaAnnotations + `((public|protected|private|static|final|abstract|synchronized|native|default|strictfp)\s+)*(<[^>]*>\s+)?[\w.]+(<.*>)?(\[\])*\s+\w+\s*\(`), member: true},
+			// Constructors; the lower-case letter tells them from enum constants.
+			{re: regexp.MustCompile(`^\s*` + javaAnnotations + `((public|protected|private)\s+)?[A-Z]\w*[a-z]\w*\s*\(`), member: true},
+		},
+	}
+	rustVisibility = `(pub(\([^)]*\))?\s+)?`
+	rustOutliner   = symbolOutliner{
+		language: "Rust",
+		patterns: []symbolPattern{
+			{re: regexp.MustCompile(`^\s*` + rustVisibility + `(unsafe\s+)?(trait|impl|mod)\b`), container: true},
+			{re: regexp.MustCompile(`^\s*` + rustVisibility + `((const|async|unsafe|extern)\s+)*fn\s+\w+`)},
+			{re: regexp.MustCompile(`^\s*` + rustVisibility + `(struct|enum|union|type)\s+\w+`)},
+			{re: regexp.MustCompile(`^\s*macro_rules!\s*\w+`)},
+		},
+	}
+)
+
+func init() {
+	for _, outliner := range []symbolOutliner{
+		pythonOutliner,
+		{language: "Javascript", patterns: ecmaScriptPatterns},
+		{language: "Typescript", patterns: ecmaScriptPatterns},
+		javaOutliner,
+		rustOutliner,
+	} {
+		outliners[outliner.language] = outliner.outline
+	}
+}
+
+func (o symbolOutliner) outline(text string) (string, error) {
+	comments, literals := scanSource(text, COMMENT_SYNTAX[o.language])
+	// display keeps literals for the output; structure also masks them so
+	// that brackets and keywords inside strings are not seen.
+	display := []byte(text)
+	for _, span := range comments {
+		maskBytes(display, span.start, span.end)
+	}
+	structure := append([]byte(nil), display...)
+	for _, span := range literals {
+		maskBytes(structure, span.start, span.end)
+	}
+
+	var symbols []string
+	if o.indentScoped {
+		symbols = o.indentedSymbols(display, structure)
+	} else {
+		symbols = o.bracedSymbols(display, structure)
+	}
+	if len(symbols) == 0 {
+		return "", errNoSymbols
+	}
+	return strings.Join(symbols, "\n") + "\n", nil
+}
+
+// bracedSymbols matches declarations at the top level and directly inside
+// containers, tracking "{" and "}" to know the current scope.
+func (o symbolOutliner) bracedSymbols(display, structure []byte) []string {
+	var symbols []string
+	// scopes holds one entry per open brace; true for container bodies.
+	var scopes []bool
+	openContainer := false
+	signatureEnd := 0
+	forEachLine(structure, func(lineNo, start, end int) {
+		if start >= signatureEnd && allTrue(scopes) {
+			line := string(structure[start:end])
+			for _, pattern := range o.patterns {
+				if pattern.member && len(scopes) == 0 {
+					continue
+				}
+				if !pattern.re.MatchString(line) {
+					continue
+				}
+				signature, sigEnd := readSignature(display, structure, start, "{;", false)
+				symbols = append(symbols, formatSymbol(lineNo, display[start:end], signature))
+				signatureEnd = sigEnd
+				openContainer = pattern.container
+				break
+			}
+		}
+		for _, c := range structure[start:end] {
+			switch c {
+			case '{':
+				scopes = append(scopes, openContainer)
+				openContainer = false
+			case '}':
+				if len(scopes) > 0 {
+					scopes = scopes[:len(scopes)-1]
+				}
+			case ';':
+				openContainer = false
+			}
+		}
+	})
+	return symbols
+}
+
+// indentedSymbols matches declarations at the top level and directly inside
+// containers, using indentation to know the current scope.
+func (o symbolOutliner) indentedSymbols(display, structure []byte) []string {
+	type scope struct {
+		indent    int
+		container bool
+	}
+	var symbols []string
+	var scopes []scope
+	signatureEnd := 0
+	forEachLine(structure, func(lineNo, start, end int) {
+		line := string(structure[start:end])
+		if start < signatureEnd || strings.TrimSpace(line) == "" {
+			return
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		for len(scopes) > 0 && scopes[len(scopes)-1].indent >= indent {
+			scopes = scopes[:len(scopes)-1]
+		}
+		for _, s := range scopes {
+			if !s.container {
+				return
+			}
+		}
+		for _, pattern := range o.patterns {
+			if !pattern.re.MatchString(line) {
+				continue
+			}
+			signature, sigEnd := readSignature(display, structure, start, ":", true)
+			symbols = append(symbols, formatSymbol(lineNo, display[start:end], signature))
+			signatureEnd = sigEnd
+			scopes = append(scopes, scope{indent: indent, container: pattern.container})
+			return
+		}
+	})
+	return symbols
+}
+
+// readSignature follows a declaration from start until one of terminators
+// outside brackets, or the end of a line outside brackets. The signature is
+// returned on one line, including the terminator when keepTerminator is set,
+// with the offset where scanning should resume.
+func readSignature(display, structure []byte, start int, terminators string, keepTerminator bool) (string, int) {
+	depth, lines := 0, 1
+	end := start
+	for ; end < len(structure); end++ {
+		c := structure[end]
+		switch {
+		case c == '(' || c == '[' || (keepTerminator && c == '{'):
+			depth++
+		case c == ')' || c == ']' || (keepTerminator && c == '}'):
+			if depth > 0 {
+				depth--
+			}
+		case depth == 0 && strings.IndexByte(terminators, c) >= 0:
+			if keepTerminator {
+				end++
+			}
+			return joinSignatureLines(display[start:end]), end
+		case c == '\n':
+			if depth == 0 || lines == maxSignatureLines {
+				return joinSignatureLines(display[start:end]), end
+			}
+			lines++
+		}
+	}
+	return joinSignatureLines(display[start:end]), end
+}
+
+func joinSignatureLines(signature []byte) string {
+	var parts []string
+	for _, line := range strings.Split(string(signature), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			parts = append(parts, line)
+		}
+	}
+	joined := strings.Join(parts, " ")
+	for _, pair := range [][2]string{{"( ", "("}, {" )", ")"}, {",)", ")"}} {
+		joined = strings.ReplaceAll(joined, pair[0], pair[1])
+	}
+	return joined
+}
+
+// formatSymbol renders a signature as "<line>: <indentation><signature>".
+func formatSymbol(lineNo int, line []byte, signature string) string {
+	indent := line[:len(line)-len(strings.TrimLeft(string(line), " \t"))]
+	return fmt.Sprintf("%d: %s%s", lineNo, indent, signature)
+}
+
+// forEachLine calls fn with the 1-based number and the offsets of each line
+// of text, without its newline.
+func forEachLine(text []byte, fn func(lineNo, start, end int)) {
+	lineNo := 1
+	for start := 0; start < len(text); lineNo++ {
+		end := start
+		for end < len(text) && text[end] != '\n' {
+			end++
+		}
+		fn(lineNo, start, end)
+		start = end + 1
+	}
+}
+
+// maskBytes blanks text[start:end] except for newlines, so offsets and line
+// numbers stay the same.
+func maskBytes(text []byte, start, end int) {
+	for i := start; i < end && i < len(text); i++ {
+		if text[i] != '\n' {
+			text[i] = ' '
+		}
+	}
+}
+
+func allTrue(values []bool) bool {
+	for _, v := range values {
+		if !v {
+			return false
+		}
+	}
+	return true
+}
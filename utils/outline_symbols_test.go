@@ -0,0 +1,230 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestSymbolOutliners(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		text     string
+		expected string
+	}{
+		{
+			name:     "Python classes, methods and functions",
+			language: "Python",
+			text: `"""Module doc with def fake():"""
+import os
+
+
+class Repo(Base):
+    """Stores things."""
+
+    kind = "def not_a_function():"
+
+    def __init__(self, path: str) -> None:
+        self.path = path
+
+        def helper():
+            pass
+
+    async def fetch(
+        self,
+        key: dict[str, int] = {"a": 1},
+    ) -> bytes:
+        return b""
+
+
+# def commented():
+def main():
+    if os.environ.get("X"):
+        print("hi")
+`,
+			expected: `5: class Repo(Base):
+10:     def __init__(self, path: str) -> None:
+16:     async def fetch(self, key: dict[str, int] = {"a": 1}) -> bytes:
+24: def main():
+`,
+		},
+		{
+			name:     "TypeScript declarations and class members",
+			language: "Typescript",
+			text: `import { x } from "./x";
+
+export interface Store {
+  get(key: string): Promise<string>;
+  size: number;
+}
+
+export type Handler = (req: Request) => void;
+
+/* function commented() {} */
+export class MemoryStore implements Store {
+  private items = new Map<string, string>();
+
+  constructor(private readonly name: string) {}
+
+  async get(key: string): Promise<string> {
+    const value = this.items.get(key);
+    if (value) {
+      return value;
+    }
+    return load(key);
+  }
+}
+
+export const handler = async (req: Request) => {
+  helper(req);
+};
+
+export function createStore(
+  name: string,
+): Store {
+  return new MemoryStore(name);
+}
+
+app.listen(3000);
+`,
+			expected: `3: export interface Store
+4:   get(key: string): Promise<string>
+8: export type Handler = (req: Request) => void
+11: export class MemoryStore implements Store
+14:   constructor(private readonly name: string)
+16:   async get(key: string): Promise<string>
+25: export const handler = async (req: Request) =>
+29: export function createStore(name: string): Store
+`,
+		},
+		{
+			name:     "JavaScript ignores braces in strings and regular expressions",
+			language: "Javascript",
+			text:     "const open = \"{\";\nconst re = /\\{/g;\nconst tpl = `${open} }`;\n\nclass Parser {\n  parse(text) {\n    return text.split(\"}\");\n  }\n}\n\nfunction run() {}\n",
+			expected: "5: class Parser\n6:   parse(text)\n11: function run()\n",
+		},
+		{
+			name:     "Java classes, methods and constructors",
+			language: "Java",
+			text: `package demo;
+
+import java.util.List;
+
+/** A service. */
+@Service
+public class UserService {
+    private final Repo repo = new Repo();
+
+    public UserService(Repo repo) {
+        this.repo = repo;
+    }
+
+    @Override
+    public <T> List<T> findAll(Class<T> type) throws Exception {
+        return repo.query(type);
+    }
+
+    interface Listener {
+        void onEvent(String name);
+    }
+
+    enum Level { LOW, HIGH }
+}
+`,
+			expected: `7: public class UserService
+10:     public UserService(Repo repo)
+15:     public <T> List<T> findAll(Class<T> type) throws Exception
+19:     interface Listener
+20:         void onEvent(String name)
+23:     enum Level
+`,
+		},
+		{
+			name:     "Rust items, traits and impl blocks",
+			language: "Rust",
+			text: `use std::fmt;
+
+/// A point.
+#[derive(Debug)]
+pub struct Point {
+    x: i32,
+}
+
+pub trait Shape {
+    fn area(&self) -> f64;
+}
+
+impl fmt::Display for Point {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        let brace = '{';
+        write!(f, "{}", self.x)
+    }
+}
+
+pub(crate) async fn load<T>(path: &str) -> Result<T, Error>
+where
+    T: Default,
+{
+    fn inner() {}
+    todo!()
+}
+`,
+			expected: `5: pub struct Point
+9: pub trait Shape
+10:     fn area(&self) -> f64
+13: impl fmt::Display for Point
+14:     fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result
+20: pub(crate) async fn load<T>(path: &str) -> Result<T, Error>
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outliner, ok := outliners[tt.language]
+			if !ok {
+				t.Fatalf("no outliner for %s", tt.language)
+			}
+			actual, err := outliner(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSymbolOutliner_NoDeclarations(t *testing.T) {
+	if _, err := outliners["Python"]("print('hello')\n"); err != errNoSymbols {
+		t.Errorf("expected errNoSymbols, got %v", err)
+	}
+}
+
+func TestParseOutlineLanguages(t *testing.T) {
+	languages, err := ParseOutlineLanguages([]string{"python", " Typescript", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(languages) != 2 {
+		t.Errorf("expected two languages, got %v", languages)
+	}
+	if _, err := ParseOutlineLanguages([]string{"Markdown"}); err == nil {
+		t.Error("expected an error for a language without an outliner")
+	}
+}
+
+func TestOutlineSource_LanguageSelection(t *testing.T) {
+	origMode, origLanguages := OutlineMode, OutlineLanguages
+	t.Cleanup(func() { OutlineMode, OutlineLanguages = origMode, origLanguages })
+
+	OutlineMode = true
+	OutlineLanguages = map[string]struct{}{"python": {}}
+	python := "def main():\n    pass\n"
+	if text, outlined := outlineSource("main.py", "Python", python, false); !outlined || text != "1: def main():\n" {
+		t.Errorf("expected a Python outline, got %q", text)
+	}
+	if _, outlined := outlineSource("main.go", "Go", outlineGoSource, false); outlined {
+		t.Error("expected Go to be collected in full when only Python is selected")
+	}
+}
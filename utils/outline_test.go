@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const outlineGoSource = `// Package shapes computes areas.
+package shapes
+
+import (
+	"fmt"
+	"math"
+)
+
+const defaultSides = 4
+
+// Shape has an area.
+type Shape interface {
+	Area() float64
+}
+
+// Circle is a round Shape.
+type Circle struct {
+	Radius float64 // in meters
+}
+
+// Area returns the area of c.
+func (c Circle) Area() float64 {
+	return math.Pi * c.Radius * c.Radius
+}
+
+func describe[T Shape](s T) string {
+	return fmt.Sprintf("%T: %.2f", s, s.Area())
+}
+`
+
+func TestOutlineGo(t *testing.T) {
+	actual, err := outlineGo(outlineGoSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `// Package shapes computes areas.
+package shapes
+
+import (
+	"fmt"
+	"math"
+)
+
+// Shape has an area.
+type Shape interface {
+	Area() float64
+}
+
+// Circle is a round Shape.
+type Circle struct {
+	Radius float64 // in meters
+}
+
+// Area returns the area of c.
+func (c Circle) Area() float64
+
+func describe[T Shape](s T) string
+`
+	if actual != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+}
+
+func TestOutlineSource(t *testing.T) {
+	orig := OutlineMode
+	t.Cleanup(func() { OutlineMode = orig })
+
+	OutlineMode = false
+	if text, outlined := outlineSource("a.go", "Go", outlineGoSource, false); outlined || text != outlineGoSource {
+		t.Error("expected full content when outline mode is off")
+	}
+
+	OutlineMode = true
+	if _, outlined := outlineSource("a.go", "Go", outlineGoSource, false); !outlined {
+		t.Error("expected a Go outline")
+	}
+	broken := "package broken\nfunc {\n"
+	if text, outlined := outlineSource("b.go", "Go", broken, false); outlined || text != broken {
+		t.Error("expected full content for a file that does not parse")
+	}
+	other := "body {}\n"
+	if text, outlined := outlineSource("a.txt", "Markdown", other, false); outlined || text != other {
+		t.Error("expected full content for a language without an outliner")
+	}
+}
+
+func TestProcessSourceFiles_Outline(t *testing.T) {
+	origOutline, origFormat := OutlineMode, OutputFormat
+	t.Cleanup(func() { OutlineMode, OutputFormat = origOutline, origFormat })
+
+	tempDir := t.TempDir()
+	createTestFile(t, filepath.Join(tempDir, "shapes.go"), outlineGoSource)
+	createTestFile(t, filepath.Join(tempDir, "notes.md"), "# Notes\n")
+
+	OutlineMode = true
+	output := ProcessSourceFiles(tempDir, 5, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, false, nil)
+	if strings.Contains(output, "math.Pi") || !strings.Contains(output, "func (c Circle) Area() float64\n") {
+		t.Errorf("expected the Go file as an outline:\n%s", output)
+	}
+	if !strings.Contains(output, "# Notes") {
+		t.Errorf("expected other files in full:\n%s", output)
+	}
+
+	OutputFormat = FormatJSON
+	var doc struct {
+		Files []sourceFile `json:"files"`
+	}
+	if err := json.Unmarshal([]byte(ProcessSourceFiles(tempDir, 5, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, false, nil)), &doc); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range doc.Files {
+		if file.Outline != (file.Path == "shapes.go") {
+			t.Errorf("unexpected outline flag for %s: %v", file.Path, file.Outline)
+		}
+	}
+}
@@ -0,0 +1,457 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// FormatMarkdown is the default human- and LLM-readable output.
+	FormatMarkdown = "markdown"
+	// FormatJSON emits a single JSON document.
+	FormatJSON = "json"
+	// FormatJSONL emits one JSON record per line.
+	FormatJSONL = "jsonl"
+	// FormatXML wraps the prompt, tree and files in XML-style tags.
+	FormatXML = "xml"
+)
+
+// OutputFormat selects how WriteProjectSummary and WriteReadmeFiles render their output.
+var OutputFormat = FormatMarkdown
+
+// ParseOutputFormat validates an output format name.
+func ParseOutputFormat(name string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "md", FormatMarkdown:
+		return FormatMarkdown, nil
+	case FormatJSON:
+		return FormatJSON, nil
+	case FormatJSONL:
+		return FormatJSONL, nil
+	case FormatXML:
+		return FormatXML, nil
+	default:
+		return "", fmt.Errorf("unsupported format '%s'. Supported: %s, %s, %s, %s", name, FormatMarkdown, FormatJSON, FormatJSONL, FormatXML)
+	}
+}
+
+// sourceFile is a collected file as a structured record.
+type sourceFile struct {
+	Path     string `json:"path"`
+	Language string `json:"language"`
+	Size     int64  `json:"size"`
+	Tokens   int    `json:"tokens"`
+	Content  string `json:"content"`
+	Outline  bool   `json:"outline,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Patch    string `json:"patch,omitempty"`
+}
+
+// skippedFile is a file left out because it exceeded --max-file-size.
+type skippedFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// omittedFile is a file left out because the size or token budget was
+// spent on files of higher priority.
+type omittedFile struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Priority int    `json:"priority"`
+}
+
+// dependencyFile is a collected manifest, or a lockfile reduced to its
+// direct dependencies.
+type dependencyFile struct {
+	Path         string             `json:"path"`
+	Kind         string             `json:"kind"`
+	Content      string             `json:"content,omitempty"`
+	Dependencies []lockedDependency `json:"dependencies,omitempty"`
+	Locked       int                `json:"locked,omitempty"`
+}
+
+// jsonDocument is the --format json output. jsonDocumentWriter writes it
+// piece by piece, with the same bytes as encoding it in one go.
+type jsonDocument struct {
+	jsonDocumentHead
+	Files []sourceFile `json:"files"`
+	jsonDocumentTail
+}
+
+// jsonDocumentHead holds the fields written before the files.
+type jsonDocumentHead struct {
+	Prompt   string           `json:"prompt,omitempty"`
+	Root     string           `json:"root"`
+	Tree     string           `json:"tree,omitempty"`
+	Overview *projectOverview `json:"overview,omitempty"`
+}
+
+// jsonDocumentTail holds the fields written after the files, once the
+// collection is complete.
+type jsonDocumentTail struct {
+	Dependencies  []dependencyFile `json:"dependencies,omitempty"`
+	Skipped       []skippedFile    `json:"skipped"`
+	Omitted       []omittedFile    `json:"omitted,omitempty"`
+	Languages     []languageStats  `json:"languages,omitempty"`
+	TotalSize     int64            `json:"total_size"`
+	TotalTokens   int              `json:"total_tokens"`
+	LimitHit      bool             `json:"limit_hit"`
+	TokenLimitHit bool             `json:"token_limit_hit"`
+}
+
+// JSONL records carry a "type" of "prompt", "tree", "overview", "file",
+// "dependency", "skipped", "omitted", "languages" or "summary".
+type jsonlPromptRecord struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type jsonlTreeRecord struct {
+	Type string `json:"type"`
+	Root string `json:"root"`
+	Tree string `json:"tree"`
+}
+
+type jsonlOverviewRecord struct {
+	Type string `json:"type"`
+	projectOverview
+}
+
+type jsonlLanguagesRecord struct {
+	Type      string          `json:"type"`
+	Languages []languageStats `json:"languages"`
+}
+
+type jsonlFileRecord struct {
+	Type string `json:"type"`
+	sourceFile
+}
+
+type jsonlDependencyRecord struct {
+	Type string `json:"type"`
+	dependencyFile
+}
+
+type jsonlSkippedRecord struct {
+	Type string `json:"type"`
+	skippedFile
+}
+
+type jsonlOmittedRecord struct {
+	Type string `json:"type"`
+	omittedFile
+}
+
+type jsonlSummaryRecord struct {
+	Type          string `json:"type"`
+	FileCount     int    `json:"file_count"`
+	TotalSize     int64  `json:"total_size"`
+	TotalTokens   int    `json:"total_tokens"`
+	LimitHit      bool   `json:"limit_hit"`
+	TokenLimitHit bool   `json:"token_limit_hit"`
+}
+
+// orderedSourceFiles returns the collected files in output order: grouped by
+// language, then sorted by path.
+func orderedSourceFiles(files []sourceFile) []sourceFile {
+	ordered := append([]sourceFile(nil), files...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].Language != ordered[j].Language {
+			return ordered[i].Language < ordered[j].Language
+		}
+		return ordered[i].Path < ordered[j].Path
+	})
+	return ordered
+}
+
+func orderedDependencyFiles(deps []dependencyFile) []dependencyFile {
+	ordered := append([]dependencyFile(nil), deps...)
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].Path < ordered[j].Path
+	})
+	return ordered
+}
+
+func orderedSkippedFiles(skipped []skippedFile) []skippedFile {
+	ordered := append([]skippedFile(nil), skipped...)
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].Path < ordered[j].Path
+	})
+	return ordered
+}
+
+// documentWriter writes a document in one OutputFormat as the scan
+// progresses: begin writes what is known once the project has been walked,
+// such as the overview and the tree, file writes each collected file in
+// output order, and end writes the sections that summarize the collection.
+// Write errors are kept by the bufio.Writer and reported by its Flush.
+type documentWriter interface {
+	begin(prompt, rootName string, treeLines []string, result *scanResult)
+	file(file sourceFile)
+	end(result *scanResult)
+}
+
+// newDocumentWriter returns the documentWriter for the active OutputFormat.
+func newDocumentWriter(w *bufio.Writer, debug bool) documentWriter {
+	switch OutputFormat {
+	case FormatJSON:
+		return &jsonDocumentWriter{w: w}
+	case FormatJSONL:
+		return &jsonlDocumentWriter{w: w}
+	case FormatXML:
+		return &xmlDocumentWriter{w: w}
+	default:
+		return &markdownDocumentWriter{w: w, debug: debug}
+	}
+}
+
+// writeDocument writes a finished scan result with doc.
+func writeDocument(doc documentWriter, prompt, rootName string, treeLines []string, result *scanResult) {
+	doc.begin(prompt, rootName, treeLines, result)
+	for _, file := range orderedSourceFiles(result.files) {
+		doc.file(file)
+	}
+	doc.end(result)
+}
+
+// jsonDocumentWriter writes a jsonDocument. The files array is opened by
+// begin and closed by end, with each file encoded at its nesting depth.
+type jsonDocumentWriter struct {
+	w     *bufio.Writer
+	files int
+}
+
+func (d *jsonDocumentWriter) begin(prompt, rootName string, treeLines []string, result *scanResult) {
+	head := encodeJSON(jsonDocumentHead{Prompt: prompt, Root: rootName, Tree: strings.Join(treeLines, "\n"), Overview: result.overview}, "  ")
+	d.w.WriteString(strings.TrimSuffix(head, "\n}"))
+	d.w.WriteString(",\n  \"files\": [")
+}
+
+func (d *jsonDocumentWriter) file(file sourceFile) {
+	if d.files > 0 {
+		d.w.WriteString(",")
+	}
+	d.w.WriteString("\n    " + encodeJSONWithPrefix(file, "    ", "  "))
+	d.files++
+}
+
+func (d *jsonDocumentWriter) end(result *scanResult) {
+	if d.files > 0 {
+		d.w.WriteString("\n  ")
+	}
+	d.w.WriteString("],\n")
+	tail := jsonDocumentTail{
+		Dependencies:  orderedDependencyFiles(result.dependencies),
+		Skipped:       orderedSkippedFiles(result.skippedFiles),
+		Omitted:       result.omittedFiles,
+		Languages:     languageBreakdown(result.languages),
+		TotalSize:     result.totalFileSize,
+		TotalTokens:   result.totalTokens,
+		LimitHit:      result.limitHit,
+		TokenLimitHit: result.tokenLimitHit,
+	}
+	if tail.Skipped == nil {
+		tail.Skipped = []skippedFile{}
+	}
+	d.w.WriteString(strings.TrimPrefix(encodeJSON(tail, "  "), "{\n"))
+}
+
+// jsonlDocumentWriter writes JSON Lines: the prompt, the tree and the
+// overview, one record per file, one per dependency file, one per skipped
+// and omitted file, the language breakdown and a closing summary.
+type jsonlDocumentWriter struct {
+	w       *bufio.Writer
+	records int
+	files   int
+}
+
+func (d *jsonlDocumentWriter) record(v interface{}) {
+	if d.records > 0 {
+		d.w.WriteString("\n")
+	}
+	d.w.WriteString(encodeJSON(v, ""))
+	d.records++
+}
+
+func (d *jsonlDocumentWriter) begin(prompt, rootName string, treeLines []string, result *scanResult) {
+	if prompt != "" {
+		d.record(jsonlPromptRecord{Type: "prompt", Text: prompt})
+	}
+	if len(treeLines) > 0 {
+		d.record(jsonlTreeRecord{Type: "tree", Root: rootName, Tree: strings.Join(treeLines, "\n")})
+	}
+	if result.overview != nil {
+		d.record(jsonlOverviewRecord{Type: "overview", projectOverview: *result.overview})
+	}
+}
+
+func (d *jsonlDocumentWriter) file(file sourceFile) {
+	d.record(jsonlFileRecord{Type: "file", sourceFile: file})
+	d.files++
+}
+
+func (d *jsonlDocumentWriter) end(result *scanResult) {
+	for _, dep := range orderedDependencyFiles(result.dependencies) {
+		d.record(jsonlDependencyRecord{Type: "dependency", dependencyFile: dep})
+	}
+	for _, skipped := range orderedSkippedFiles(result.skippedFiles) {
+		d.record(jsonlSkippedRecord{Type: "skipped", skippedFile: skipped})
+	}
+	for _, omitted := range result.omittedFiles {
+		d.record(jsonlOmittedRecord{Type: "omitted", omittedFile: omitted})
+	}
+	if languages := languageBreakdown(result.languages); len(languages) > 0 {
+		d.record(jsonlLanguagesRecord{Type: "languages", Languages: languages})
+	}
+	d.record(jsonlSummaryRecord{
+		Type:          "summary",
+		FileCount:     d.files,
+		TotalSize:     result.totalFileSize,
+		TotalTokens:   result.totalTokens,
+		LimitHit:      result.limitHit,
+		TokenLimitHit: result.tokenLimitHit,
+	})
+}
+
+var (
+	xmlTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	xmlAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+)
+
+// xmlDocumentWriter writes XML-style tags. Unlike Markdown fences, the tags
+// cannot be broken by file contents because text is escaped. Newlines and
+// indentation are kept verbatim so the code stays readable.
+type xmlDocumentWriter struct {
+	w     *bufio.Writer
+	files int
+}
+
+func (d *xmlDocumentWriter) begin(prompt, rootName string, treeLines []string, result *scanResult) {
+	if prompt != "" {
+		fmt.Fprintf(d.w, "<prompt>\n%s\n</prompt>\n\n", xmlTextEscaper.Replace(prompt))
+	}
+	fmt.Fprintf(d.w, "<project name=\"%s\">\n", xmlAttrEscaper.Replace(rootName))
+	if len(treeLines) > 0 {
+		fmt.Fprintf(d.w, "<tree>\n%s\n</tree>\n", xmlTextEscaper.Replace(strings.Join(treeLines, "\n")))
+	}
+	if result.overview != nil {
+		d.w.WriteString("<overview>\n")
+		for _, ecosystem := range result.overview.Ecosystems {
+			fmt.Fprintf(d.w, "<ecosystem language=\"%s\" source=\"%s\"", xmlAttrEscaper.Replace(ecosystem.Language), ecosystem.Source)
+			if ecosystem.Files > 0 {
+				fmt.Fprintf(d.w, " files=\"%d\"", ecosystem.Files)
+			}
+			d.w.WriteString("/>\n")
+		}
+		d.w.WriteString("</overview>\n")
+	}
+}
+
+func (d *xmlDocumentWriter) file(file sourceFile) {
+	fmt.Fprintf(d.w, "<file path=\"%s\" language=\"%s\" size=\"%d\" tokens=\"%d\"",
+		xmlAttrEscaper.Replace(file.Path), xmlAttrEscaper.Replace(file.Language), file.Size, file.Tokens)
+	if file.Outline {
+		d.w.WriteString(" outline=\"true\"")
+	}
+	if file.Status != "" {
+		fmt.Fprintf(d.w, " status=\"%s\"", xmlAttrEscaper.Replace(file.Status))
+	}
+	fmt.Fprintf(d.w, ">\n%s\n</file>\n", xmlTextEscaper.Replace(file.Content))
+	if file.Patch != "" {
+		fmt.Fprintf(d.w, "<patch path=\"%s\">\n%s\n</patch>\n", xmlAttrEscaper.Replace(file.Path), xmlTextEscaper.Replace(file.Patch))
+	}
+	d.files++
+}
+
+func (d *xmlDocumentWriter) end(result *scanResult) {
+	for _, dep := range orderedDependencyFiles(result.dependencies) {
+		content := dep.Content
+		fmt.Fprintf(d.w, "<dependency path=\"%s\" kind=\"%s\"", xmlAttrEscaper.Replace(dep.Path), dep.Kind)
+		if dep.Kind == dependencyKindLockfile {
+			content = lockfileSummaryText(dep.Dependencies)
+			fmt.Fprintf(d.w, " direct=\"%d\" locked=\"%d\"", len(dep.Dependencies), dep.Locked)
+		}
+		fmt.Fprintf(d.w, ">\n%s\n</dependency>\n", xmlTextEscaper.Replace(content))
+	}
+	for _, skipped := range orderedSkippedFiles(result.skippedFiles) {
+		fmt.Fprintf(d.w, "<skipped path=\"%s\" size=\"%d\"/>\n", xmlAttrEscaper.Replace(skipped.Path), skipped.Size)
+	}
+	for _, omitted := range result.omittedFiles {
+		fmt.Fprintf(d.w, "<omitted path=\"%s\" size=\"%d\" priority=\"%d\"/>\n", xmlAttrEscaper.Replace(omitted.Path), omitted.Size, omitted.Priority)
+	}
+	if languages := languageBreakdown(result.languages); len(languages) > 0 {
+		d.w.WriteString("<languages>\n")
+		for _, stats := range languages {
+			fmt.Fprintf(d.w, "<language name=\"%s\" files=\"%d\" lines=\"%d\" bytes=\"%d\"/>\n",
+				xmlAttrEscaper.Replace(stats.Language), stats.Files, stats.Lines, stats.Bytes)
+		}
+		d.w.WriteString("</languages>\n")
+	}
+	fmt.Fprintf(d.w, "<summary files=\"%d\" total-size=\"%d\" total-tokens=\"%d\" limit-hit=\"%t\" token-limit-hit=\"%t\"/>\n",
+		d.files, result.totalFileSize, result.totalTokens, result.limitHit, result.tokenLimitHit)
+	d.w.WriteString("</project>")
+}
+
+// projectOutputSniffBytes bounds how much of a file IsProjectOutput reads.
+// The prompt comes before the first section and may be long.
+const projectOutputSniffBytes = 1 << 20
+
+// IsProjectOutput reports whether the file at path is a document list-codes
+// wrote for folderAbs, in any OutputFormat and with or without a prompt,
+// such as the output of an earlier run or one of its --split-size parts.
+func IsProjectOutput(folderAbs, path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head := make([]byte, projectOutputSniffBytes)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return false
+	}
+	text := string(head[:n])
+
+	rootName := filepath.Base(folderAbs)
+	jsonName := encodeJSON(rootName, "")
+	switch {
+	case strings.Contains(text, "## Project Structure\n") && strings.Contains(text, "text\n. ("+rootName+")\n"):
+		return true // Markdown summary
+	case strings.HasPrefix(text, "# Project README Files\n") || strings.Contains(text, "\n# Project README Files\n"):
+		return true // Markdown README files
+	case strings.HasPrefix(text, "{\n") && strings.Contains(text, "\n  \"root\": "+jsonName+",\n"):
+		return true // JSON
+	case strings.HasPrefix(text, `{"type":"`):
+		return true // JSON Lines
+	case strings.Contains(text, `<project name="`+xmlAttrEscaper.Replace(rootName)+`">`):
+		return true // XML
+	}
+	return false
+}
+
+// encodeJSON marshals v without HTML escaping so source code stays readable.
+func encodeJSON(v interface{}, indent string) string {
+	return encodeJSONWithPrefix(v, "", indent)
+}
+
+// encodeJSONWithPrefix is encodeJSON for a value nested at the depth of
+// prefix.
+func encodeJSONWithPrefix(v interface{}, prefix, indent string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent(prefix, indent)
+	if err := enc.Encode(v); err != nil {
+		PrintError(fmt.Sprintf("Could not encode JSON output: %v", err))
+		return ""
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
@@ -0,0 +1,263 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withOutputFormat(t *testing.T, format string) {
+	t.Helper()
+	orig := OutputFormat
+	t.Cleanup(func() { OutputFormat = orig })
+	OutputFormat = format
+}
+
+// renderDocument writes a finished scan result with the writer for format
+// and returns the document. tree is the joined tree lines, "" for none.
+func renderDocument(t *testing.T, format, prompt, rootName, tree string, result *scanResult) string {
+	t.Helper()
+	withOutputFormat(t, format)
+	var b strings.Builder
+	w := bufio.NewWriter(&b)
+	var treeLines []string
+	if tree != "" {
+		treeLines = strings.Split(tree, "\n")
+	}
+	writeDocument(newDocumentWriter(w, false), prompt, rootName, treeLines, result)
+	require.NoError(t, w.Flush())
+	return b.String()
+}
+
+func TestParseOutputFormat(t *testing.T) {
+	for input, want := range map[string]string{
+		"":         FormatMarkdown,
+		"md":       FormatMarkdown,
+		"Markdown": FormatMarkdown,
+		"json":     FormatJSON,
+		"JSONL":    FormatJSONL,
+	} {
+		got, err := ParseOutputFormat(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, want, got, input)
+	}
+
+	_, err := ParseOutputFormat("yaml")
+	assert.Error(t, err)
+}
+
+func TestBuildJSONOutput_OrderingAndFields(t *testing.T) {
+	result := &scanResult{
+		files: []sourceFile{
+			{Path: "b.py", Language: "Python", Size: 3, Content: "b=1"},
+			{Path: "z.go", Language: "Go", Size: 9, Content: "package z"},
+			{Path: "a.go", Language: "Go", Size: 9, Content: "package a"},
+		},
+		skippedFiles:  []skippedFile{{Path: "z.bin.go", Size: 10}, {Path: "big.go", Size: 20}},
+		totalFileSize: 21,
+		limitHit:      true,
+	}
+
+	output := renderDocument(t, FormatJSON, "", "repo", ". (repo)\n└── a.go", result)
+
+	var doc jsonDocument
+	require.NoError(t, json.Unmarshal([]byte(output), &doc))
+	assert.Equal(t, "repo", doc.Root)
+	assert.Equal(t, ". (repo)\n└── a.go", doc.Tree)
+	require.Len(t, doc.Files, 3)
+	assert.Equal(t, []string{"a.go", "z.go", "b.py"}, []string{doc.Files[0].Path, doc.Files[1].Path, doc.Files[2].Path})
+	assert.Equal(t, "Go", doc.Files[0].Language)
+	assert.EqualValues(t, 9, doc.Files[0].Size)
+	assert.Equal(t, "package a", doc.Files[0].Content)
+	assert.Equal(t, []skippedFile{{Path: "big.go", Size: 20}, {Path: "z.bin.go", Size: 10}}, doc.Skipped)
+	assert.True(t, doc.LimitHit)
+	assert.False(t, doc.TokenLimitHit)
+}
+
+func TestBuildJSONOutput_EmptyListsAreArrays(t *testing.T) {
+	output := renderDocument(t, FormatJSON, "", "repo", "", &scanResult{})
+	assert.Contains(t, output, `"files": []`)
+	assert.Contains(t, output, `"skipped": []`)
+	assert.NotContains(t, output, `"tree"`)
+}
+
+func TestBuildJSONLOutput_Records(t *testing.T) {
+	result := &scanResult{
+		files:         []sourceFile{{Path: "main.go", Language: "Go", Size: 12, Tokens: 3, Content: "package main"}},
+		skippedFiles:  []skippedFile{{Path: "huge.go", Size: 2048}},
+		totalFileSize: 12,
+		totalTokens:   3,
+	}
+
+	output := renderDocument(t, FormatJSONL, "", "repo", ". (repo)", result)
+	lines := strings.Split(output, "\n")
+	require.Len(t, lines, 4)
+
+	var records []map[string]interface{}
+	for _, line := range lines {
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record), line)
+		records = append(records, record)
+	}
+
+	assert.Equal(t, "tree", records[0]["type"])
+	assert.Equal(t, "file", records[1]["type"])
+	assert.Equal(t, "main.go", records[1]["path"])
+	assert.Equal(t, "Go", records[1]["language"])
+	assert.EqualValues(t, 12, records[1]["size"])
+	assert.Equal(t, "package main", records[1]["content"])
+	assert.Equal(t, "skipped", records[2]["type"])
+	assert.Equal(t, "huge.go", records[2]["path"])
+	assert.EqualValues(t, 2048, records[2]["size"])
+	assert.Equal(t, "summary", records[3]["type"])
+	assert.EqualValues(t, 1, records[3]["file_count"])
+	assert.Equal(t, false, records[3]["limit_hit"])
+}
+
+func TestDocumentWriters_Prompt(t *testing.T) {
+	t.Run("markdown", func(t *testing.T) {
+		got := renderDocument(t, FormatMarkdown, "Explain", "repo", ". (repo)", &scanResult{})
+		assert.True(t, strings.HasPrefix(got, "Explain\n\n## Project Structure\n"), got)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		got := renderDocument(t, FormatJSON, "Explain <this>", "repo", "", &scanResult{})
+		var doc jsonDocument
+		require.NoError(t, json.Unmarshal([]byte(got), &doc))
+		assert.Equal(t, "Explain <this>", doc.Prompt)
+		assert.Contains(t, got, "Explain <this>")
+	})
+
+	t.Run("jsonl", func(t *testing.T) {
+		got := renderDocument(t, FormatJSONL, "Explain", "repo", "", &scanResult{})
+		assert.True(t, strings.HasPrefix(got, "{\"type\":\"prompt\",\"text\":\"Explain\"}\n"), got)
+	})
+
+	t.Run("xml", func(t *testing.T) {
+		got := renderDocument(t, FormatXML, "Review <all> & fix", "repo", "", &scanResult{})
+		assert.True(t, strings.HasPrefix(got, "<prompt>\nReview &lt;all&gt; &amp; fix\n</prompt>\n\n<project name=\"repo\">\n"), got)
+	})
+
+	t.Run("empty prompt", func(t *testing.T) {
+		got := renderDocument(t, FormatJSON, "", "repo", "", &scanResult{})
+		assert.NotContains(t, got, `"prompt"`)
+	})
+}
+
+func TestWriteProjectSummary_ReturnsScanError(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	var streamed strings.Builder
+	err := WriteProjectSummary(&streamed, "", missing, 5, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, false, nil)
+	assert.Error(t, err)
+	assert.Empty(t, streamed.String(), "nothing is written when the folder cannot be scanned")
+}
+
+func TestProcessSourceFiles_JSONFormat(t *testing.T) {
+	withOutputFormat(t, FormatJSON)
+	origMaxFileSize := MaxFileSizeBytes
+	t.Cleanup(func() { MaxFileSizeBytes = origMaxFileSize })
+	MaxFileSizeBytes = 20
+
+	tempDir := t.TempDir()
+	createTestFile(t, filepath.Join(tempDir, "main.go"), "package main\n")
+	createTestFile(t, filepath.Join(tempDir, "pkg", "big.go"), strings.Repeat("x", 64))
+
+	output := ProcessSourceFiles(tempDir, 5, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, false, nil)
+
+	var doc jsonDocument
+	require.NoError(t, json.Unmarshal([]byte(output), &doc), output)
+	assert.Equal(t, filepath.Base(tempDir), doc.Root)
+	assert.Contains(t, doc.Tree, "├── main.go")
+	require.Len(t, doc.Files, 1)
+	assert.Equal(t, "main.go", doc.Files[0].Path)
+	assert.Equal(t, "package main\n", doc.Files[0].Content)
+	assert.Equal(t, []skippedFile{{Path: "pkg/big.go", Size: 64}}, doc.Skipped)
+	assert.NotContains(t, output, "```")
+}
+
+func TestCollectReadmeFiles_JSONLFormat(t *testing.T) {
+	withOutputFormat(t, FormatJSONL)
+
+	tempDir := t.TempDir()
+	createTestFile(t, filepath.Join(tempDir, "README.md"), "# Root\n")
+
+	output := CollectReadmeFiles(tempDir, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, nil)
+	lines := strings.Split(output, "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"type":"file"`)
+	assert.Contains(t, lines[0], `"path":"README.md"`)
+	assert.Contains(t, lines[0], `"language":"Markdown"`)
+	assert.Contains(t, lines[1], `"type":"summary"`)
+}
+
+func TestBuildXMLOutput(t *testing.T) {
+	result := &scanResult{
+		files: []sourceFile{
+			{Path: "z.py", Language: "Python", Size: 5, Tokens: 2, Content: "x = 1"},
+			{Path: "a&b.go", Language: "Go", Size: 40, Tokens: 9, Content: "// ```\nif a < b && c > d {}\n</file>", Status: "modified", Patch: "+<x>"},
+		},
+		skippedFiles:  []skippedFile{{Path: "big\".go", Size: 99}},
+		totalFileSize: 45,
+		totalTokens:   11,
+	}
+
+	output := renderDocument(t, FormatXML, "", "repo", ". (repo)\n└── <odd>", result)
+
+	assert.True(t, strings.HasPrefix(output, "<project name=\"repo\">\n<tree>\n. (repo)\n└── &lt;odd&gt;\n</tree>\n"))
+	assert.Contains(t, output, "<file path=\"a&amp;b.go\" language=\"Go\" size=\"40\" tokens=\"9\" status=\"modified\">\n// ```\nif a &lt; b &amp;&amp; c &gt; d {}\n&lt;/file&gt;\n</file>\n")
+	assert.Contains(t, output, "<patch path=\"a&amp;b.go\">\n+&lt;x&gt;\n</patch>\n")
+	assert.Contains(t, output, "<skipped path=\"big&quot;.go\" size=\"99\"/>")
+	assert.Contains(t, output, "<summary files=\"2\" total-size=\"45\" total-tokens=\"11\" limit-hit=\"false\" token-limit-hit=\"false\"/>")
+	assert.True(t, strings.HasSuffix(output, "</project>"))
+	assert.Equal(t, 2, strings.Count(output, "</file>"), "escaped content must not close the tag")
+	assert.Less(t, strings.Index(output, `path="a&amp;b.go"`), strings.Index(output, `path="z.py"`), "files follow the Markdown ordering")
+}
+
+func TestProcessSourceFiles_XMLFormat(t *testing.T) {
+	withOutputFormat(t, FormatXML)
+
+	tempDir := t.TempDir()
+	createTestFile(t, filepath.Join(tempDir, "main.go"), "package main\n")
+	createTestFile(t, filepath.Join(tempDir, "app.py"), "print('<hi>')\n")
+
+	output := ProcessSourceFiles(tempDir, 5, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, false, nil)
+
+	assert.Contains(t, output, "<tree>\n. (")
+	assert.Contains(t, output, "<file path=\"main.go\" language=\"Go\"")
+	assert.Contains(t, output, "print('&lt;hi&gt;')")
+	assert.Less(t, strings.Index(output, `path="main.go"`), strings.Index(output, `path="app.py"`))
+	assert.NotContains(t, output, "```")
+}
+
+func TestIsProjectOutput(t *testing.T) {
+	tempDir := t.TempDir()
+	createTestFile(t, filepath.Join(tempDir, "main.go"), "package main\n")
+	createTestFile(t, filepath.Join(tempDir, "README.md"), "# App\n\n## Project Structure\n\nThe code is in main.go.\n")
+	outputPath := filepath.Join(t.TempDir(), "context")
+
+	for _, format := range []string{FormatMarkdown, FormatJSON, FormatJSONL, FormatXML} {
+		for _, readmeOnly := range []bool{false, true} {
+			withOutputFormat(t, format)
+			var b strings.Builder
+			if readmeOnly {
+				require.NoError(t, WriteReadmeFiles(&b, "Explain this", tempDir, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, nil))
+			} else {
+				require.NoError(t, WriteProjectSummary(&b, "Explain this", tempDir, 5, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, false, nil))
+			}
+			createTestFile(t, outputPath, b.String())
+			assert.True(t, IsProjectOutput(tempDir, outputPath), "%s output, readme only: %v", format, readmeOnly)
+			if !readmeOnly && format != FormatJSONL {
+				assert.False(t, IsProjectOutput(filepath.Join(t.TempDir(), "other"), outputPath), "%s output of another folder", format)
+			}
+		}
+	}
+
+	assert.False(t, IsProjectOutput(tempDir, filepath.Join(tempDir, "main.go")))
+	assert.False(t, IsProjectOutput(tempDir, filepath.Join(tempDir, "README.md")))
+	assert.False(t, IsProjectOutput(tempDir, filepath.Join(tempDir, "missing.md")))
+}
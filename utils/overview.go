@@ -0,0 +1,225 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// detectedEcosystem is a language or framework identified by PROJECT_SIGNATURES.
+// Source is "signature" for signature files and directories such as go.mod or
+// node_modules, and "extension" for extension signatures such as ".html".
+type detectedEcosystem struct {
+	Language string `json:"language"`
+	Source   string `json:"source"`
+	Files    int    `json:"files,omitempty"`
+}
+
+// languageStats is the per-language breakdown of the collected source files.
+type languageStats struct {
+	Language string `json:"language"`
+	Files    int    `json:"files"`
+	Lines    int    `json:"lines"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// projectOverview orients the reader before the tree and the code. The
+// ecosystems are detected before the scan, so it can lead the output.
+type projectOverview struct {
+	Ecosystems []detectedEcosystem `json:"ecosystems"`
+}
+
+// overviewIgnoredSignatures are signatures too common to characterize a project.
+var overviewIgnoredSignatures = map[string]struct{}{
+	"README.md": {},
+}
+
+// detectProjectLanguages walks folderAbs for PROJECT_SIGNATURES entries.
+// primaryLangs are the languages with a signature file or directory (go.mod,
+// package.json, node_modules, ...); fallbackLangs counts the files matching
+// extension signatures of the remaining languages. Include filters are not
+// applied so that the whole project is characterized, but excluded and
+// gitignored paths are skipped.
+func detectProjectLanguages(folderAbs string, excludeNames map[string]struct{}, excludeMatcher *SimpleMatcher, debug bool, gi *GitIgnoreMatcher) ([]string, map[string]int) {
+	nameSignatures := make(map[string][]string)
+	extensionSignatures := make(map[string][]string)
+	for lang, signatures := range PROJECT_SIGNATURES {
+		for _, signature := range signatures {
+			if _, ok := overviewIgnoredSignatures[signature]; ok {
+				continue
+			}
+			if strings.HasPrefix(signature, ".") {
+				extensionSignatures[signature] = append(extensionSignatures[signature], lang)
+			} else {
+				nameSignatures[signature] = append(nameSignatures[signature], lang)
+			}
+		}
+	}
+
+	primary := make(map[string]struct{})
+	extensionCounts := make(map[string]int)
+	walkErr := filepath.WalkDir(folderAbs, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			PrintWarning(fmt.Sprintf("Error accessing path %s: %v", path, err), debug)
+			return nil
+		}
+		if path == folderAbs {
+			return nil
+		}
+
+		// Directory signatures such as node_modules count even though the
+		// directory itself is excluded from the scan.
+		for _, lang := range nameSignatures[d.Name()] {
+			primary[lang] = struct{}{}
+		}
+		if shouldSkipPath(path, d.Name(), d.IsDir(), nil, nil, excludeNames, excludeMatcher, gi) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			for _, lang := range extensionSignatures[strings.ToLower(filepath.Ext(d.Name()))] {
+				extensionCounts[lang]++
+			}
+		}
+		return nil
+	})
+	if walkErr != nil {
+		PrintWarning(fmt.Sprintf("Error during language detection: %v", walkErr), debug)
+	}
+
+	primaryLangs := make([]string, 0, len(primary))
+	for lang := range primary {
+		primaryLangs = append(primaryLangs, lang)
+	}
+	sort.Strings(primaryLangs)
+
+	fallbackLangs := make(map[string]int)
+	for lang, count := range extensionCounts {
+		if _, ok := primary[lang]; !ok {
+			fallbackLangs[lang] = count
+		}
+	}
+	PrintDebug(fmt.Sprintf("Detected ecosystems: %v, by extension: %v", primaryLangs, fallbackLangs), debug)
+	return primaryLangs, fallbackLangs
+}
+
+// newProjectOverview reports the languages found by detectProjectLanguages.
+// It returns nil when nothing was detected.
+func newProjectOverview(primaryLangs []string, fallbackLangs map[string]int) *projectOverview {
+	overview := &projectOverview{Ecosystems: []detectedEcosystem{}}
+	for _, lang := range primaryLangs {
+		overview.Ecosystems = append(overview.Ecosystems, detectedEcosystem{Language: lang, Source: "signature"})
+	}
+	fallback := make([]detectedEcosystem, 0, len(fallbackLangs))
+	for lang, count := range fallbackLangs {
+		fallback = append(fallback, detectedEcosystem{Language: lang, Source: "extension", Files: count})
+	}
+	sort.Slice(fallback, func(i, j int) bool {
+		if fallback[i].Files != fallback[j].Files {
+			return fallback[i].Files > fallback[j].Files
+		}
+		return fallback[i].Language < fallback[j].Language
+	})
+	overview.Ecosystems = append(overview.Ecosystems, fallback...)
+
+	if len(overview.Ecosystems) == 0 {
+		return nil
+	}
+	return overview
+}
+
+// languageBreakdown returns the collected files tallied by addLanguageStats,
+// largest language first.
+func languageBreakdown(byLanguage map[string]*languageStats) []languageStats {
+	languages := make([]languageStats, 0, len(byLanguage))
+	for _, stats := range byLanguage {
+		languages = append(languages, *stats)
+	}
+	sort.Slice(languages, func(i, j int) bool {
+		if languages[i].Bytes != languages[j].Bytes {
+			return languages[i].Bytes > languages[j].Bytes
+		}
+		return languages[i].Language < languages[j].Language
+	})
+	return languages
+}
+
+// addLanguageStats counts a collected file in the breakdown of its language.
+// Files are tallied as they are collected, so the breakdown does not need
+// their content once they have been written.
+func addLanguageStats(byLanguage map[string]*languageStats, file sourceFile) {
+	stats, ok := byLanguage[file.Language]
+	if !ok {
+		stats = &languageStats{Language: file.Language}
+		byLanguage[file.Language] = stats
+	}
+	stats.Files++
+	stats.Lines += countLines(file.Content)
+	stats.Bytes += file.Size
+}
+
+// countLines counts lines the way editors number them; a trailing newline
+// does not start another line.
+func countLines(content string) int {
+	if content == "" {
+		return 0
+	}
+	lines := strings.Count(content, "\n")
+	if !strings.HasSuffix(content, "\n") {
+		lines++
+	}
+	return lines
+}
+
+// buildOverviewMarkdown renders the "Project Overview" section.
+func buildOverviewMarkdown(overview *projectOverview) string {
+	if overview == nil {
+		return ""
+	}
+	lines := []string{"## Project Overview\n"}
+
+	var signatureLangs, extensionLangs []string
+	for _, ecosystem := range overview.Ecosystems {
+		if ecosystem.Source == "signature" {
+			signatureLangs = append(signatureLangs, ecosystem.Language)
+		} else {
+			noun := "files"
+			if ecosystem.Files == 1 {
+				noun = "file"
+			}
+			extensionLangs = append(extensionLangs, fmt.Sprintf("%s (%d %s)", ecosystem.Language, ecosystem.Files, noun))
+		}
+	}
+	if len(signatureLangs) > 0 {
+		lines = append(lines, fmt.Sprintf("**Detected ecosystems**: %s\n", strings.Join(signatureLangs, ", ")))
+	}
+	if len(extensionLangs) > 0 {
+		lines = append(lines, fmt.Sprintf("**Detected by file extension**: %s\n", strings.Join(extensionLangs, ", ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// buildLanguageBreakdownMarkdown renders the "Language Breakdown" section.
+// The files are counted as they are written, so it follows them.
+func buildLanguageBreakdownMarkdown(languages []languageStats) string {
+	if len(languages) == 0 {
+		return ""
+	}
+	lines := []string{"## Language Breakdown\n", "| Language | Files | Lines | Bytes |", "| --- | ---: | ---: | ---: |"}
+	var total languageStats
+	for _, stats := range languages {
+		lines = append(lines, fmt.Sprintf("| %s | %d | %d | %d |", stats.Language, stats.Files, stats.Lines, stats.Bytes))
+		total.Files += stats.Files
+		total.Lines += stats.Lines
+		total.Bytes += stats.Bytes
+	}
+	if len(languages) > 1 {
+		lines = append(lines, fmt.Sprintf("| **Total** | %d | %d | %d |", total.Files, total.Lines, total.Bytes))
+	}
+	lines = append(lines, "")
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectProjectLanguages(t *testing.T) {
+	tempDir := t.TempDir()
+	createTestFile(t, filepath.Join(tempDir, "go.mod"), "module app\n")
+	createTestFile(t, filepath.Join(tempDir, "web", "package.json"), "{}")
+	createTestFile(t, filepath.Join(tempDir, "web", "tsconfig.json"), "{}")
+	createTestFile(t, filepath.Join(tempDir, "templates", "index.html"), "<html></html>")
+	createTestFile(t, filepath.Join(tempDir, "templates", "about.html"), "<html></html>")
+	createTestFile(t, filepath.Join(tempDir, "db", "schema.sql"), "SELECT 1;")
+	createTestFile(t, filepath.Join(tempDir, "node_modules", "x", "y.sql"), "SELECT 1;")
+	createTestFile(t, filepath.Join(tempDir, "README.md"), "# App\n")
+
+	primaryLangs, fallbackLangs := detectProjectLanguages(tempDir, DefaultExcludeNames, nil, false, nil)
+
+	// node_modules is excluded from the scan but still signals Javascript.
+	assert.Equal(t, []string{"Go", "Javascript", "Typescript"}, primaryLangs)
+	assert.Equal(t, map[string]int{"HTML": 2, "SQL": 1}, fallbackLangs)
+}
+
+func TestDetectProjectLanguages_IgnoresIncludeFilters(t *testing.T) {
+	tempDir := t.TempDir()
+	createTestFile(t, filepath.Join(tempDir, "Cargo.toml"), "[package]\n")
+	createTestFile(t, filepath.Join(tempDir, "secret", "go.mod"), "module secret\n")
+	excludeMatcher, err := NewSimpleMatcher(tempDir, []string{"secret"})
+	require.NoError(t, err)
+
+	primaryLangs, _ := detectProjectLanguages(tempDir, map[string]struct{}{}, excludeMatcher, false, nil)
+	assert.Equal(t, []string{"Rust"}, primaryLangs)
+}
+
+func tallyLanguages(files []sourceFile) map[string]*languageStats {
+	byLanguage := make(map[string]*languageStats)
+	for _, file := range files {
+		addLanguageStats(byLanguage, file)
+	}
+	return byLanguage
+}
+
+func TestNewProjectOverview(t *testing.T) {
+	files := []sourceFile{
+		{Path: "a.go", Language: "Go", Size: 20, Content: "package a\n\nfunc A() {}\n"},
+		{Path: "b.go", Language: "Go", Size: 9, Content: "package b"},
+		{Path: "s.py", Language: "Python", Size: 40, Content: "x = 1\ny = 2\n"},
+	}
+	overview := newProjectOverview([]string{"Go"}, map[string]int{"SQL": 1, "HTML": 3})
+	require.NotNil(t, overview)
+
+	assert.Equal(t, []detectedEcosystem{
+		{Language: "Go", Source: "signature"},
+		{Language: "HTML", Source: "extension", Files: 3},
+		{Language: "SQL", Source: "extension", Files: 1},
+	}, overview.Ecosystems)
+	assert.Equal(t, []languageStats{
+		{Language: "Python", Files: 1, Lines: 2, Bytes: 40},
+		{Language: "Go", Files: 2, Lines: 4, Bytes: 29},
+	}, languageBreakdown(tallyLanguages(files)))
+
+	assert.Nil(t, newProjectOverview(nil, nil))
+}
+
+func TestBuildOverviewMarkdown(t *testing.T) {
+	overview := newProjectOverview([]string{"Go", "Javascript"}, map[string]int{"SQL": 1})
+	expected := strings.Join([]string{
+		"## Project Overview\n",
+		"**Detected ecosystems**: Go, Javascript\n",
+		"**Detected by file extension**: SQL (1 file)\n",
+	}, "\n")
+	assert.Equal(t, expected, buildOverviewMarkdown(overview))
+	assert.Empty(t, buildOverviewMarkdown(nil))
+}
+
+func TestBuildLanguageBreakdownMarkdown(t *testing.T) {
+	languages := languageBreakdown(tallyLanguages([]sourceFile{
+		{Language: "Go", Size: 10, Content: "package a\n"},
+		{Language: "SQL", Size: 9, Content: "SELECT 1;"},
+	}))
+	expected := strings.Join([]string{
+		"## Language Breakdown\n",
+		"| Language | Files | Lines | Bytes |",
+		"| --- | ---: | ---: | ---: |",
+		"| Go | 1 | 1 | 10 |",
+		"| SQL | 1 | 1 | 9 |",
+		"| **Total** | 2 | 2 | 19 |",
+		"",
+	}, "\n")
+	assert.Equal(t, expected, buildLanguageBreakdownMarkdown(languages))
+	assert.Empty(t, buildLanguageBreakdownMarkdown(nil))
+}
+
+func TestCountLines(t *testing.T) {
+	assert.Equal(t, 0, countLines(""))
+	assert.Equal(t, 1, countLines("a"))
+	assert.Equal(t, 1, countLines("a\n"))
+	assert.Equal(t, 2, countLines("a\nb"))
+	assert.Equal(t, 3, countLines("\n\n\n"))
+}
+
+func TestProcessSourceFiles_ProjectOverview(t *testing.T) {
+	tempDir := t.TempDir()
+	createTestFile(t, filepath.Join(tempDir, "go.mod"), "module app\n")
+	createTestFile(t, filepath.Join(tempDir, "main.go"), "package main\n\nfunc main() {}\n")
+
+	output := ProcessSourceFiles(tempDir, 5, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, false, nil)
+	overviewIdx := strings.Index(output, "## Project Overview")
+	structureIdx := strings.Index(output, "## Project Structure")
+	require.NotEqual(t, -1, overviewIdx, output)
+	assert.Less(t, overviewIdx, structureIdx, "the overview comes before the tree")
+	assert.Contains(t, output, "**Detected ecosystems**: Go\n")
+	breakdownIdx := strings.Index(output, "## Language Breakdown")
+	assert.Less(t, strings.Index(output, "### main.go"), breakdownIdx, "the breakdown follows the files")
+	assert.Contains(t, output, "| Go | 1 | 3 | 29 |")
+
+	withOutputFormat(t, FormatJSON)
+	output = ProcessSourceFiles(tempDir, 5, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, false, nil)
+	var doc jsonDocument
+	require.NoError(t, json.Unmarshal([]byte(output), &doc), output)
+	require.NotNil(t, doc.Overview)
+	assert.Equal(t, []detectedEcosystem{{Language: "Go", Source: "signature"}}, doc.Overview.Ecosystems)
+	assert.Equal(t, []languageStats{{Language: "Go", Files: 1, Lines: 3, Bytes: 29}}, doc.Languages)
+	assert.Less(t, strings.Index(output, `"overview"`), strings.Index(output, `"files"`), "the overview comes before the files")
+
+	withOutputFormat(t, FormatJSONL)
+	output = ProcessSourceFiles(tempDir, 5, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, false, nil)
+	var types []string
+	for _, line := range strings.Split(output, "\n") {
+		var record struct {
+			Type string `json:"type"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(line), &record), line)
+		types = append(types, record.Type)
+	}
+	assert.Equal(t, []string{"tree", "overview", "file", "dependency", "languages", "summary"}, types)
+
+	withOutputFormat(t, FormatXML)
+	output = ProcessSourceFiles(tempDir, 5, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, false, nil)
+	assert.Contains(t, output, "</tree>\n<overview>\n<ecosystem language=\"Go\" source=\"signature\"/>\n</overview>\n<file ")
+	assert.Contains(t, output, "<languages>\n<language name=\"Go\" files=\"1\" lines=\"3\" bytes=\"29\"/>\n</languages>\n<summary ")
+}
+
+func TestWriteProjectSummary_DebugSizeCheckLeads(t *testing.T) {
+	tempDir := t.TempDir()
+	createTestFile(t, filepath.Join(tempDir, "go.mod"), "module app\n")
+	createTestFile(t, filepath.Join(tempDir, "main.go"), "package main\n")
+
+	var b strings.Builder
+	require.NoError(t, WriteProjectSummary(&b, "", tempDir, 5, map[string]struct{}{}, nil, map[string]struct{}{}, nil, true, false, nil))
+	output := b.String()
+	sizeCheckIdx := strings.Index(output, "## Source Code Size Check")
+	require.NotEqual(t, -1, sizeCheckIdx, output)
+	assert.Less(t, sizeCheckIdx, strings.Index(output, "## Project Overview"), "the size check comes first")
+	assert.Less(t, strings.Index(output, "## Project Overview"), strings.Index(output, "## Project Structure"))
+	assert.Contains(t, output, "- `main.go`: ", "the size check covers the files written after it")
+}
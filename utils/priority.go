@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Score weights for ranking source files when a size or token budget is set.
+// A "priority" rule in .list-codes.yaml without a weight adds
+// defaultPriorityWeight, which outranks every built-in signal.
+const (
+	entrypointPriority    = 50
+	readmePriority        = 40
+	recentChangePriority  = 30
+	defaultPriorityWeight = 100
+
+	// Files larger than sizePenaltyFreeBytes lose sizePenaltyPerDoubling
+	// points each time their size doubles, so that small files fill the
+	// budget before one large file takes it.
+	sizePenaltyFreeBytes   = 4 * 1024
+	sizePenaltyPerDoubling = 5
+
+	// recentCommitDepth bounds how many commits are read for recency.
+	recentCommitDepth = 100
+)
+
+// entrypointNames are file names that usually start a program or a library.
+var entrypointNames = map[string]struct{}{
+	"main.go": {}, "main.py": {}, "__main__.py": {}, "app.py": {}, "manage.py": {},
+	"index.js": {}, "index.ts": {}, "main.js": {}, "main.ts": {}, "server.js": {}, "server.ts": {},
+	"main.rs": {}, "lib.rs": {}, "Main.java": {}, "Application.java": {}, "Program.cs": {},
+	"main.c": {}, "main.cpp": {}, "main.swift": {}, "main.kt": {}, "main.dart": {},
+}
+
+// priorityRule is a "priority" glob from .list-codes.yaml with its weight.
+type priorityRule struct {
+	pattern string
+	re      *regexp.Regexp
+	weight  int
+}
+
+var priorityRules []priorityRule
+
+// AddPriorityPattern registers a glob, relative to the scanned folder, whose
+// files gain weight points when a budget forces a choice. A pattern without
+// "/" matches file names at any depth; a zero weight means
+// defaultPriorityWeight and a negative weight demotes the files.
+func AddPriorityPattern(pattern string, weight int) error {
+	pattern = strings.TrimPrefix(filepath.ToSlash(strings.TrimSpace(pattern)), "./")
+	if pattern == "" {
+		return fmt.Errorf("empty priority pattern")
+	}
+	if weight == 0 {
+		weight = defaultPriorityWeight
+	}
+	expr := doubleStarPatternToRegexp(pattern)
+	if !strings.Contains(pattern, "/") {
+		expr = "(?:^|/)" + strings.TrimPrefix(expr, "^")
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return fmt.Errorf("invalid priority pattern '%s': %w", pattern, err)
+	}
+	priorityRules = append(priorityRules, priorityRule{pattern: pattern, re: re, weight: weight})
+	return nil
+}
+
+// budgetActive reports whether a size or token budget may leave files out.
+func budgetActive() bool {
+	return TotalMaxFileSizeBytes > 0 || MaxTokens > 0
+}
+
+// sourceCandidate is a source file that passed the filters and waits for
+// the budget.
+type sourceCandidate struct {
+	absPath  string
+	language string
+	info     os.FileInfo
+	priority int
+	// binary is set when the walk found the file to hold binary data.
+	binary bool
+}
+
+// filePriority scores a file by the signals that make it worth a place in a
+// limited budget. relPath uses forward slashes; recency is between 0 for
+// untouched files and 1 for uncommitted changes.
+func filePriority(relPath string, size int64, recency float64) int {
+	score := 0
+	name := path.Base(relPath)
+	if _, ok := entrypointNames[name]; ok || strings.HasPrefix(relPath, "cmd/") {
+		score += entrypointPriority
+	}
+	if strings.HasPrefix(strings.ToLower(name), "readme") {
+		score += readmePriority
+	}
+	score += int(math.Round(recency * recentChangePriority))
+	if size > sizePenaltyFreeBytes {
+		score -= int(math.Round(math.Log2(float64(size)/sizePenaltyFreeBytes) * sizePenaltyPerDoubling))
+	}
+	for _, rule := range priorityRules {
+		if rule.re.MatchString(relPath) {
+			score += rule.weight
+		}
+	}
+	return score
+}
+
+// rankSourceCandidates scores candidates and orders them highest first.
+// Ties keep the walk order, so equally scored files are taken by path.
+func rankSourceCandidates(rootPath string, candidates []sourceCandidate, debug bool) {
+	recency := loadRecentChanges(rootPath, debug)
+	for i := range candidates {
+		relPath := relativeDisplayPath(rootPath, candidates[i].absPath, debug)
+		candidates[i].priority = filePriority(relPath, candidates[i].info.Size(), recencyOf(recency, candidates[i].absPath))
+		PrintDebug(fmt.Sprintf("Priority of '%s': %d", relPath, candidates[i].priority), debug)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].priority > candidates[j].priority
+	})
+}
+
+// recencyOf looks absPath up in recency, which holds paths below the
+// symlink-resolved repository root.
+func recencyOf(recency map[string]float64, absPath string) float64 {
+	if value, ok := recency[absPath]; ok {
+		return value
+	}
+	if resolved, err := filepath.EvalSymlinks(absPath); err == nil {
+		return recency[resolved]
+	}
+	return 0
+}
+
+// loadRecentChanges maps files of the git repository containing rootPath to
+// how recently they changed: 1 for uncommitted changes, and from just under
+// 1 down to 0 along the last recentCommitDepth commits. Outside a repository
+// the map is empty.
+func loadRecentChanges(rootPath string, debug bool) map[string]float64 {
+	recency := make(map[string]float64)
+	topLevel, err := runGit(rootPath, "rev-parse", "--show-toplevel")
+	if err != nil {
+		PrintDebug("Not a git repository; file recency is not used for priority", debug)
+		return recency
+	}
+	repoRoot := strings.TrimSpace(topLevel)
+	if resolved, err := filepath.EvalSymlinks(repoRoot); err == nil {
+		repoRoot = resolved
+	}
+	toAbs := func(rel string) string {
+		return filepath.Join(repoRoot, filepath.FromSlash(rel))
+	}
+
+	if status, err := runGit(repoRoot, "status", "--porcelain", "-z", "--untracked-files=all"); err == nil {
+		entries := strings.Split(status, "\x00")
+		for i := 0; i < len(entries); i++ {
+			entry := entries[i]
+			if len(entry) < 4 {
+				continue
+			}
+			recency[toAbs(entry[3:])] = 1
+			// Renames and copies are followed by their old path.
+			if entry[0] == 'R' || entry[0] == 'C' {
+				i++
+			}
+		}
+	}
+
+	log, err := runGit(repoRoot, "-c", "core.quotePath=false", "log", fmt.Sprintf("-n%d", recentCommitDepth), "--format=%x00", "--name-only")
+	if err != nil {
+		PrintDebug(fmt.Sprintf("Could not read git history for priority: %v", err), debug)
+		return recency
+	}
+	commits := strings.Split(log, "\x00")[1:]
+	for i, commit := range commits {
+		value := 1 - float64(i+1)/float64(len(commits)+1)
+		for _, rel := range strings.Split(commit, "\n") {
+			if rel = strings.TrimSpace(rel); rel == "" {
+				continue
+			}
+			if _, seen := recency[toAbs(rel)]; !seen {
+				recency[toAbs(rel)] = value
+			}
+		}
+	}
+	return recency
+}
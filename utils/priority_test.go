@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilePriority(t *testing.T) {
+	orig := priorityRules
+	t.Cleanup(func() { priorityRules = orig })
+	priorityRules = nil
+
+	assert.Equal(t, entrypointPriority, filePriority("main.go", 100, 0))
+	assert.Equal(t, entrypointPriority, filePriority("cmd/tool/run.go", 100, 0))
+	assert.Equal(t, readmePriority, filePriority("docs/README.md", 100, 0))
+	assert.Equal(t, recentChangePriority, filePriority("lib/util.go", 100, 1))
+	assert.Equal(t, 0, filePriority("lib/util.go", sizePenaltyFreeBytes, 0))
+	assert.Equal(t, -2*sizePenaltyPerDoubling, filePriority("lib/util.go", 4*sizePenaltyFreeBytes, 0))
+
+	require.NoError(t, AddPriorityPattern("internal/core/**", 0))
+	require.NoError(t, AddPriorityPattern("*.pb.go", -80))
+	assert.Equal(t, defaultPriorityWeight, filePriority("internal/core/store/db.go", 100, 0))
+	assert.Equal(t, -80, filePriority("api/v1/service.pb.go", 100, 0))
+	assert.Equal(t, 0, filePriority("internal/coreutil.go", 100, 0))
+	assert.Error(t, AddPriorityPattern("  ", 10))
+}
+
+func TestLoadRecentChanges(t *testing.T) {
+	dir := initGitRepoForTest(t)
+	createTestFile(t, filepath.Join(dir, "lib", "util.go"), "package lib\n\nfunc Util() {}\n")
+	gitForTest(t, dir, "commit", "-q", "-am", "update util")
+	createTestFile(t, filepath.Join(dir, "new.go"), "package main\n")
+
+	recency := loadRecentChanges(dir, false)
+	newest := recencyOf(recency, filepath.Join(dir, "new.go"))
+	util := recencyOf(recency, filepath.Join(dir, "lib", "util.go"))
+	old := recencyOf(recency, filepath.Join(dir, "lib", "old.go"))
+	assert.Equal(t, 1.0, newest)
+	assert.Greater(t, util, old)
+	assert.Greater(t, old, 0.0)
+
+	assert.Empty(t, loadRecentChanges(t.TempDir(), false))
+}
+
+func TestCollectSourceFiles_PriorityBudget(t *testing.T) {
+	origTotal, origRules, origFormat := TotalMaxFileSizeBytes, priorityRules, OutputFormat
+	t.Cleanup(func() { TotalMaxFileSizeBytes, priorityRules, OutputFormat = origTotal, origRules, origFormat })
+
+	tempDir := t.TempDir()
+	createTestFile(t, filepath.Join(tempDir, "a_helpers.go"), strings.Repeat("x", 300))
+	createTestFile(t, filepath.Join(tempDir, "b_small.go"), strings.Repeat("x", 50))
+	createTestFile(t, filepath.Join(tempDir, "main.go"), strings.Repeat("x", 300))
+	createTestFile(t, filepath.Join(tempDir, "z_core.go"), strings.Repeat("x", 300))
+
+	// Walk order would take a_helpers.go and b_small.go first; main.go and
+	// the configured z_core.go outrank them, and b_small.go still fits.
+	priorityRules = nil
+	require.NoError(t, AddPriorityPattern("z_core.go", 0))
+	TotalMaxFileSizeBytes = 700
+	result := collectSourceFiles(tempDir, nil, nil, map[string]struct{}{}, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, false, nil)
+
+	sourceMarkdown := flattenSourceMarkdownByLanguage(result.sourceFileContents)
+	assert.Contains(t, sourceMarkdown, "### main.go")
+	assert.Contains(t, sourceMarkdown, "### z_core.go")
+	assert.Contains(t, sourceMarkdown, "### b_small.go")
+	assert.NotContains(t, sourceMarkdown, "a_helpers.go")
+	assert.True(t, result.limitHit)
+	require.Len(t, result.omittedFiles, 1)
+	assert.Equal(t, omittedFile{Path: "a_helpers.go", Size: 300, Priority: 0}, result.omittedFiles[0])
+
+	output := buildMarkdownOutput("## Project Structure", map[string][]string{}, result, false)
+	assert.Contains(t, output, "## Omitted Files\n\n1 file(s) did not fit the budget and were left out, highest priority first:\n- `a_helpers.go` (300 bytes, priority 0)\n")
+
+	var doc jsonDocument
+	require.NoError(t, json.Unmarshal([]byte(renderDocument(t, FormatJSON, "", "root", "", result)), &doc))
+	assert.Equal(t, result.omittedFiles, doc.Omitted)
+}
@@ -1,30 +1,97 @@
 package utils
 
 import (
+	"bufio"
 	"fmt"
+	"io"
+	"path/filepath"
 	"sort"
 	"strings"
 )
 
-// buildMarkdownOutput builds the final Markdown output.
-func buildMarkdownOutput(directoryStructureMD string, depFileContents map[string][]string, sourceFileContents map[string][]string, totalFileSize int64, skippedFileMessages []string, limitHit bool, debug bool) string {
-	var outputMDParts []string
+// markdownDocumentWriter writes the Markdown output: the size check in
+// debug mode, the overview and the structure, the files, and the sections
+// that summarize the collection. Sections are separated by a blank line.
+type markdownDocumentWriter struct {
+	w       *bufio.Writer
+	debug   bool
+	started bool
+	files   int
+}
 
-	hasSourceFiles := false
-	for lang, contents := range sourceFileContents {
-		if lang != DependencyFilesCategory && len(contents) > 0 {
-			hasSourceFiles = true
-			break
+func (d *markdownDocumentWriter) part(text string) {
+	if d.started {
+		d.w.WriteString("\n")
+	}
+	d.w.WriteString(text)
+	d.started = true
+}
+
+func (d *markdownDocumentWriter) begin(prompt, rootName string, treeLines []string, result *scanResult) {
+	if prompt != "" {
+		d.w.WriteString(prompt + "\n\n")
+	}
+	d.head(directoryStructureMarkdown(treeLines), result)
+}
+
+// head writes the sections before the files. The size check describes the
+// whole collection, so in debug mode the files are collected before head.
+func (d *markdownDocumentWriter) head(directoryStructureMD string, result *scanResult) {
+	if d.debug {
+		d.sizeCheck(result)
+	}
+
+	// Orient the reader with the detected ecosystems before the tree and code.
+	if overviewMD := buildOverviewMarkdown(result.overview); overviewMD != "" {
+		d.part(overviewMD)
+	}
+	d.part(directoryStructureMD)
+}
+
+func (d *markdownDocumentWriter) file(file sourceFile) {
+	d.renderedFile(renderMarkdownFile(file))
+}
+
+func (d *markdownDocumentWriter) renderedFile(markdown string) {
+	d.part(markdown)
+	d.files++
+}
+
+func (d *markdownDocumentWriter) end(result *scanResult) {
+	d.summary(dependencyFileContents(result.dependencies), result)
+}
+
+// summary writes the sections that follow the files.
+func (d *markdownDocumentWriter) summary(depFileContents map[string][]string, result *scanResult) {
+	// List what the budget left out so the reader knows the context is partial.
+	if omittedMD := buildOmittedFilesMarkdown(result.omittedFiles); omittedMD != "" {
+		d.part(omittedMD)
+	}
+
+	if len(depFileContents[DependencyFilesCategory]) > 0 {
+		d.part("## Dependency and Configuration Files\n")
+		sort.Strings(depFileContents[DependencyFilesCategory])
+		for _, content := range depFileContents[DependencyFilesCategory] {
+			d.part(content)
 		}
 	}
 
-	// Show Source Code Size Check section only in debug mode.
-	// This keeps default output concise while preserving diagnostics when needed.
-	if debug && (hasSourceFiles || len(skippedFileMessages) > 0) {
-		outputMDParts = append(outputMDParts, "## Source Code Size Check\n")
+	// The breakdown counts the files as they are written, so it follows them.
+	if breakdownMD := buildLanguageBreakdownMarkdown(languageBreakdown(result.languages)); breakdownMD != "" {
+		d.part(breakdownMD)
+	}
+}
+
+// sizeCheck writes the "Source Code Size Check" section. It is shown only
+// in debug mode, which keeps default output concise while preserving
+// diagnostics when needed.
+func (d *markdownDocumentWriter) sizeCheck(result *scanResult) {
+	skippedFileMessages := result.skippedFileMessages
+	if hasSourceFiles(result) || len(skippedFileMessages) > 0 || result.limitHit || result.tokenLimitHit {
+		d.part("## Source Code Size Check\n")
 
 		// Add file size statistics
-		fileSizeMB := float64(totalFileSize) / (1024 * 1024)
+		fileSizeMB := float64(result.totalFileSize) / (1024 * 1024)
 		maxFileSizeMB := float64(MaxFileSizeBytes) / (1024 * 1024)
 		totalLimitMB := float64(TotalMaxFileSizeBytes) / (1024 * 1024)
 
@@ -32,16 +99,16 @@ func buildMarkdownOutput(directoryStructureMD string, depFileContents map[string
 		statsParts = append(statsParts, fmt.Sprintf("%.2f MB collected", fileSizeMB))
 		statsParts = append(statsParts, fmt.Sprintf("max file: %.1f MB", maxFileSizeMB))
 
-		if limitHit {
-			statsParts = append(statsParts, fmt.Sprintf("scan stopped at %.2f MB total limit", totalLimitMB))
+		if result.limitHit {
+			statsParts = append(statsParts, fmt.Sprintf("%.2f MB total limit reached", totalLimitMB))
 		} else if TotalMaxFileSizeBytes > 0 {
 			statsParts = append(statsParts, fmt.Sprintf("max total: %.2f MB", totalLimitMB))
 		} else {
 			statsParts = append(statsParts, "max total: unlimited")
 		}
 
-		statsText := fmt.Sprintf("**File Statistics**: %s\n", strings.Join(statsParts, ", "))
-		outputMDParts = append(outputMDParts, statsText)
+		d.part(fmt.Sprintf("**File Statistics**: %s\n", strings.Join(statsParts, ", ")))
+		d.part(buildTokenStatsMarkdown(result))
 
 		if len(skippedFileMessages) > 0 {
 			sort.Strings(skippedFileMessages)
@@ -51,47 +118,212 @@ func buildMarkdownOutput(directoryStructureMD string, depFileContents map[string
 			for _, msg := range skippedFileMessages {
 				skippedList = append(skippedList, "- "+msg)
 			}
-			outputMDParts = append(outputMDParts, strings.Join(skippedList, "\n"))
+			d.part(strings.Join(skippedList, "\n"))
 		}
 
-		outputMDParts = append(outputMDParts, "\n")
+		d.part("\n")
+	}
+}
+
+// hasSourceFiles reports whether result holds a collected source file.
+func hasSourceFiles(result *scanResult) bool {
+	if len(result.files) > 0 {
+		return true
 	}
+	for lang, contents := range result.sourceFileContents {
+		if lang != DependencyFilesCategory && len(contents) > 0 {
+			return true
+		}
+	}
+	return false
+}
 
-	// Add directory structure as SECOND section (after Source Code Size Check)
-	outputMDParts = append(outputMDParts, directoryStructureMD)
+// buildMarkdownOutput renders a finished scan result whose files and
+// dependency files are already rendered as Markdown.
+func buildMarkdownOutput(directoryStructureMD string, depFileContents map[string][]string, result *scanResult, debug bool) string {
+	var b strings.Builder
+	w := bufio.NewWriter(&b)
+	doc := &markdownDocumentWriter{w: w, debug: debug}
+	doc.head(directoryStructureMD, result)
 
 	// Add source code files after directory structure (grouped internally by language for stable ordering)
-	if hasSourceFiles {
-		languages := make([]string, 0, len(sourceFileContents))
-		for lang := range sourceFileContents {
-			if lang != DependencyFilesCategory {
-				languages = append(languages, lang)
-			}
+	languages := make([]string, 0, len(result.sourceFileContents))
+	for lang := range result.sourceFileContents {
+		if lang != DependencyFilesCategory {
+			languages = append(languages, lang)
+		}
+	}
+	sort.Strings(languages)
+	for _, lang := range languages {
+		sort.Strings(result.sourceFileContents[lang])
+		for _, content := range result.sourceFileContents[lang] {
+			doc.renderedFile(content)
 		}
-		sort.Strings(languages)
+	}
 
-		for _, lang := range languages {
-			sort.Strings(sourceFileContents[lang])
-			outputMDParts = append(outputMDParts, sourceFileContents[lang]...)
+	doc.summary(depFileContents, result)
+	w.Flush()
+	return b.String()
+}
+
+// buildOmittedFilesMarkdown lists the files that did not fit the size or
+// token budget, highest priority first. It returns "" when none were omitted.
+func buildOmittedFilesMarkdown(omitted []omittedFile) string {
+	if len(omitted) == 0 {
+		return ""
+	}
+	lines := []string{
+		"## Omitted Files",
+		"",
+		fmt.Sprintf("%d file(s) did not fit the budget and were left out, highest priority first:", len(omitted)),
+	}
+	for _, file := range omitted {
+		lines = append(lines, fmt.Sprintf("- `%s` (%d bytes, priority %d)", file.Path, file.Size, file.Priority))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// markdownFence returns a backtick fence longer than the longest backtick run
+// in content, so nested fences inside the content cannot close the block.
+func markdownFence(content string) string {
+	longest, run := 0, 0
+	for i := 0; i < len(content); i++ {
+		if content[i] == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
 		}
 	}
+	if longest < 3 {
+		return "```"
+	}
+	return strings.Repeat("`", longest+1)
+}
 
-	// Add dependency files section LAST
-	if len(depFileContents[DependencyFilesCategory]) > 0 {
-		outputMDParts = append(outputMDParts, "## Dependency and Configuration Files\n")
-		sort.Strings(depFileContents[DependencyFilesCategory])
-		outputMDParts = append(outputMDParts, depFileContents[DependencyFilesCategory]...)
+// buildTokenStatsMarkdown reports the collected token total and a per-file breakdown.
+func buildTokenStatsMarkdown(result *scanResult) string {
+	tokenizerName := TokenizerChars
+	if ActiveTokenizer != nil {
+		tokenizerName = ActiveTokenizer.Name()
 	}
 
-	return strings.Join(outputMDParts, "\n")
+	var statsParts []string
+	statsParts = append(statsParts, fmt.Sprintf("%d tokens collected", result.totalTokens))
+	statsParts = append(statsParts, "tokenizer: "+tokenizerName)
+	if result.tokenLimitHit {
+		statsParts = append(statsParts, fmt.Sprintf("%d token limit reached", MaxTokens))
+	} else if MaxTokens > 0 {
+		statsParts = append(statsParts, fmt.Sprintf("max tokens: %d", MaxTokens))
+	} else {
+		statsParts = append(statsParts, "max tokens: unlimited")
+	}
+
+	if result.strippedBytes > 0 {
+		statsParts = append(statsParts, fmt.Sprintf("stripped: %d bytes, %d tokens saved", result.strippedBytes, result.strippedTokens))
+	}
+
+	lines := []string{fmt.Sprintf("**Token Statistics**: %s\n", strings.Join(statsParts, ", "))}
+	if len(result.fileTokenCounts) > 0 {
+		paths := make([]string, 0, len(result.fileTokenCounts))
+		for path := range result.fileTokenCounts {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		lines = append(lines, "**Tokens per file:**")
+		for _, path := range paths {
+			lines = append(lines, fmt.Sprintf("- `%s`: %d", path, result.fileTokenCounts[path]))
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
 }
 
-// ProcessSourceFiles processes source files and generates a Markdown summary.
-func ProcessSourceFiles(folderAbs string, maxDepth int, includePaths map[string]struct{}, includeMatcher *SimpleMatcher, excludeNames map[string]struct{}, excludeMatcher *SimpleMatcher, debug bool, includeTests bool, gi *GitIgnoreMatcher) string {
-	directoryStructureMD := GenerateDirectoryStructure(folderAbs, maxDepth, debug, includePaths, includeMatcher, excludeNames, excludeMatcher, includeTests, gi)
+// WriteProjectSummary writes the summary of folderAbs to w in the active
+// OutputFormat, preceded by prompt unless it is empty. The structure is
+// written once the project has been walked and each file as soon as it has
+// been read, so only the files held for a size or token budget stay in
+// memory. It returns an error when folderAbs cannot be scanned, before
+// anything is written, or the first error from writing to w.
+func WriteProjectSummary(w io.Writer, prompt string, folderAbs string, maxDepth int, includePaths map[string]struct{}, includeMatcher *SimpleMatcher, excludeNames map[string]struct{}, excludeMatcher *SimpleMatcher, debug bool, includeTests bool, gi *GitIgnoreMatcher) error {
+	if ActiveGitChanges != nil {
+		PrintDebug(fmt.Sprintf("Diff mode: %d file(s) changed in %s", ActiveGitChanges.Len(), ActiveGitChanges.Range), debug)
+	}
+	// The detected languages break ties for ambiguous extensions such as .h,
+	// so they are needed before the tree is filtered by language.
+	primaryLangs, fallbackLangs := detectProjectLanguages(folderAbs, excludeNames, excludeMatcher, debug, gi)
+	activeLanguageDetector = newLanguageDetector(primaryLangs, fallbackLangs)
+
+	// Files unchanged since an earlier run are neither classified nor
+	// prepared again.
+	if absRoot, err := normalizeAbsolutePath(folderAbs); err == nil {
+		activeFileCache = openFileCache(absRoot, debug)
+	}
+	defer func() {
+		activeFileCache.save(debug)
+		activeFileCache = nil
+	}()
+
+	// The structure, dependency files and sources are collected in one walk.
+	PrintDebug("Scanning project...", debug)
+	scanner := &projectScanner{
+		rootPath:            folderAbs,
+		includePaths:        includePaths,
+		includeMatcher:      includeMatcher,
+		excludeNames:        excludeNames,
+		excludeMatcher:      excludeMatcher,
+		debug:               debug,
+		includeTests:        includeTests,
+		gi:                  gi,
+		collectStructure:    true,
+		collectDependencies: true,
+		collectSources:      true,
+	}
+	result, candidates, err := scanner.walk()
+	if err != nil {
+		return fmt.Errorf("could not scan %s: %w", folderAbs, err)
+	}
+	result.overview = newProjectOverview(primaryLangs, fallbackLangs)
 
-	depFileContents, processedDepFiles := collectDependencyFiles(folderAbs, nil, nil, includePaths, includeMatcher, excludeNames, excludeMatcher, debug, gi)
-	sourceFileContents, totalFileSize, skippedFileMessages, limitHit := collectSourceFiles(folderAbs, nil, nil, processedDepFiles, includePaths, includeMatcher, excludeNames, excludeMatcher, debug, includeTests, gi)
+	// Flushing after the tree and after every file lets a reader at the
+	// other end of a pipe start before the scan finishes. The debug size
+	// check leads the document, so in debug mode the files are collected
+	// before anything is written.
+	out := bufio.NewWriter(w)
+	doc := newDocumentWriter(out, debug)
+	if debug {
+		scanner.commitSourceCandidates(result, candidates, nil)
+	}
+	doc.begin(prompt, filepath.Base(folderAbs), directoryTreeLines(result.rootPath, maxDepth, result.structureChildren), result)
+	out.Flush()
+	if !debug {
+		scanner.commitSourceCandidates(result, candidates, func(file sourceFile) {
+			doc.file(file)
+			out.Flush()
+		})
+	}
+	// Files held for the budget or the size check are written once all
+	// are collected.
+	for _, file := range orderedSourceFiles(result.files) {
+		doc.file(file)
+	}
 
-	return buildMarkdownOutput(directoryStructureMD, depFileContents, sourceFileContents, totalFileSize, skippedFileMessages, limitHit, debug)
+	if result.strippedBytes > 0 {
+		PrintDebug(fmt.Sprintf("Strip saved %d bytes, %d tokens in total", result.strippedBytes, result.strippedTokens), debug)
+	}
+	doc.end(result)
+	return out.Flush()
+}
+
+// ProcessSourceFiles returns the summary written by WriteProjectSummary,
+// without a prompt, as a string.
+func ProcessSourceFiles(folderAbs string, maxDepth int, includePaths map[string]struct{}, includeMatcher *SimpleMatcher, excludeNames map[string]struct{}, excludeMatcher *SimpleMatcher, debug bool, includeTests bool, gi *GitIgnoreMatcher) string {
+	var b strings.Builder
+	// Writing to a strings.Builder does not fail, so an error is from the scan.
+	if err := WriteProjectSummary(&b, "", folderAbs, maxDepth, includePaths, includeMatcher, excludeNames, excludeMatcher, debug, includeTests, gi); err != nil {
+		PrintError(err.Error())
+	}
+	return b.String()
 }
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -48,7 +49,10 @@ func TestBuildMarkdownOutput(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			output := buildMarkdownOutput(tt.directoryStructureMD, tt.depFileContents, tt.sourceFileContents, 1024, []string{}, false, tt.debugMode)
+			output := buildMarkdownOutput(tt.directoryStructureMD, tt.depFileContents, &scanResult{
+				sourceFileContents: tt.sourceFileContents,
+				totalFileSize:      1024,
+			}, tt.debugMode)
 
 			for _, substr := range tt.expectedContains {
 				if !strings.Contains(output, substr) {
@@ -68,14 +72,16 @@ func TestBuildMarkdownOutput_DebugSkippedFilesAreSorted(t *testing.T) {
 	output := buildMarkdownOutput(
 		"## Project Structure\n```text\n. (repo)\n```",
 		map[string][]string{},
-		map[string][]string{"Go": {"### main.go\n```go\npackage main\n```"}},
-		2*1024*1024,
-		[]string{"`z.go` (2.00 MB)", "`a.go` (2.00 MB)"},
-		true,
+		&scanResult{
+			sourceFileContents:  map[string][]string{"Go": {"### main.go\n```go\npackage main\n```"}},
+			totalFileSize:       2 * 1024 * 1024,
+			skippedFileMessages: []string{"`z.go` (2.00 MB)", "`a.go` (2.00 MB)"},
+			limitHit:            true,
+		},
 		true,
 	)
 
-	if !strings.Contains(output, "scan stopped at") {
+	if !strings.Contains(output, "total limit reached") {
 		t.Fatalf("expected debug output to mention total-size limit, got:\n%s", output)
 	}
 	if !strings.Contains(output, "**Skipped 2 file(s)") {
@@ -139,9 +145,11 @@ func TestProcessSourceFiles(t *testing.T) {
 				"## Project Structure",
 				"## Source Code Size Check",
 				"### main.go",
-			},
-			expectedNotContains: []string{
-				"## Dependency and Configuration Files", // This section is no longer generated
+				"## Dependency and Configuration Files",
+				"### go.mod\n```\nmodule myapp\n```",
+				"### package.json\n```json\n",
+				"- `package.json`: ", // manifests count toward the tokens collected
+				"- `go.mod`: ",
 			},
 		},
 	}
@@ -214,6 +222,56 @@ func TestProcessSourceFiles(t *testing.T) {
 	}
 }
 
+// recordingWriter keeps every Write separately.
+type recordingWriter struct {
+	writes []string
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	w.writes = append(w.writes, string(p))
+	return len(p), nil
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestWriteProjectSummary_WritesTreeThenEachFile(t *testing.T) {
+	tempDir := t.TempDir()
+	createTestFile(t, filepath.Join(tempDir, "b.go"), "package b\n")
+	createTestFile(t, filepath.Join(tempDir, "a.go"), "package a\n")
+	createTestFile(t, filepath.Join(tempDir, "z.py"), "print(1)\n")
+
+	w := &recordingWriter{}
+	if err := WriteProjectSummary(w, "", tempDir, 5, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, false, nil); err != nil {
+		t.Fatalf("WriteProjectSummary returned %v", err)
+	}
+
+	if len(w.writes) < 4 {
+		t.Fatalf("Expected the tree and each file in separate writes, got %d write(s): %q", len(w.writes), w.writes)
+	}
+	if !strings.Contains(w.writes[0], "## Project Structure") || strings.Contains(w.writes[0], "### ") {
+		t.Errorf("Expected the first write to hold the tree alone, got %q", w.writes[0])
+	}
+	for i, path := range []string{"a.go", "b.go", "z.py"} {
+		if !strings.HasPrefix(w.writes[i+1], "\n### "+path+"\n") {
+			t.Errorf("Expected write %d to hold %s, got %q", i+1, path, w.writes[i+1])
+		}
+	}
+}
+
+func TestWriteProjectSummary_ReturnsWriteError(t *testing.T) {
+	tempDir := t.TempDir()
+	createTestFile(t, filepath.Join(tempDir, "main.go"), "package main\n")
+
+	err := WriteProjectSummary(failingWriter{}, "", tempDir, 5, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, false, nil)
+	if err == nil || !strings.Contains(err.Error(), "broken pipe") {
+		t.Errorf("Expected the write error, got %v", err)
+	}
+}
+
 func TestProcessSourceFilesWithIncludeTests(t *testing.T) {
 	tempDir := t.TempDir()
 
@@ -378,7 +436,7 @@ func TestBuildMarkdownOutputDeep(t *testing.T) {
 				"**Skipped 2 file(s)",
 				"`large_file.go` (5.00 MB)",
 				"`huge_image.png` (10.50 MB)",
-				"scan stopped",
+				"limit reached",
 			},
 			checkSectionOrder: false,
 		},
@@ -447,7 +505,12 @@ func TestBuildMarkdownOutputDeep(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			output := buildMarkdownOutput(tt.directoryStructureMD, tt.depFileContents, tt.sourceFileContents, tt.totalFileSize, tt.skippedFileMessages, tt.limitHit, tt.debugMode)
+			output := buildMarkdownOutput(tt.directoryStructureMD, tt.depFileContents, &scanResult{
+				sourceFileContents:  tt.sourceFileContents,
+				totalFileSize:       tt.totalFileSize,
+				skippedFileMessages: tt.skippedFileMessages,
+				limitHit:            tt.limitHit,
+			}, tt.debugMode)
 
 			// Check expected content
 			for _, expected := range tt.expectedContains {
@@ -482,3 +545,74 @@ func TestBuildMarkdownOutputDeep(t *testing.T) {
 		})
 	}
 }
+
+func TestMarkdownFence(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "no backticks", content: "package main", want: "```"},
+		{name: "inline code", content: "use `x` or ``y``", want: "```"},
+		{name: "nested fence", content: "```go\nx\n```", want: "````"},
+		{name: "longest run wins", content: "````\n```\n`````", want: "``````"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := markdownFence(tt.content); got != tt.want {
+				t.Errorf("markdownFence(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNestedFencesDoNotBreakOutput(t *testing.T) {
+	tempDir := t.TempDir()
+	readme := "# Usage\n\n```bash\nlist-codes\n```\n"
+	guide := "Example:\n\n````markdown\n```go\nfmt.Println()\n```\n````\n"
+	createTestFile(t, filepath.Join(tempDir, "README.md"), readme)
+	createTestFile(t, filepath.Join(tempDir, "docs", "guide.md"), guide)
+
+	t.Run("source collector", func(t *testing.T) {
+		output := ProcessSourceFiles(tempDir, 5, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, false, nil)
+
+		if !strings.Contains(output, "### README.md\n````markdown\n"+readme+"\n````\n") {
+			t.Errorf("expected README.md to be wrapped in a four-backtick fence, got:\n%s", output)
+		}
+		if !strings.Contains(output, "### docs/guide.md\n`````markdown\n"+guide+"\n`````\n") {
+			t.Errorf("expected docs/guide.md to be wrapped in a five-backtick fence, got:\n%s", output)
+		}
+	})
+
+	t.Run("README collector", func(t *testing.T) {
+		output := CollectReadmeFiles(tempDir, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, nil)
+
+		if !strings.Contains(output, "### README.md\n````markdown\n"+readme+"\n````\n") {
+			t.Errorf("expected README.md to be wrapped in a four-backtick fence, got:\n%s", output)
+		}
+	})
+
+	t.Run("scanner README collector", func(t *testing.T) {
+		scanner := newProjectScannerForTest(tempDir)
+		result, err := scanner.scan()
+		if err != nil {
+			t.Fatalf("scan failed: %v", err)
+		}
+		if len(result.readmeFiles) != 1 || !strings.Contains(result.readmeFiles[0], "````markdown\n"+readme+"\n````\n") {
+			t.Errorf("expected scanner README to be wrapped in a four-backtick fence, got:\n%v", result.readmeFiles)
+		}
+	})
+}
+
+func TestRenderMarkdownFile_PlainFenceForOrdinaryCode(t *testing.T) {
+	got := renderMarkdownFile(sourceFile{Path: "main.go", Language: "Go", Content: "package main"})
+	if got != "### main.go\n```go\npackage main\n```\n" {
+		t.Errorf("unexpected rendering: %q", got)
+	}
+
+	got = renderMarkdownFile(sourceFile{Path: "x.go", Language: "Go", Content: "x", Patch: "+```"})
+	if !strings.Contains(got, "\n````diff\n+```\n````\n") {
+		t.Errorf("expected patch fence to grow past its backticks, got: %q", got)
+	}
+}
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// RedactSecrets enables the redaction pass over collected file contents.
+var RedactSecrets = true
+
+// minSecretEntropy is the Shannon entropy, in bits per character, above which
+// a generic *_TOKEN= style value is treated as a secret.
+const minSecretEntropy = 3.5
+
+// redactionRule replaces the secrets matched by Pattern with
+// "[REDACTED:<Kind>]". When Pattern has a capture group only the first group
+// is replaced, so "API_TOKEN=" stays readable.
+type redactionRule struct {
+	Kind    string
+	Pattern *regexp.Regexp
+	// highEntropy additionally requires the secret to look random.
+	highEntropy bool
+}
+
+// defaultRedactionRules are tried in order; where matches overlap, the first
+// rule wins.
+var defaultRedactionRules = []redactionRule{
+	{Kind: "private-key", Pattern: regexp.MustCompile(`-----BEGIN[A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----[\s\S]*?-----END[A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----`)},
+	{Kind: "aws-access-key", Pattern: regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`)},
+	{Kind: "aws-secret-key", Pattern: regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})\b`)},
+	{Kind: "jwt", Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+	{Kind: "connection-string", Pattern: regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^\s:/@'"]+:([^\s@/'"]+)@`)},
+	{Kind: "token", Pattern: regexp.MustCompile(`(?i)\b\w*(?:TOKEN|SECRET|API_?KEY|ACCESS_?KEY|PASSWORD|PASSWD|CREDENTIALS?)["']?\s*[:=]\s*["']?([A-Za-z0-9+/=_.~-]{16,})`), highEntropy: true},
+}
+
+// customRedactionRules come from the "redact" section of .list-codes.yaml.
+var customRedactionRules []redactionRule
+
+// AddRedactionPattern registers a custom redaction regex reported as kind.
+// If the regex has a capture group only the first group is redacted.
+func AddRedactionPattern(kind, pattern string) error {
+	kind = strings.TrimSpace(kind)
+	if kind == "" {
+		kind = "custom"
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid redaction pattern for '%s': %w", kind, err)
+	}
+	customRedactionRules = append(customRedactionRules, redactionRule{Kind: kind, Pattern: re})
+	return nil
+}
+
+// redaction records one replaced secret by its kind and 1-based line.
+type redaction struct {
+	Kind string
+	Line int
+}
+
+type redactionSpan struct {
+	start, end int
+	kind       string
+}
+
+// redactSecrets replaces every secret in text with "[REDACTED:<kind>]" and
+// reports what was replaced, with line numbers of the original text.
+func redactSecrets(text string) (string, []redaction) {
+	rules := append(append([]redactionRule(nil), defaultRedactionRules...), customRedactionRules...)
+
+	var spans []redactionSpan
+	for _, rule := range rules {
+		for _, match := range rule.Pattern.FindAllStringSubmatchIndex(text, -1) {
+			start, end := match[0], match[1]
+			if len(match) >= 4 && match[2] >= 0 {
+				start, end = match[2], match[3]
+			}
+			if start == end {
+				continue
+			}
+			if rule.highEntropy && !looksRandom(text[start:end]) {
+				continue
+			}
+			if overlapsAny(spans, start, end) {
+				continue
+			}
+			spans = append(spans, redactionSpan{start: start, end: end, kind: rule.Kind})
+		}
+	}
+	if len(spans) == 0 {
+		return text, nil
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	var b strings.Builder
+	redactions := make([]redaction, 0, len(spans))
+	last := 0
+	for _, span := range spans {
+		b.WriteString(text[last:span.start])
+		b.WriteString("[REDACTED:" + span.kind + "]")
+		redactions = append(redactions, redaction{Kind: span.kind, Line: strings.Count(text[:span.start], "\n") + 1})
+		last = span.end
+	}
+	b.WriteString(text[last:])
+	return b.String(), redactions
+}
+
+// redactFileContent applies redactSecrets when RedactSecrets is set and
+// reports the redactions for displayPath in debug output.
+func redactFileContent(displayPath, text string, debug bool) string {
+	if !RedactSecrets {
+		return text
+	}
+	redacted, redactions := redactSecrets(text)
+	if len(redactions) > 0 {
+		found := make([]string, 0, len(redactions))
+		for _, r := range redactions {
+			found = append(found, fmt.Sprintf("%s (line %d)", r.Kind, r.Line))
+		}
+		PrintDebug(fmt.Sprintf("Redacted %d secret(s) in '%s': %s", len(redactions), displayPath, strings.Join(found, ", ")), debug)
+	}
+	return redacted
+}
+
+func overlapsAny(spans []redactionSpan, start, end int) bool {
+	for _, span := range spans {
+		if start < span.end && span.start < end {
+			return true
+		}
+	}
+	return false
+}
+
+// looksRandom reports whether value mixes letters and digits with high
+// entropy, which identifiers and placeholders such as "changeme" rarely do.
+func looksRandom(value string) bool {
+	hasLetter := strings.IndexFunc(value, func(r rune) bool { return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') }) >= 0
+	hasDigit := strings.IndexFunc(value, func(r rune) bool { return r >= '0' && r <= '9' }) >= 0
+	return hasLetter && hasDigit && shannonEntropy(value) >= minSecretEntropy
+}
+
+func shannonEntropy(value string) float64 {
+	if value == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range value {
+		counts[r]++
+		total++
+	}
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
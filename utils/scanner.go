@@ -3,76 +3,150 @@ package utils
 import (
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 )
 
-type scannedEntry struct {
-	name  string
-	path  string
-	isDir bool
+// ProjectEntry is a file or directory listed by a project scan.
+type ProjectEntry struct {
+	Name  string
+	Path  string // absolute
+	IsDir bool
 }
 
 type scanResult struct {
-	rootPath              string
-	structureChildren     map[string][]scannedEntry
-	readmeFiles           []string
-	sourceFileContents    map[string][]string
-	totalFileSize         int64
-	skippedFileMessages   []string
-	limitHit              bool
-	stopCollectingSources bool
+	rootPath            string
+	structureChildren   map[string][]ProjectEntry
+	readmeFiles         []string
+	sourceFileContents  map[string][]string
+	totalFileSize       int64
+	skippedFileMessages []string
+	limitHit            bool
+
+	// readmes are the README.md files behind readmeFiles as records.
+	readmes []sourceFile
+
+	// totalTokens and fileTokenCounts are measured with ActiveTokenizer;
+	// fileTokenCounts is keyed by display path.
+	totalTokens     int
+	fileTokenCounts map[string]int
+	tokenLimitHit   bool
+
+	// strippedBytes and strippedTokens are what --strip removed.
+	strippedBytes  int
+	strippedTokens int
+
+	// files, skippedFiles and dependencies hold the same collection as
+	// structured records for the non-Markdown output formats.
+	files        []sourceFile
+	skippedFiles []skippedFile
+	dependencies []dependencyFile
+
+	// omittedFiles did not fit the size or token budget, highest priority
+	// first.
+	omittedFiles []omittedFile
+
+	// languages tallies the collected files for the language breakdown,
+	// including the files handed to emit.
+	languages map[string]*languageStats
+
+	// emit, when set, receives each collected file instead of files and
+	// sourceFileContents, so the file can be written and dropped.
+	emit func(sourceFile)
+
+	// overview is rendered as the "Project Overview" section before the
+	// tree; nil omits it.
+	overview *projectOverview
 }
 
+// projectScanner walks the project once and feeds every entry that passes
+// the include, exclude and .gitignore rules to the enabled collectors, so
+// the tree, READMEs, dependency files and sources share a single walk.
 type projectScanner struct {
 	rootPath string
 
-	includePaths map[string]struct{}
-	excludeNames map[string]struct{}
-	excludePaths map[string]struct{}
+	includePaths   map[string]struct{}
+	includeMatcher *SimpleMatcher
+	excludeNames   map[string]struct{}
+	excludeMatcher *SimpleMatcher
 
 	debug        bool
 	includeTests bool
 	gi           *GitIgnoreMatcher
 
+	// processedDepFiles are not collected as sources. collectDependencies
+	// adds the dependency files it claims during the walk.
 	processedDepFiles map[string]struct{}
 
-	collectStructure bool
-	collectReadmes   bool
-	collectSources   bool
+	// walkDepth is the deepest level below the root that is walked;
+	// directories at that level are listed but not entered. 0 walks the
+	// whole tree.
+	walkDepth int
 
-	// stopWalkOnSourceLimit preserves collectSourceFiles behaviour where scan
-	// stops immediately once total-size limit is exceeded.
-	stopWalkOnSourceLimit bool
+	collectStructure    bool
+	collectReadmes      bool
+	collectDependencies bool
+	collectSources      bool
+
+	// listAllFiles keeps tests, assets and files of filtered languages in
+	// the structure, for callers that let the user pick files.
+	listAllFiles bool
 }
 
+// fileScanMeta classifies a walked file. language and binary are only set
+// for files that are not left out as tests or assets by their name.
 type fileScanMeta struct {
 	isTest             bool
 	isAsset            bool
 	explicitlyIncluded bool
+	languageFiltered   bool
+	language           string
+	binary             bool
 }
 
+// scan walks the project and collects the selected sources. It returns a
+// nil result only when the root cannot be resolved.
 func (s *projectScanner) scan() (*scanResult, error) {
+	result, candidates, err := s.walk()
+	if result != nil && s.collectSources {
+		s.commitSourceCandidates(result, candidates, nil)
+	}
+	return result, err
+}
+
+// walk runs the enabled collectors on every entry of the project. Sources
+// are only selected as candidates; commitSourceCandidates reads them.
+func (s *projectScanner) walk() (*scanResult, []sourceCandidate, error) {
 	absRoot, err := normalizeAbsolutePath(s.rootPath)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	result := &scanResult{
 		rootPath: absRoot,
 	}
 	if s.collectStructure {
-		result.structureChildren = make(map[string][]scannedEntry)
+		result.structureChildren = make(map[string][]ProjectEntry)
 		result.structureChildren[absRoot] = nil
 	}
 	if s.collectSources {
 		result.sourceFileContents = make(map[string][]string)
 	}
+	if s.processedDepFiles == nil {
+		s.processedDepFiles = make(map[string]struct{})
+	}
+	needsMeta := (s.collectStructure && !s.listAllFiles) || s.collectSources
+	var candidates []sourceCandidate
 
 	walkErr := filepath.WalkDir(absRoot, func(path string, d os.DirEntry, walkErr error) error {
 		if walkErr != nil {
+			if path == absRoot {
+				// Nothing can be collected below a root that cannot be read.
+				return walkErr
+			}
 			PrintWarning(fmt.Sprintf("Error accessing path %s: %v", path, walkErr), s.debug)
 			if d != nil && d.IsDir() {
 				return filepath.SkipDir
@@ -84,38 +158,47 @@ func (s *projectScanner) scan() (*scanResult, error) {
 			return nil
 		}
 
+		// WalkDir yields clean absolute paths below an absolute root, so the
+		// rules need neither filepath.Abs nor an os.Stat per entry.
 		absPath := path
-		if shouldSkipPath(absPath, d.Name(), d.IsDir(), s.includePaths, s.excludeNames, s.excludePaths, s.gi) {
-			if d.IsDir() {
+		isDir := d.IsDir()
+		if !isDir && !s.wantsFile(d.Name()) {
+			return nil
+		}
+		if shouldSkipPath(absPath, d.Name(), isDir, s.includePaths, s.includeMatcher, s.excludeNames, s.excludeMatcher, s.gi) {
+			if isDir {
 				return filepath.SkipDir
 			}
 			return nil
 		}
 
-		if d.IsDir() {
+		if isDir {
 			if s.collectStructure {
-				result.structureChildren[filepath.Dir(absPath)] = append(result.structureChildren[filepath.Dir(absPath)], scannedEntry{
-					name:  d.Name(),
-					path:  absPath,
-					isDir: true,
+				result.structureChildren[filepath.Dir(absPath)] = append(result.structureChildren[filepath.Dir(absPath)], ProjectEntry{
+					Name:  d.Name(),
+					Path:  absPath,
+					IsDir: true,
 				})
 				if _, ok := result.structureChildren[absPath]; !ok {
 					result.structureChildren[absPath] = nil
 				}
 			}
+			if s.walkDepth > 0 && strings.Count(absPath[len(absRoot):], string(filepath.Separator)) >= s.walkDepth {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
 		var meta fileScanMeta
-		if s.collectStructure || s.collectSources {
-			meta = buildFileScanMeta(absPath, s.includePaths, s.includeTests, s.debug)
+		if needsMeta {
+			meta = s.buildFileScanMeta(absPath)
 		}
 
-		if s.collectStructure && shouldIncludeInStructure(meta) {
-			result.structureChildren[filepath.Dir(absPath)] = append(result.structureChildren[filepath.Dir(absPath)], scannedEntry{
-				name:  d.Name(),
-				path:  absPath,
-				isDir: false,
+		if s.collectStructure && (s.listAllFiles || shouldIncludeInStructure(meta)) {
+			result.structureChildren[filepath.Dir(absPath)] = append(result.structureChildren[filepath.Dir(absPath)], ProjectEntry{
+				Name:  d.Name(),
+				Path:  absPath,
+				IsDir: false,
 			})
 		}
 
@@ -123,31 +206,59 @@ func (s *projectScanner) scan() (*scanResult, error) {
 			s.collectReadmeFile(result, absPath)
 		}
 
+		if s.collectDependencies {
+			s.collectDependencyFile(result, absPath, d)
+		}
+
 		if s.collectSources {
-			if err := s.collectSourceFile(result, absPath, d, meta); err != nil {
-				return err
+			if candidate, ok := s.sourceCandidate(absPath, d, meta); ok {
+				candidates = append(candidates, candidate)
 			}
 		}
 
 		return nil
 	})
 
-	if walkErr != nil && !errors.Is(walkErr, errTotalSizeLimitExceeded) {
+	if walkErr != nil {
 		PrintWarning(fmt.Sprintf("Error during file walk: %v", walkErr), s.debug)
-		return result, walkErr
 	}
+	return result, candidates, walkErr
+}
 
-	return result, nil
+// wantsFile reports whether any enabled collector may use a file, so that
+// a README-only scan does not apply the rules to every other file.
+func (s *projectScanner) wantsFile(name string) bool {
+	return s.collectStructure || s.collectDependencies || s.collectSources ||
+		(s.collectReadmes && strings.EqualFold(name, "readme.md"))
 }
 
-func buildFileScanMeta(absPath string, includePaths map[string]struct{}, includeTests bool, debug bool) fileScanMeta {
+// buildFileScanMeta classifies a file by its name and, when the name does
+// not settle it, by the start of its content, which is read at most once
+// and not at all while activeFileCache holds the class of the unchanged
+// file.
+func (s *projectScanner) buildFileScanMeta(absPath string) fileScanMeta {
 	meta := fileScanMeta{
-		isAsset:            IsAssetFile(absPath, debug),
-		explicitlyIncluded: isExplicitlyIncludedPath(absPath, includePaths),
+		isAsset:            hasAssetExtension(absPath, s.debug),
+		explicitlyIncluded: isExplicitlyIncludedPath(absPath, s.includePaths, s.includeMatcher),
 	}
-	if !includeTests {
-		meta.isTest = IsTestFile(absPath, debug)
+	if !s.includeTests {
+		meta.isTest = IsTestFile(absPath, s.debug)
 	}
+	if meta.isTest || (meta.isAsset && !meta.explicitlyIncluded) {
+		return meta
+	}
+
+	head := newFileHead(absPath)
+	if activeFileCache != nil {
+		head.cache = activeFileCache
+		head.displayPath = relativeDisplayPath(activeFileCache.root, absPath, s.debug)
+	}
+	meta.language = activeLanguageDetector.detectWithHead(absPath, head)
+	if isBinaryAsset(absPath, meta.language, head, s.debug) {
+		meta.isAsset = true
+		meta.binary = true
+	}
+	meta.languageFiltered = !IsLanguageAllowed(meta.language)
 	return meta
 }
 
@@ -158,10 +269,18 @@ func shouldIncludeInStructure(meta fileScanMeta) bool {
 	if meta.isAsset && !meta.explicitlyIncluded {
 		return false
 	}
+	if meta.languageFiltered {
+		return false
+	}
 	return true
 }
 
-func isExplicitlyIncludedPath(absPath string, includePaths map[string]struct{}) bool {
+// isExplicitlyIncludedPath reports whether --include names absPath, either
+// as a path or through a pattern.
+func isExplicitlyIncludedPath(absPath string, includePaths map[string]struct{}, includeMatcher *SimpleMatcher) bool {
+	if includeMatcher != nil && includeMatcher.Match(absPath) {
+		return true
+	}
 	return len(includePaths) > 0 && isPathRelatedToIncludes(absPath, includePaths)
 }
 
@@ -173,73 +292,462 @@ func (s *projectScanner) collectReadmeFile(result *scanResult, absPath string) {
 	}
 
 	fileDisplayName := relativeDisplayPath(result.rootPath, absPath, s.debug)
-	markdownContent := fmt.Sprintf("### %s\n```markdown\n%s\n```\n", fileDisplayName, string(content))
+	text, isText := decodeFileText(fileDisplayName, content, s.debug)
+	if !isText {
+		return
+	}
+	text = redactFileContent(fileDisplayName, text, s.debug)
+	fence := markdownFence(text)
+	markdownContent := fmt.Sprintf("### %s\n%smarkdown\n%s\n%s\n", fileDisplayName, fence, text, fence)
 	result.readmeFiles = append(result.readmeFiles, markdownContent)
+	result.readmes = append(result.readmes, sourceFile{
+		Path:     fileDisplayName,
+		Language: "Markdown",
+		Size:     int64(len(content)),
+		Tokens:   CountTokens(text),
+		Content:  text,
+	})
 }
 
-func (s *projectScanner) collectSourceFile(result *scanResult, absPath string, d os.DirEntry, meta fileScanMeta) error {
-	if result.stopCollectingSources {
-		return nil
+// collectDependencyFile collects a manifest listed in PROJECT_SIGNATURES or
+// summarizes a lockfile as its direct dependencies, and claims the file so
+// that it is not collected again as a source.
+func (s *projectScanner) collectDependencyFile(result *scanResult, absPath string, d os.DirEntry) {
+	summarize, isLockfile := lockfileSummarizers[d.Name()]
+	if !isLockfile && !isDependencyManifest(d.Name()) {
+		return
+	}
+	if ActiveGitChanges != nil && !ActiveGitChanges.Contains(absPath) {
+		return
+	}
+	// Claim the file even if it cannot be read, so a lockfile is never
+	// dumped verbatim as a source file.
+	s.processedDepFiles[absPath] = struct{}{}
+	relPath := relativeDisplayPath(result.rootPath, absPath, s.debug)
+
+	fileInfo, err := d.Info()
+	if err != nil {
+		PrintWarning(fmt.Sprintf("Could not get file info for '%s': %v", absPath, err), s.debug)
+		return
 	}
+	// Lockfiles are summarized, so only manifests are bound by --max-file-size.
+	if !isLockfile && fileInfo.Size() > MaxFileSizeBytes {
+		PrintDebug(fmt.Sprintf("Skipping dependency file '%s' (%.2f MB exceeds the file size limit)", relPath, float64(fileInfo.Size())/(1024*1024)), s.debug)
+		return
+	}
+
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		PrintWarning(fmt.Sprintf("Could not read dependency file '%s': %v", relPath, err), s.debug)
+		return
+	}
+
+	text, isText := decodeFileText(relPath, content, s.debug)
+	if !isText {
+		return
+	}
+
+	file := dependencyFile{Path: relPath, Kind: dependencyKindManifest, Content: redactFileContent(relPath, text, s.debug)}
+	if isLockfile {
+		summary, err := summarize(filepath.Dir(absPath), []byte(text))
+		if err != nil {
+			PrintWarning(fmt.Sprintf("Could not summarize lockfile '%s': %v", relPath, err), s.debug)
+			return
+		}
+		file = dependencyFile{Path: relPath, Kind: dependencyKindLockfile, Dependencies: summary.Direct, Locked: summary.Locked}
+		PrintDebug(fmt.Sprintf("Summarized lockfile '%s': %d direct of %d locked", relPath, len(summary.Direct), summary.Locked), s.debug)
+	} else {
+		if !result.chargeManifest(file, fileInfo.Size(), s.debug) {
+			return
+		}
+		PrintDebug(fmt.Sprintf("Collected manifest '%s'", relPath), s.debug)
+	}
+	result.dependencies = append(result.dependencies, file)
+}
+
+// sourceCandidate decides whether a walked file is collected as a source
+// and with which language. The budgets are applied later, once all
+// candidates are known.
+func (s *projectScanner) sourceCandidate(absPath string, d os.DirEntry, meta fileScanMeta) (sourceCandidate, bool) {
 	if _, ok := s.processedDepFiles[absPath]; ok {
-		return nil
+		return sourceCandidate{}, false
 	}
 	if meta.isTest {
-		return nil
+		return sourceCandidate{}, false
 	}
 
-	language := GetLanguageByExtension(d.Name())
+	language := meta.language
 	if meta.isAsset {
 		if !meta.explicitlyIncluded {
-			return nil
+			return sourceCandidate{}, false
 		}
+		// Explicitly included asset files are treated as plain text.
 		if language == "" {
 			language = "text"
 		}
 	} else if language == "" {
-		return nil
+		return sourceCandidate{}, false
+	}
+	if !IsLanguageAllowed(language) {
+		return sourceCandidate{}, false
+	}
+	if ActiveGitChanges != nil && !ActiveGitChanges.Contains(absPath) {
+		return sourceCandidate{}, false
 	}
 
 	fileInfo, err := d.Info()
 	if err != nil {
 		PrintWarning(fmt.Sprintf("Could not get file info for '%s': %v", absPath, err), s.debug)
-		return nil
+		return sourceCandidate{}, false
+	}
+	return sourceCandidate{absPath: absPath, language: language, info: fileInfo, binary: meta.binary}, true
+}
+
+// WouldCollect reports whether a scan of folderAbs with these rules would
+// collect the existing file at path, as a source, a dependency file or,
+// with readmeOnly, a README. Budgets are not considered.
+func WouldCollect(folderAbs, path string, includePaths map[string]struct{}, includeMatcher *SimpleMatcher, excludeNames map[string]struct{}, excludeMatcher *SimpleMatcher, readmeOnly, includeTests bool, gi *GitIgnoreMatcher) bool {
+	absRoot, err := normalizeAbsolutePath(folderAbs)
+	if err != nil {
+		return false
+	}
+	absPath, err := normalizeAbsolutePath(path)
+	if err != nil || absPath == absRoot || !pathWithinRoot(absRoot, absPath) {
+		return false
+	}
+	info, err := os.Lstat(absPath)
+	if err != nil || info.IsDir() {
+		return false
 	}
 
+	// The walk only reaches the file through directories that are not
+	// skipped.
+	for dir := filepath.Dir(absPath); dir != absRoot; dir = filepath.Dir(dir) {
+		if shouldSkipPath(dir, filepath.Base(dir), true, includePaths, includeMatcher, excludeNames, excludeMatcher, gi) {
+			return false
+		}
+	}
+	name := filepath.Base(absPath)
+	if shouldSkipPath(absPath, name, false, includePaths, includeMatcher, excludeNames, excludeMatcher, gi) {
+		return false
+	}
+	if readmeOnly {
+		return strings.EqualFold(name, "readme.md")
+	}
+	if _, isLockfile := lockfileSummarizers[name]; isLockfile || isDependencyManifest(name) {
+		return true
+	}
+
+	s := &projectScanner{
+		rootPath:          absRoot,
+		includePaths:      includePaths,
+		includeMatcher:    includeMatcher,
+		includeTests:      includeTests,
+		processedDepFiles: make(map[string]struct{}),
+	}
+	_, ok := s.sourceCandidate(absPath, fs.FileInfoToDirEntry(info), s.buildFileScanMeta(absPath))
+	return ok
+}
+
+// commitSourceCandidates collects the candidates of a scan.
+// With --focus only the candidates most relevant to FocusQuery are kept.
+// When a total size or token budget is set, candidates are ranked by
+// filePriority and the files that do not fit are recorded as omitted.
+// Files are read on Jobs workers and committed in candidate order, so the
+// result does not depend on the number of workers.
+//
+// Without a budget every candidate is collected, so candidates are read in
+// output order and emit, when not nil, receives each file as soon as it is
+// read. With a budget the files stay in result.files until it is spent.
+func (s *projectScanner) commitSourceCandidates(result *scanResult, candidates []sourceCandidate, emit func(sourceFile)) {
+	if FocusQuery != "" {
+		candidates = focusCandidates(result.rootPath, candidates, s.debug)
+	}
+	// With a budget, the files most worth reading fill it first; a file that
+	// does not fit is listed as omitted and smaller files may still follow.
+	if budgetActive() {
+		rankSourceCandidates(result.rootPath, candidates, s.debug)
+	} else {
+		sortCandidatesInOutputOrder(result.rootPath, candidates, s.debug)
+		result.emit = emit
+	}
+	prepareSourceFilesInOrder(result.rootPath, candidates, s.debug, func(candidate sourceCandidate, prepared preparedSource) {
+		if err := result.commitSourceFile(prepared, s.debug); errors.Is(err, errTotalSizeLimitExceeded) {
+			result.omittedFiles = append(result.omittedFiles, omittedFile{
+				Path:     relativeDisplayPath(result.rootPath, candidate.absPath, s.debug),
+				Size:     prepared.info.Size(),
+				Priority: candidate.priority,
+			})
+		}
+	})
+}
+
+// sortCandidatesInOutputOrder sorts candidates like orderedSourceFiles:
+// grouped by language, then by display path.
+func sortCandidatesInOutputOrder(rootPath string, candidates []sourceCandidate, debug bool) {
+	paths := make(map[string]string, len(candidates))
+	for _, candidate := range candidates {
+		paths[candidate.absPath] = relativeDisplayPath(rootPath, candidate.absPath, debug)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].language != candidates[j].language {
+			return candidates[i].language < candidates[j].language
+		}
+		return paths[candidates[i].absPath] < paths[candidates[j].absPath]
+	})
+}
+
+// headFileInfo reports the size of a file read at the head of a --diff
+// range instead of the size in the working tree.
+type headFileInfo struct {
+	os.FileInfo
+	size int64
+}
+
+func (info headFileInfo) Size() int64 { return info.size }
+
+// preparedSource is a source file read, decoded and reduced by
+// prepareSourceFile, waiting for commitSourceFile to apply the budgets.
+type preparedSource struct {
+	absPath  string
+	language string
+	info     os.FileInfo
+	// tooLarge files exceed --max-file-size and were not read; text is
+	// false for unreadable and binary files.
+	tooLarge bool
+	text     bool
+	file     sourceFile
+
+	strippedBytes  int
+	strippedTokens int
+}
+
+// prepareSourceFile does the per-file work that does not depend on other
+// files: it reads the file, transcodes it to UTF-8, redacts secrets, reduces
+// it to its outline in --outline mode, applies --strip and counts its tokens.
+// It only reads shared settings, so several files can be prepared at once.
+// Files unchanged since an earlier run are taken from activeFileCache, and
+// files the walk found to be binary are not read again.
+func prepareSourceFile(rootPath string, candidate sourceCandidate, debug bool) preparedSource {
+	absPath, language, fileInfo := candidate.absPath, candidate.language, candidate.info
+	// A --diff range is collected as of its head, whatever is checked out.
+	content, fromHead, err := ActiveGitChanges.ReadFile(absPath)
+	if err != nil {
+		PrintWarning(fmt.Sprintf("Could not read source file '%s': %v", absPath, err), debug)
+		return preparedSource{absPath: absPath, language: language, info: fileInfo}
+	}
+	// The cache describes working tree files, so content from git bypasses it.
+	cache := activeFileCache
+	if fromHead {
+		fileInfo = headFileInfo{FileInfo: fileInfo, size: int64(len(content))}
+		cache = nil
+	}
+
+	prepared := preparedSource{absPath: absPath, language: language, info: fileInfo}
 	if fileInfo.Size() > MaxFileSizeBytes {
-		PrintDebug(fmt.Sprintf("Skipping file '%s' due to size (%d bytes > %d bytes)", absPath, fileInfo.Size(), MaxFileSizeBytes), s.debug)
-		relPath := relativeDisplayPath(result.rootPath, absPath, s.debug)
+		prepared.tooLarge = true
+		return prepared
+	}
+
+	fileDisplayName := relativeDisplayPath(rootPath, absPath, debug)
+	if candidate.binary && !fromHead {
+		PrintDebug(fmt.Sprintf("Skipping binary file '%s'", fileDisplayName), debug)
+		return prepared
+	}
+	// Binary files and files whose prepared text is cached need no read
+	// while their size and modification time are unchanged.
+	if entry, ok := cache.lookup(fileDisplayName, language, fileInfo, ""); ok && (entry.Binary || entry.content != nil) {
+		return prepared.fromCache(fileDisplayName, entry, "", debug)
+	}
+
+	if !fromHead {
+		if content, err = os.ReadFile(absPath); err != nil {
+			PrintWarning(fmt.Sprintf("Could not read source file '%s': %v", absPath, err), debug)
+			return prepared
+		}
+	}
+
+	var hash string
+	if cache != nil {
+		hash = contentHash(content)
+		if entry, ok := cache.lookup(fileDisplayName, language, fileInfo, hash); ok {
+			return prepared.fromCache(fileDisplayName, entry, string(content), debug)
+		}
+	}
+	entry := fileCacheEntry{Size: fileInfo.Size(), ModTime: fileInfo.ModTime().UnixNano(), Hash: hash, Language: language}
+
+	text, isText := decodeFileText(fileDisplayName, content, debug)
+	if !isText {
+		entry.Binary = true
+		cache.store(fileDisplayName, entry, debug)
+		return prepared
+	}
+	text = redactFileContent(fileDisplayName, text, debug)
+	text, outlined := outlineSource(fileDisplayName, language, text, debug)
+	if ActiveStripOptions.Enabled() {
+		stripped := stripSource(text, language, ActiveStripOptions)
+		if savedBytes := len(text) - len(stripped); savedBytes > 0 {
+			prepared.strippedBytes = savedBytes
+			prepared.strippedTokens = CountTokens(text) - CountTokens(stripped)
+			PrintDebug(fmt.Sprintf("Stripped '%s': %d bytes, %d tokens saved", fileDisplayName, prepared.strippedBytes, prepared.strippedTokens), debug)
+		}
+		text = stripped
+	}
+
+	prepared.text = true
+	prepared.file = sourceFile{
+		Path:     fileDisplayName,
+		Language: language,
+		Size:     fileInfo.Size(),
+		Tokens:   CountTokens(text),
+		Content:  text,
+		Outline:  outlined,
+	}
+
+	entry.Tokens = prepared.file.Tokens
+	entry.Outline = outlined
+	entry.StrippedBytes = prepared.strippedBytes
+	entry.StrippedTokens = prepared.strippedTokens
+	if text != string(content) {
+		entry.content = &text
+	}
+	cache.store(fileDisplayName, entry, debug)
+	return prepared
+}
+
+// fromCache completes a prepared file from its cache entry. content is the
+// file as read, used when the entry does not hold the prepared text.
+func (prepared preparedSource) fromCache(fileDisplayName string, entry fileCacheEntry, content string, debug bool) preparedSource {
+	if entry.Binary {
+		PrintDebug(fmt.Sprintf("Skipping binary file '%s' (cached)", fileDisplayName), debug)
+		return prepared
+	}
+	if entry.content != nil {
+		content = *entry.content
+	}
+	prepared.text = true
+	prepared.strippedBytes = entry.StrippedBytes
+	prepared.strippedTokens = entry.StrippedTokens
+	prepared.file = sourceFile{
+		Path:     fileDisplayName,
+		Language: prepared.language,
+		Size:     prepared.info.Size(),
+		Tokens:   entry.Tokens,
+		Content:  content,
+		Outline:  entry.Outline,
+	}
+	return prepared
+}
+
+// chargeManifest adds a manifest to the size and token totals and to the
+// tokens per file like a source file, or records it as omitted and returns
+// false when it does not fit the remaining budget. Manifests are collected during the walk, so they are
+// charged before any source file.
+func (r *scanResult) chargeManifest(file dependencyFile, size int64, debug bool) bool {
+	tokens := CountTokens(file.Content)
+	if TotalMaxFileSizeBytes > 0 && r.totalFileSize+size > TotalMaxFileSizeBytes {
+		PrintDebug(fmt.Sprintf("Omitting '%s' as total size limit of %d bytes would be exceeded (%d bytes).", file.Path, TotalMaxFileSizeBytes, size), debug)
+		r.limitHit = true
+	} else if MaxTokens > 0 && int64(r.totalTokens+tokens) > MaxTokens {
+		PrintDebug(fmt.Sprintf("Omitting '%s' as token limit of %d would be exceeded (%d tokens).", file.Path, MaxTokens, tokens), debug)
+		r.tokenLimitHit = true
+	} else {
+		r.totalFileSize += size
+		r.totalTokens += tokens
+		r.countFileTokens(file.Path, tokens)
+		return true
+	}
+	r.omittedFiles = append(r.omittedFiles, omittedFile{Path: file.Path, Size: size, Priority: filePriority(file.Path, size, 0)})
+	return false
+}
+
+func (r *scanResult) countFileTokens(path string, tokens int) {
+	if r.fileTokenCounts == nil {
+		r.fileTokenCounts = make(map[string]int)
+	}
+	r.fileTokenCounts[path] = tokens
+}
+
+// commitSourceFile records a prepared file unless it exceeds the per-file
+// limit or the remaining size or token budget. Files must be committed in
+// a fixed order for the budgets to select the same files on every run.
+func (r *scanResult) commitSourceFile(prepared preparedSource, debug bool) error {
+	absPath, fileInfo := prepared.absPath, prepared.info
+	if prepared.tooLarge {
+		PrintDebug(fmt.Sprintf("Skipping file '%s' due to size (%d bytes > %d bytes)", absPath, fileInfo.Size(), MaxFileSizeBytes), debug)
+		relPath := relativeDisplayPath(r.rootPath, absPath, debug)
 		fileSizeMB := float64(fileInfo.Size()) / (1024 * 1024)
 		skippedMessage := fmt.Sprintf("`%s` (%.2f MB)", relPath, fileSizeMB)
-		result.skippedFileMessages = append(result.skippedFileMessages, skippedMessage)
+		r.skippedFileMessages = append(r.skippedFileMessages, skippedMessage)
+		r.skippedFiles = append(r.skippedFiles, skippedFile{Path: relPath, Size: fileInfo.Size()})
 		return nil
 	}
 
-	if TotalMaxFileSizeBytes > 0 && result.totalFileSize+fileInfo.Size() > TotalMaxFileSizeBytes {
-		PrintDebug(fmt.Sprintf("Stopping scan as total size limit of %d bytes would be exceeded.", TotalMaxFileSizeBytes), s.debug)
-		result.limitHit = true
-		if s.stopWalkOnSourceLimit {
-			return errTotalSizeLimitExceeded
-		}
-		result.stopCollectingSources = true
+	// Binary and unreadable files are never collected, so they neither
+	// spend nor exhaust the budgets.
+	if !prepared.text {
 		return nil
 	}
+	if TotalMaxFileSizeBytes > 0 && r.totalFileSize+fileInfo.Size() > TotalMaxFileSizeBytes {
+		PrintDebug(fmt.Sprintf("Omitting '%s' as total size limit of %d bytes would be exceeded (%d bytes).", relativeDisplayPath(r.rootPath, absPath, debug), TotalMaxFileSizeBytes, fileInfo.Size()), debug)
+		r.limitHit = true
+		return errTotalSizeLimitExceeded
+	}
 
-	result.totalFileSize += fileInfo.Size()
+	file := prepared.file
+	if MaxTokens > 0 && int64(r.totalTokens+file.Tokens) > MaxTokens {
+		PrintDebug(fmt.Sprintf("Omitting '%s' as token limit of %d would be exceeded (%d tokens).", file.Path, MaxTokens, file.Tokens), debug)
+		r.tokenLimitHit = true
+		return errTotalSizeLimitExceeded
+	}
 
-	content, err := os.ReadFile(absPath)
-	if err != nil {
-		PrintWarning(fmt.Sprintf("Could not read source file '%s': %v", absPath, err), s.debug)
+	r.totalFileSize += fileInfo.Size()
+	r.totalTokens += file.Tokens
+	r.strippedBytes += prepared.strippedBytes
+	r.strippedTokens += prepared.strippedTokens
+	r.countFileTokens(file.Path, file.Tokens)
+
+	if ActiveGitChanges != nil {
+		file.Status = ActiveGitChanges.StatusName(absPath)
+		if ActiveGitChanges.IncludePatch {
+			patch, err := ActiveGitChanges.Patch(absPath)
+			if err != nil {
+				PrintWarning(fmt.Sprintf("Could not get diff for '%s': %v", absPath, err), debug)
+			}
+			// The patch repeats the added lines, so it is redacted too.
+			file.Patch = redactFileContent(file.Path, patch, debug)
+		}
+	}
+	if r.languages == nil {
+		r.languages = make(map[string]*languageStats)
+	}
+	addLanguageStats(r.languages, file)
+	if r.emit != nil {
+		r.emit(file)
 		return nil
 	}
+	r.files = append(r.files, file)
 
-	fileDisplayName := relativeDisplayPath(result.rootPath, absPath, s.debug)
-	codeBlockLangHint := strings.ToLower(language)
+	if r.sourceFileContents == nil {
+		r.sourceFileContents = make(map[string][]string)
+	}
+	r.sourceFileContents[file.Language] = append(r.sourceFileContents[file.Language], renderMarkdownFile(file))
+	return nil
+}
+
+// renderMarkdownFile renders a collected file as a "### path" heading followed
+// by its code fence and, in diff mode, its patch.
+func renderMarkdownFile(file sourceFile) string {
+	codeBlockLangHint := strings.ToLower(file.Language)
 	codeBlockLangHint = strings.ReplaceAll(codeBlockLangHint, "/", "")
 	codeBlockLangHint = strings.ReplaceAll(codeBlockLangHint, "+", "p")
-	markdownContent := fmt.Sprintf("### %s\n```%s\n%s\n```\n", fileDisplayName, codeBlockLangHint, string(content))
-	result.sourceFileContents[language] = append(result.sourceFileContents[language], markdownContent)
-	return nil
+	fence := markdownFence(file.Content)
+	markdownContent := fmt.Sprintf("### %s\n%s%s\n%s\n%s\n", file.Path, fence, codeBlockLangHint, file.Content, fence)
+	if file.Patch != "" {
+		patchFence := markdownFence(file.Patch)
+		markdownContent += fmt.Sprintf("%sdiff\n%s\n%s\n", patchFence, file.Patch, patchFence)
+	}
+	return markdownContent
 }
 
 func relativeDisplayPath(root, absPath string, debug bool) string {
@@ -251,26 +759,32 @@ func relativeDisplayPath(root, absPath string, debug bool) string {
 	return filepath.ToSlash(relPath)
 }
 
-func buildDirectoryStructureMarkdown(rootPath string, maxDepth int, children map[string][]scannedEntry) string {
+func buildDirectoryStructureMarkdown(rootPath string, maxDepth int, children map[string][]ProjectEntry) string {
+	return directoryStructureMarkdown(directoryTreeLines(rootPath, maxDepth, children))
+}
+
+// directoryTreeLines draws the scanned structure as plain text lines,
+// starting with the ". (<root-name>)" line. Directories deeper than maxDepth
+// are folded into a "..." line.
+func directoryTreeLines(rootPath string, maxDepth int, children map[string][]ProjectEntry) []string {
 	var structureLines []string
-	structureLines = append(structureLines, "## Project Structure", "```text")
 
 	rootDisplayName := filepath.Base(rootPath)
 	structureLines = append(structureLines, fmt.Sprintf(". (%s)", rootDisplayName))
 
 	var generateTreeRecursive func(currentPath, prefix string, depth int)
 	generateTreeRecursive = func(currentPath, prefix string, depth int) {
-		entries := append([]scannedEntry(nil), children[currentPath]...)
+		entries := append([]ProjectEntry(nil), children[currentPath]...)
 		sort.Slice(entries, func(i, j int) bool {
-			return strings.ToLower(entries[i].name) < strings.ToLower(entries[j].name)
+			return strings.ToLower(entries[i].Name) < strings.ToLower(entries[j].Name)
 		})
 
-		var filesToShow []scannedEntry
-		var dirsToShow []scannedEntry
+		var filesToShow []ProjectEntry
+		var dirsToShow []ProjectEntry
 		var hasHiddenDirs bool
 
 		for _, entry := range entries {
-			if entry.isDir {
+			if entry.IsDir {
 				if maxDepth > 0 && depth < maxDepth {
 					dirsToShow = append(dirsToShow, entry)
 				} else {
@@ -290,14 +804,18 @@ func buildDirectoryStructureMarkdown(rootPath string, maxDepth int, children map
 				pointer = "└── "
 			}
 
-			structureLines = append(structureLines, prefix+pointer+entry.name)
+			line := prefix + pointer + entry.Name
+			if !entry.IsDir {
+				line += ActiveGitChanges.Marker(entry.Path)
+			}
+			structureLines = append(structureLines, line)
 
-			if entry.isDir {
+			if entry.IsDir {
 				extension := "│   "
 				if pointer == "└── " && !showEllipsis {
 					extension = "    "
 				}
-				generateTreeRecursive(entry.path, prefix+extension, depth+1)
+				generateTreeRecursive(entry.Path, prefix+extension, depth+1)
 			}
 		}
 
@@ -307,6 +825,5 @@ func buildDirectoryStructureMarkdown(rootPath string, maxDepth int, children map
 	}
 
 	generateTreeRecursive(rootPath, "", 0)
-	structureLines = append(structureLines, "```")
-	return strings.Join(structureLines, "\n") + "\n\n"
+	return structureLines
 }
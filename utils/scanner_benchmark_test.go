@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+// Synthetic project sizes for the scanner benchmarks: 40 packages of 5
+// directories with 20 files each, about 4,000 files with READMEs, tests,
+// assets, manifests and a gitignored build directory mixed in. The files
+// are one line long, so that walking and filtering dominate the timings.
+const (
+	benchmarkPackages     = 40
+	benchmarkDirsPerPkg   = 5
+	benchmarkFilesPerDir  = 20
+	benchmarkIgnoredFiles = 200
+)
+
+// createSyntheticProject writes the benchmark tree below a temporary
+// directory and returns its path.
+func createSyntheticProject(b *testing.B) string {
+	b.Helper()
+	root := b.TempDir()
+	body := "// synthetic source file for the scanner benchmark\n"
+
+	createTestFile(b, filepath.Join(root, ".gitignore"), "build/\n*.log\n")
+	createTestFile(b, filepath.Join(root, "README.md"), "# Synthetic project\n")
+	createTestFile(b, filepath.Join(root, "go.mod"), "module example.com/synthetic\n\ngo 1.24\n")
+	for i := 0; i < benchmarkIgnoredFiles; i++ {
+		createTestFile(b, filepath.Join(root, "build", fmt.Sprintf("out%03d.go", i)), body)
+	}
+	for p := 0; p < benchmarkPackages; p++ {
+		pkg := filepath.Join(root, fmt.Sprintf("pkg%02d", p))
+		createTestFile(b, filepath.Join(pkg, "README.md"), fmt.Sprintf("# Package %d\n", p))
+		createTestFile(b, filepath.Join(pkg, "package.json"), `{"name": "synthetic"}`)
+		for d := 0; d < benchmarkDirsPerPkg; d++ {
+			dir := filepath.Join(pkg, fmt.Sprintf("dir%d", d))
+			for f := 0; f < benchmarkFilesPerDir; f++ {
+				name := fmt.Sprintf("file%02d.go", f)
+				switch f % 10 {
+				case 7:
+					name = fmt.Sprintf("file%02d_test.go", f)
+				case 8:
+					name = fmt.Sprintf("image%02d.png", f)
+				case 9:
+					name = fmt.Sprintf("debug%02d.log", f)
+				}
+				createTestFile(b, filepath.Join(dir, name), body)
+			}
+		}
+	}
+	return root
+}
+
+func newSyntheticGitIgnore(b *testing.B, root string) *GitIgnoreMatcher {
+	b.Helper()
+	gi, err := NewGitIgnoreMatcher(root)
+	if err != nil {
+		b.Fatalf("Failed to create gitignore matcher: %v", err)
+	}
+	return gi
+}
+
+func BenchmarkProcessSourceFiles(b *testing.B) {
+	root := createSyntheticProject(b)
+	gi := newSyntheticGitIgnore(b, root)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ProcessSourceFiles(root, 10, map[string]struct{}{}, nil, DefaultExcludeNames, nil, false, false, gi)
+	}
+}
+
+// writeSummaryInTwoWalks writes the summary the way it was written before
+// the scanner collected everything in one walk: the tree in one walk, the
+// dependency files and sources in another. It is the baseline for
+// BenchmarkWriteProjectSummary.
+func writeSummaryInTwoWalks(w io.Writer, root string, maxDepth int, gi *GitIgnoreMatcher) error {
+	primaryLangs, fallbackLangs := detectProjectLanguages(root, DefaultExcludeNames, nil, false, gi)
+	activeLanguageDetector = newLanguageDetector(primaryLangs, fallbackLangs)
+
+	tree := &projectScanner{
+		rootPath:         root,
+		includePaths:     map[string]struct{}{},
+		excludeNames:     DefaultExcludeNames,
+		gi:               gi,
+		collectStructure: true,
+	}
+	treeResult, err := tree.scan()
+	if err != nil {
+		return err
+	}
+	sources := &projectScanner{
+		rootPath:            root,
+		includePaths:        map[string]struct{}{},
+		excludeNames:        DefaultExcludeNames,
+		gi:                  gi,
+		collectDependencies: true,
+		collectSources:      true,
+	}
+	result, candidates, err := sources.walk()
+	if err != nil {
+		return err
+	}
+
+	result.overview = newProjectOverview(primaryLangs, fallbackLangs)
+
+	out := bufio.NewWriter(w)
+	doc := newDocumentWriter(out, false)
+	doc.begin("", filepath.Base(root), directoryTreeLines(treeResult.rootPath, maxDepth, treeResult.structureChildren), result)
+	sources.commitSourceCandidates(result, candidates, doc.file)
+	for _, file := range orderedSourceFiles(result.files) {
+		doc.file(file)
+	}
+	doc.end(result)
+	return out.Flush()
+}
+
+// BenchmarkWriteProjectSummary compares the single scanner walk with the
+// two walks it replaced.
+func BenchmarkWriteProjectSummary(b *testing.B) {
+	root := createSyntheticProject(b)
+	gi := newSyntheticGitIgnore(b, root)
+
+	b.Run("single walk", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := WriteProjectSummary(io.Discard, "", root, 10, map[string]struct{}{}, nil, DefaultExcludeNames, nil, false, false, gi); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("two walks", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := writeSummaryInTwoWalks(io.Discard, root, 10, gi); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkProcessSourceFiles_Patterns(b *testing.B) {
+	root := createSyntheticProject(b)
+	gi := newSyntheticGitIgnore(b, root)
+	includeMatcher, err := NewSimpleMatcher(root, []string{"**/*.go", "**/*.md"})
+	if err != nil {
+		b.Fatalf("Failed to create include matcher: %v", err)
+	}
+	excludeMatcher, err := NewSimpleMatcher(root, []string{"pkg0*/dir4"})
+	if err != nil {
+		b.Fatalf("Failed to create exclude matcher: %v", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ProcessSourceFiles(root, 10, map[string]struct{}{}, includeMatcher, DefaultExcludeNames, excludeMatcher, false, false, gi)
+	}
+}
+
+func BenchmarkCollectReadmeFiles(b *testing.B) {
+	root := createSyntheticProject(b)
+	gi := newSyntheticGitIgnore(b, root)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		CollectReadmeFiles(root, map[string]struct{}{}, nil, DefaultExcludeNames, nil, false, gi)
+	}
+}
+
+func BenchmarkGenerateDirectoryStructure(b *testing.B) {
+	root := createSyntheticProject(b)
+	gi := newSyntheticGitIgnore(b, root)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		GenerateDirectoryStructure(root, 2, false, map[string]struct{}{}, nil, DefaultExcludeNames, nil, false, gi)
+	}
+}
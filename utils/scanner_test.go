@@ -7,6 +7,7 @@ import (
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -18,7 +19,6 @@ func newProjectScannerForTest(root string) *projectScanner {
 
 		includePaths: map[string]struct{}{},
 		excludeNames: map[string]struct{}{},
-		excludePaths: map[string]struct{}{},
 
 		processedDepFiles: map[string]struct{}{},
 
@@ -96,6 +96,52 @@ func TestProjectScannerScan_ExplicitIncludeCollectsAssetAsText(t *testing.T) {
 	assert.Contains(t, sourceMarkdown, "```text")
 }
 
+func TestBuildFileScanMeta(t *testing.T) {
+	tempDir := t.TempDir()
+	createTestFile(t, filepath.Join(tempDir, "deploy"), "#!/usr/bin/env python3\nprint(1)\n")
+	createTestFile(t, filepath.Join(tempDir, "firmware"), "\x00\x01\x02\x03binary")
+	createTestFile(t, filepath.Join(tempDir, "logo.png"), "\x89PNG\r\n\x1a\n")
+	createTestFile(t, filepath.Join(tempDir, "main_test.go"), "package main\n")
+	scanner := newProjectScannerForTest(tempDir)
+
+	meta := scanner.buildFileScanMeta(filepath.Join(tempDir, "deploy"))
+	assert.Equal(t, fileScanMeta{language: "Python"}, meta, "the shebang gives the language")
+
+	meta = scanner.buildFileScanMeta(filepath.Join(tempDir, "firmware"))
+	assert.Equal(t, fileScanMeta{isAsset: true, binary: true}, meta, "binary content without a language is an asset")
+
+	meta = scanner.buildFileScanMeta(filepath.Join(tempDir, "logo.png"))
+	assert.Equal(t, fileScanMeta{isAsset: true}, meta, "assets by name are not read")
+
+	meta = scanner.buildFileScanMeta(filepath.Join(tempDir, "main_test.go"))
+	assert.Equal(t, fileScanMeta{isTest: true}, meta, "test files are not classified further")
+}
+
+func TestBuildFileScanMeta_CachedClassSavesTheRead(t *testing.T) {
+	withFileCache(t)
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "deploy")
+	createTestFile(t, path, "#!/usr/bin/env python3\n")
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	scanner := newProjectScannerForTest(tempDir)
+
+	activeFileCache = openFileCache(tempDir, false)
+	t.Cleanup(func() { activeFileCache = nil })
+	assert.Equal(t, "Python", scanner.buildFileScanMeta(path).language)
+	activeFileCache.save(false)
+
+	// Same size and modification time: the cached class is used unread.
+	createTestFile(t, path, "#!/usr/bin/env ruby3.0\n")
+	require.NoError(t, os.Chtimes(path, info.ModTime(), info.ModTime()))
+	activeFileCache = openFileCache(tempDir, false)
+	assert.Equal(t, "Python", scanner.buildFileScanMeta(path).language)
+
+	later := info.ModTime().Add(time.Second)
+	require.NoError(t, os.Chtimes(path, later, later))
+	assert.Equal(t, "Ruby", scanner.buildFileScanMeta(path).language, "a changed file is read again")
+}
+
 func TestProjectScannerScan_SizeLimitBoundaries(t *testing.T) {
 	origMaxFileSize := MaxFileSizeBytes
 	origTotalMaxSize := TotalMaxFileSizeBytes
@@ -152,6 +198,27 @@ func TestProjectScannerScan_SizeLimitBoundaries(t *testing.T) {
 		assert.EqualValues(t, 3, result.totalFileSize)
 	})
 
+	t.Run("binary files do not count toward the total size", func(t *testing.T) {
+		tempDir := t.TempDir()
+		createTestFile(t, filepath.Join(tempDir, "a.go"), "a")
+		createTestFile(t, filepath.Join(tempDir, "blob.go"), "\x00\x01\x02\x03\x04\x05")
+
+		MaxFileSizeBytes = 1024
+		TotalMaxFileSizeBytes = 3
+
+		scanner := newProjectScannerForTest(tempDir)
+		scanner.collectStructure = false
+		scanner.collectReadmes = false
+		scanner.collectSources = true
+
+		result, err := scanner.scan()
+		require.NoError(t, err)
+
+		assert.Contains(t, flattenSourceMarkdownByLanguage(result.sourceFileContents), "### a.go")
+		assert.False(t, result.limitHit)
+		assert.Empty(t, result.omittedFiles)
+	})
+
 	t.Run("zero max file size only allows zero byte files", func(t *testing.T) {
 		tempDir := t.TempDir()
 		createTestFile(t, filepath.Join(tempDir, "empty.go"), "")
@@ -290,3 +357,157 @@ func TestProjectScannerScan_SpecialFileNames(t *testing.T) {
 	assert.Contains(t, sourceMarkdown, "deeply_nested.go")
 	assert.GreaterOrEqual(t, countSourceEntries(result.sourceFileContents), expectedMinEntries)
 }
+
+func TestProjectScannerScan_LanguageFilter(t *testing.T) {
+	withLanguageFilter(t, nil, []string{"Python"})
+
+	tempDir := t.TempDir()
+	createTestFile(t, filepath.Join(tempDir, "main.go"), "package main\n")
+	createTestFile(t, filepath.Join(tempDir, "gen.py"), "print(1)\n")
+	createTestFile(t, filepath.Join(tempDir, "NOTICE"), "notice\n")
+
+	result, err := newProjectScannerForTest(tempDir).scan()
+	require.NoError(t, err)
+
+	sourceMarkdown := flattenSourceMarkdownByLanguage(result.sourceFileContents)
+	assert.Contains(t, sourceMarkdown, "### main.go")
+	assert.NotContains(t, sourceMarkdown, "gen.py")
+
+	structureMD := buildDirectoryStructureMarkdown(result.rootPath, 10, result.structureChildren)
+	assert.Contains(t, structureMD, "main.go")
+	assert.Contains(t, structureMD, "NOTICE")
+	assert.NotContains(t, structureMD, "gen.py")
+}
+
+func TestProjectScannerScan_MatcherPatterns(t *testing.T) {
+	tempDir := t.TempDir()
+	createTestFile(t, filepath.Join(tempDir, "main.go"), "package main\n")
+	createTestFile(t, filepath.Join(tempDir, "docs", "guide.md"), "# Guide\n")
+	createTestFile(t, filepath.Join(tempDir, "docs", "drafts", "idea.md"), "# Idea\n")
+	createTestFile(t, filepath.Join(tempDir, "images", "logo.png"), "asset-bytes")
+
+	includeMatcher, err := NewSimpleMatcher(tempDir, []string{"**/*.md", "images/*.png"})
+	require.NoError(t, err)
+	excludeMatcher, err := NewSimpleMatcher(tempDir, []string{"docs/drafts"})
+	require.NoError(t, err)
+
+	scanner := newProjectScannerForTest(tempDir)
+	scanner.includeMatcher = includeMatcher
+	scanner.excludeMatcher = excludeMatcher
+
+	result, err := scanner.scan()
+	require.NoError(t, err)
+
+	sourceMarkdown := flattenSourceMarkdownByLanguage(result.sourceFileContents)
+	assert.Contains(t, sourceMarkdown, "### docs/guide.md")
+	assert.Contains(t, sourceMarkdown, "### images/logo.png\n```text")
+	assert.NotContains(t, sourceMarkdown, "main.go")
+	assert.NotContains(t, sourceMarkdown, "idea.md")
+
+	structureMD := buildDirectoryStructureMarkdown(result.rootPath, 10, result.structureChildren)
+	assert.Contains(t, structureMD, "guide.md")
+	assert.Contains(t, structureMD, "logo.png")
+	assert.NotContains(t, structureMD, "drafts")
+	assert.NotContains(t, structureMD, "main.go")
+}
+
+func TestProjectScannerScan_DependenciesAreNotSources(t *testing.T) {
+	tempDir := t.TempDir()
+	createTestFile(t, filepath.Join(tempDir, "package.json"), `{"dependencies": {"react": "^18"}}`)
+	createTestFile(t, filepath.Join(tempDir, "index.js"), "console.log(1)\n")
+
+	scanner := newProjectScannerForTest(tempDir)
+	scanner.collectDependencies = true
+
+	result, err := scanner.scan()
+	require.NoError(t, err)
+
+	require.Len(t, result.dependencies, 1)
+	assert.Equal(t, "package.json", result.dependencies[0].Path)
+	sourceMarkdown := flattenSourceMarkdownByLanguage(result.sourceFileContents)
+	assert.Contains(t, sourceMarkdown, "### index.js")
+	assert.NotContains(t, sourceMarkdown, "package.json")
+
+	structureMD := buildDirectoryStructureMarkdown(result.rootPath, 10, result.structureChildren)
+	assert.Contains(t, structureMD, "package.json")
+}
+
+func TestListProjectTree_ListsEveryFileUpToMaxDepth(t *testing.T) {
+	tempDir := t.TempDir()
+	createTestFile(t, filepath.Join(tempDir, "main_test.go"), "package main\n")
+	createTestFile(t, filepath.Join(tempDir, "logo.png"), "asset-bytes")
+	createTestFile(t, filepath.Join(tempDir, "src", "deep", "util.go"), "package deep\n")
+
+	children, err := ListProjectTree(tempDir, 1, map[string]struct{}{}, nil)
+	require.NoError(t, err)
+
+	var names []string
+	for _, entry := range children[tempDir] {
+		names = append(names, entry.Name)
+	}
+	assert.ElementsMatch(t, []string{"main_test.go", "logo.png", "src"}, names)
+	assert.Empty(t, children[filepath.Join(tempDir, "src")], "directories at maxDepth are not entered")
+}
+
+func TestCommitSourceCandidates_EmitsFilesInOutputOrder(t *testing.T) {
+	root := t.TempDir()
+	createTestFile(t, filepath.Join(root, "z.go"), "package z\n")
+	createTestFile(t, filepath.Join(root, "pkg", "a.go"), "package a\n")
+	createTestFile(t, filepath.Join(root, "m.py"), "print(1)\n")
+
+	collect := func(t *testing.T) (*scanResult, []string) {
+		scanner := newProjectScannerForTest(root)
+		result, candidates, err := scanner.walk()
+		require.NoError(t, err)
+		var emitted []string
+		scanner.commitSourceCandidates(result, candidates, func(file sourceFile) {
+			emitted = append(emitted, file.Path)
+		})
+		return result, emitted
+	}
+
+	t.Run("without budget", func(t *testing.T) {
+		result, emitted := collect(t)
+		assert.Equal(t, []string{"pkg/a.go", "z.go", "m.py"}, emitted)
+		assert.Empty(t, result.files, "emitted files are not kept")
+		assert.Empty(t, countSourceEntries(result.sourceFileContents))
+		require.Contains(t, result.languages, "Go")
+		assert.Equal(t, 2, result.languages["Go"].Files)
+	})
+
+	t.Run("with budget", func(t *testing.T) {
+		origMaxTokens := MaxTokens
+		t.Cleanup(func() { MaxTokens = origMaxTokens })
+		MaxTokens = 1000
+
+		result, emitted := collect(t)
+		assert.Empty(t, emitted, "files are held until the budget is spent")
+		assert.Len(t, result.files, 3)
+	})
+}
+
+func TestWouldCollect(t *testing.T) {
+	root := t.TempDir()
+	createTestFile(t, filepath.Join(root, ".gitignore"), "context.md\n")
+	for _, name := range []string{"main.go", "notes.md", "context.md", "go.mod", "main_test.go", "logo.png", "README.md", "build/out.md"} {
+		createTestFile(t, filepath.Join(root, name), "x\n")
+	}
+	gi, err := NewGitIgnoreMatcher(root)
+	require.NoError(t, err)
+	excludeNames := map[string]struct{}{"build": {}}
+	wouldCollect := func(name string, readmeOnly bool) bool {
+		return WouldCollect(root, filepath.Join(root, name), map[string]struct{}{}, nil, excludeNames, nil, readmeOnly, false, gi)
+	}
+
+	assert.True(t, wouldCollect("main.go", false))
+	assert.True(t, wouldCollect("notes.md", false))
+	assert.True(t, wouldCollect("go.mod", false), "dependency files are collected")
+	assert.False(t, wouldCollect("context.md", false), "gitignored files are not collected")
+	assert.False(t, wouldCollect("main_test.go", false), "tests are excluded by default")
+	assert.False(t, wouldCollect("logo.png", false))
+	assert.False(t, wouldCollect("build/out.md", false), "files below excluded directories are not collected")
+	assert.False(t, wouldCollect("missing.go", false), "only existing files are overwritten")
+	assert.False(t, wouldCollect("notes.md", true))
+	assert.True(t, wouldCollect("README.md", true))
+	assert.False(t, WouldCollect(root, filepath.Join(t.TempDir(), "main.go"), map[string]struct{}{}, nil, excludeNames, nil, false, false, gi), "files outside the folder are not collected")
+}
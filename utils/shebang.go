@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// modelineLines is how many lines at the start of a file are searched for a
+// Vim or Emacs modeline.
+const modelineLines = 5
+
+var (
+	// vimModeline matches "vim: set ft=python:", "vi: filetype=sh" and
+	// "ex: syntax=ruby" anywhere on a line.
+	vimModeline = regexp.MustCompile(`(?:^|\s)(?:vim?|ex):.*?\b(?:ft|filetype|syntax)=([\w+#-]+)`)
+	// emacsModeline matches "-*- mode: python -*-" and "-*- python -*-".
+	emacsModeline = regexp.MustCompile(`-\*-\s*(?:.*?\bmode:\s*)?([\w+#-]+)\s*(?:;.*?)?-\*-`)
+	// interpreterVersion strips versions such as "3" or "3.11" from
+	// interpreter names.
+	interpreterVersion = regexp.MustCompile(`[\d.]+$`)
+)
+
+// headLanguage returns the language that the start of a file names: its
+// shebang line or a modeline when name has no known language, or the
+// first matching heuristic when the extension of name is ambiguous.
+func headLanguage(name string, head []byte) string {
+	if GetLanguageByExtension(name) == "" {
+		if lang := languageFromShebang(head); lang != "" {
+			return lang
+		}
+		return languageFromModeline(head)
+	}
+	for _, heuristic := range languageHeuristics[extensionKey(name)] {
+		if heuristic.Pattern.Match(head) {
+			return heuristic.Language
+		}
+	}
+	return ""
+}
+
+// languageFromShebang maps the interpreter of a "#!" first line to a
+// language. "#!/usr/bin/env -S node --flags" resolves to the program after
+// env and its options.
+func languageFromShebang(head []byte) string {
+	if !bytes.HasPrefix(head, []byte("#!")) {
+		return ""
+	}
+	line := head[2:]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return ""
+	}
+
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		interpreter = ""
+		for _, field := range fields[1:] {
+			if strings.HasPrefix(field, "-") || strings.Contains(field, "=") {
+				continue
+			}
+			interpreter = filepath.Base(field)
+			break
+		}
+	}
+	interpreter = strings.ToLower(interpreter)
+	if lang, ok := INTERPRETERS[interpreter]; ok {
+		return lang
+	}
+	return INTERPRETERS[interpreterVersion.ReplaceAllString(interpreter, "")]
+}
+
+// languageFromModeline looks for a Vim or Emacs modeline in the first
+// modelineLines lines and maps its file type to a language.
+func languageFromModeline(head []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(head))
+	for i := 0; i < modelineLines && scanner.Scan(); i++ {
+		line := scanner.Text()
+		for _, modeline := range []*regexp.Regexp{vimModeline, emacsModeline} {
+			if match := modeline.FindStringSubmatch(line); match != nil {
+				if lang := languageByName(match[1]); lang != "" {
+					return lang
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// languageByName maps a modeline file type such as "python", "sh" or "cpp"
+// to a language in EXTENSIONS.
+func languageByName(name string) string {
+	key := strings.ToLower(name)
+	if lang, ok := INTERPRETERS[key]; ok {
+		return lang
+	}
+	if lang, ok := modelineFileTypes[key]; ok {
+		return lang
+	}
+	for lang := range EXTENSIONS {
+		if strings.ToLower(lang) == key {
+			return lang
+		}
+	}
+	if candidates := extensionLanguages["."+key]; len(candidates) > 0 {
+		return candidates[0]
+	}
+	return ""
+}
+
+// modelineFileTypes are Vim and Emacs file type names that differ from both
+// the language names and the extensions.
+var modelineFileTypes = map[string]string{
+	"cpp":        "C++",
+	"objc":       "Objective-C",
+	"cs":         "C#",
+	"make":       "Makefile",
+	"dosbatch":   "Batch",
+	"js":         "Javascript",
+	"javascript": "Javascript",
+	"typescript": "Typescript",
+	"ps1":        "PowerShell",
+	"conf":       "INI",
+	"dockerfile": "Dockerfile",
+	"emacs-lisp": "Emacs Lisp",
+	"elisp":      "Emacs Lisp",
+	"tex":        "LaTeX",
+}
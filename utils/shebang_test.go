@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLanguageFromShebang(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{line: "#!/usr/bin/env python3\n", expected: "Python"},
+		{line: "#!/usr/bin/python3.11 -u\n", expected: "Python"},
+		{line: "#!/bin/bash\nset -e\n", expected: "Bash"},
+		{line: "#!/bin/sh\n", expected: "Shell"},
+		{line: "#! /usr/bin/env node\n", expected: "Javascript"},
+		{line: "#!/usr/bin/env -S deno run --allow-net\n", expected: "Typescript"},
+		{line: "#!/usr/bin/env FOO=1 ruby\n", expected: "Ruby"},
+		{line: "#!/usr/bin/perl -w\n", expected: "Perl"},
+		{line: "#!/usr/bin/env Rscript\n", expected: "R"},
+		{line: "#!/usr/bin/env unknown-tool\n", expected: ""},
+		{line: "echo no shebang\n", expected: ""},
+		{line: "#!\n", expected: ""},
+	}
+
+	for _, tt := range tests {
+		if actual := languageFromShebang([]byte(tt.line)); actual != tt.expected {
+			t.Errorf("languageFromShebang(%q): expected %q, got %q", tt.line, tt.expected, actual)
+		}
+	}
+}
+
+func TestLanguageFromModeline(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected string
+	}{
+		{content: "# vim: set ft=python:\n", expected: "Python"},
+		{content: "line\n# vi: filetype=sh\n", expected: "Shell"},
+		{content: "// vim: syntax=cpp\n", expected: "C++"},
+		{content: "# -*- mode: ruby -*-\n", expected: "Ruby"},
+		{content: ";; -*- emacs-lisp -*-\n", expected: "Emacs Lisp"},
+		{content: "# -*- mode: yaml; indent-tabs-mode: nil -*-\n", expected: "YAML"},
+		{content: "# vim: ft=Rscript\n", expected: "R"},
+		{content: "1\n2\n3\n4\n5\n# vim: ft=python\n", expected: ""},
+		{content: "plain text\n", expected: ""},
+	}
+
+	for _, tt := range tests {
+		if actual := languageFromModeline([]byte(tt.content)); actual != tt.expected {
+			t.Errorf("languageFromModeline(%q): expected %q, got %q", tt.content, tt.expected, actual)
+		}
+	}
+}
+
+func TestProcessSourceFiles_ExtensionlessFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	createTestFile(t, filepath.Join(tempDir, "bin", "deploy"), "#!/usr/bin/env bash\necho deploy\n")
+	createTestFile(t, filepath.Join(tempDir, "scripts", "release"), "#!/usr/bin/env python3\nprint('release')\n")
+	createTestFile(t, filepath.Join(tempDir, "tools", "lint"), "# vim: ft=ruby\nputs 'lint'\n")
+	createTestFile(t, filepath.Join(tempDir, "Jenkinsfile"), "pipeline {}\n")
+	createTestFile(t, filepath.Join(tempDir, "Rakefile"), "task :default\n")
+	createTestFile(t, filepath.Join(tempDir, "LICENSE"), "MIT\n")
+
+	output := ProcessSourceFiles(tempDir, 5, map[string]struct{}{}, nil, map[string]struct{}{}, nil, false, false, nil)
+
+	for _, want := range []string{
+		"### bin/deploy\n```bash",
+		"### scripts/release\n```python",
+		"### tools/lint\n```ruby",
+		"### Jenkinsfile\n```groovy",
+		"### Rakefile\n```ruby",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "### LICENSE") {
+		t.Errorf("expected LICENSE to stay out of the sources:\n%s", output)
+	}
+}
@@ -0,0 +1,308 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// SplitLimit is the budget of one output part for --split-size, in bytes or
+// in tokens. The zero value disables splitting.
+type SplitLimit struct {
+	Bytes  int64
+	Tokens int64
+}
+
+// Enabled reports whether a split budget is set.
+func (l SplitLimit) Enabled() bool {
+	return l.Bytes > 0 || l.Tokens > 0
+}
+
+func (l SplitLimit) measure(text string) int64 {
+	if l.Tokens > 0 {
+		return int64(CountTokens(text))
+	}
+	return int64(len(text))
+}
+
+func (l SplitLimit) budget() int64 {
+	if l.Tokens > 0 {
+		return l.Tokens
+	}
+	return l.Bytes
+}
+
+var splitTokensPattern = regexp.MustCompile(`(?i)^(\d+(?:\.\d+)?\s*[km]?)\s*-?\s*(?:t|tokens?)$`)
+
+// ParseSplitSize parses a --split-size value: a size such as 500k or 2m, or
+// a token count with a "tokens" or "t" suffix such as 32k-tokens or 32kt.
+func ParseSplitSize(value string) (SplitLimit, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return SplitLimit{}, nil
+	}
+	if matches := splitTokensPattern.FindStringSubmatch(value); matches != nil {
+		tokens, err := ParseTokenCount(matches[1])
+		if err != nil {
+			return SplitLimit{}, err
+		}
+		if tokens <= 0 {
+			return SplitLimit{}, fmt.Errorf("split size must be positive: %s", value)
+		}
+		return SplitLimit{Tokens: tokens}, nil
+	}
+	bytes, err := ParseSize(value)
+	if err != nil {
+		return SplitLimit{}, fmt.Errorf("invalid split size: %s (expected a size such as 500k or a token count such as 32k-tokens)", value)
+	}
+	if bytes <= 0 {
+		return SplitLimit{}, fmt.Errorf("split size must be positive: %s", value)
+	}
+	return SplitLimit{Bytes: bytes}, nil
+}
+
+// markdownBlock is a heading with everything up to the next heading. Blocks
+// are the units the output is split at; path is set for "### <path>" file
+// blocks.
+type markdownBlock struct {
+	path string
+	text string
+}
+
+// parseMarkdownBlocks cuts Markdown output at the "#", "##" and "###"
+// headings outside code fences. A heading with no content of its own, such
+// as "## Dependency and Configuration Files", is kept with the next block.
+func parseMarkdownBlocks(output string) []markdownBlock {
+	var blocks []markdownBlock
+	var current strings.Builder
+	currentPath := ""
+	pendingHeading := ""
+	fence := ""
+	flush := func() {
+		text := current.String()
+		current.Reset()
+		if strings.TrimSpace(text) == "" {
+			return
+		}
+		if currentPath == "" && strings.Count(strings.TrimRight(text, "\n"), "\n") == 0 && strings.HasPrefix(text, "#") {
+			pendingHeading += text
+			return
+		}
+		blocks = append(blocks, markdownBlock{path: currentPath, text: pendingHeading + text})
+		pendingHeading = ""
+	}
+
+	for _, line := range strings.SplitAfter(output, "\n") {
+		trimmed := strings.TrimRight(line, "\n")
+		switch {
+		case fence != "":
+			if strings.Trim(trimmed, "`") == "" && len(trimmed) >= len(fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```"):
+			fence = trimmed[:len(trimmed)-len(strings.TrimLeft(trimmed, "`"))]
+		case strings.HasPrefix(trimmed, "# ") || strings.HasPrefix(trimmed, "## ") || strings.HasPrefix(trimmed, "### "):
+			flush()
+			currentPath = ""
+			if strings.HasPrefix(trimmed, "### ") {
+				currentPath = strings.TrimPrefix(trimmed, "### ")
+			}
+		}
+		current.WriteString(line)
+	}
+	flush()
+	if pendingHeading != "" {
+		blocks = append(blocks, markdownBlock{text: pendingHeading})
+	}
+	return blocks
+}
+
+// splitOversizedBlock cuts a block larger than budget at line boundaries.
+// An open code fence is closed at the end of a piece and reopened in the
+// next one, under a "(continued)" heading.
+func splitOversizedBlock(block markdownBlock, budget int64, limit SplitLimit) []markdownBlock {
+	var pieces []markdownBlock
+	var current strings.Builder
+	fence, fenceLine := "", ""
+	continuedHeading := ""
+	if block.path != "" {
+		continuedHeading = "### " + block.path + " (continued)\n"
+	}
+
+	var used int64
+	for _, line := range strings.SplitAfter(block.text, "\n") {
+		closing := ""
+		if fence != "" {
+			closing = fence + "\n"
+		}
+		lineSize := limit.measure(line)
+		if current.Len() > 0 && used+lineSize+limit.measure(closing) > budget {
+			pieces = append(pieces, markdownBlock{path: block.path, text: current.String() + closing})
+			current.Reset()
+			current.WriteString(continuedHeading)
+			if fence != "" {
+				current.WriteString(fenceLine)
+			}
+			used = limit.measure(current.String())
+		}
+		current.WriteString(line)
+		used += lineSize
+
+		trimmed := strings.TrimRight(line, "\n")
+		switch {
+		case fence != "":
+			if strings.Trim(trimmed, "`") == "" && len(trimmed) >= len(fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```"):
+			fence = trimmed[:len(trimmed)-len(strings.TrimLeft(trimmed, "`"))]
+			fenceLine = trimmed + "\n"
+		}
+	}
+	if current.Len() > 0 {
+		pieces = append(pieces, markdownBlock{path: block.path, text: current.String()})
+	}
+	return pieces
+}
+
+// outputPart is one chunk of split output with the files it holds.
+type outputPart struct {
+	blocks []markdownBlock
+	paths  []string
+}
+
+// packMarkdownBlocks fills parts with blocks in order, starting a new part
+// when the next block would exceed budget. firstReserve is kept free in the
+// first part for the part index.
+func packMarkdownBlocks(blocks []markdownBlock, budget, firstReserve int64, limit SplitLimit) []outputPart {
+	var parts []outputPart
+	var current outputPart
+	var used int64
+	partBudget := budget - firstReserve
+	addPiece := func(piece markdownBlock) {
+		size := limit.measure(piece.text)
+		if len(current.blocks) > 0 && used+size > partBudget {
+			parts = append(parts, current)
+			current, used, partBudget = outputPart{}, 0, budget
+		}
+		current.blocks = append(current.blocks, piece)
+		if piece.path != "" && (len(current.paths) == 0 || current.paths[len(current.paths)-1] != piece.path) {
+			current.paths = append(current.paths, piece.path)
+		}
+		used += size
+	}
+
+	for _, block := range blocks {
+		if limit.measure(block.text) <= partBudget-used || limit.measure(block.text) <= budget {
+			addPiece(block)
+			continue
+		}
+		// Only a block larger than a whole part is cut; start it on a fresh
+		// part so that as few pieces as possible are made.
+		if len(current.blocks) > 0 {
+			parts = append(parts, current)
+			current, used, partBudget = outputPart{}, 0, budget
+		}
+		for _, piece := range splitOversizedBlock(block, partBudget, limit) {
+			addPiece(piece)
+		}
+	}
+	if len(current.blocks) > 0 || len(parts) == 0 {
+		parts = append(parts, current)
+	}
+	return parts
+}
+
+// SplitMarkdownOutput splits Markdown output into parts that each fit limit
+// together with prompt. Files are only cut when a single file exceeds a part.
+// Every part starts with the prompt and a "Part N of M" marker, and the first
+// part carries an index of the files in each part. The project structure and
+// the other sections before the first file stay in the first part.
+func SplitMarkdownOutput(output, prompt string, limit SplitLimit) []string {
+	blocks := parseMarkdownBlocks(output)
+
+	var allPaths []string
+	for _, block := range blocks {
+		if block.path != "" {
+			allPaths = append(allPaths, block.path)
+		}
+	}
+	// The index and marker only grow with the number of digits in a part
+	// number, so rendering them with the largest possible numbers gives a
+	// safe reservation.
+	maxParts := len(blocks) + 1
+	overhead := limit.measure(formatSplitPart(prompt, maxParts, maxParts, ""))
+	indexReserve := limit.measure(buildPartIndex([]outputPart{{paths: allPaths}}, maxParts))
+	budget := limit.budget() - overhead
+	if budget < 1 {
+		budget = 1
+	}
+
+	parts := packMarkdownBlocks(blocks, budget, indexReserve, limit)
+	rendered := make([]string, 0, len(parts))
+	for i, part := range parts {
+		var body strings.Builder
+		if i == 0 && len(parts) > 1 {
+			body.WriteString(buildPartIndex(parts, 0))
+			body.WriteString("\n")
+		}
+		for _, block := range part.blocks {
+			body.WriteString(block.text)
+		}
+		rendered = append(rendered, formatSplitPart(prompt, i+1, len(parts), body.String()))
+	}
+	return rendered
+}
+
+func formatSplitPart(prompt string, number, total int, body string) string {
+	return FormatWithPrompt(prompt, fmt.Sprintf("> Part %d of %d\n\n%s", number, total, body))
+}
+
+// buildPartIndex lists the files of each part. With forcePart set every file
+// is listed under that part number, which sizes the index before packing.
+func buildPartIndex(parts []outputPart, forcePart int) string {
+	lines := []string{"## Part Index", "", "| File | Part |", "| --- | ---: |"}
+	for i, part := range parts {
+		number := i + 1
+		if forcePart > 0 {
+			number = forcePart
+		}
+		for _, path := range part.paths {
+			lines = append(lines, fmt.Sprintf("| `%s` | %d |", path, number))
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// SplitPartPath returns the path of part number of outputPath, so out.md
+// becomes out.part-001.md.
+func SplitPartPath(outputPath string, number int) string {
+	ext := filepath.Ext(outputPath)
+	return fmt.Sprintf("%s.part-%03d%s", strings.TrimSuffix(outputPath, ext), number, ext)
+}
+
+// SaveSplitOutput writes each part to its SplitPartPath and returns the
+// paths written. Once all are written, the parts an earlier run wrote past
+// the last one are removed, so a reader does not take them for this output.
+func SaveSplitOutput(parts []string, outputPath string) ([]string, error) {
+	paths := make([]string, 0, len(parts))
+	for i, part := range parts {
+		path := SplitPartPath(outputPath, i+1)
+		if err := SaveToMarkdown(part, path); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	for number := len(parts) + 1; ; number++ {
+		stale := SplitPartPath(outputPath, number)
+		if _, err := os.Lstat(stale); err != nil {
+			break
+		}
+		if err := os.Remove(stale); err != nil {
+			return paths, fmt.Errorf("could not remove stale part %s: %w", stale, err)
+		}
+	}
+	return paths, nil
+}
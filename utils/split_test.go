@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseSplitSize(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected SplitLimit
+		wantErr  bool
+	}{
+		{value: "", expected: SplitLimit{}},
+		{value: "500k", expected: SplitLimit{Bytes: 500 * 1024}},
+		{value: "2m", expected: SplitLimit{Bytes: 2 * 1024 * 1024}},
+		{value: "32k-tokens", expected: SplitLimit{Tokens: 32000}},
+		{value: "32kt", expected: SplitLimit{Tokens: 32000}},
+		{value: "8000 tokens", expected: SplitLimit{Tokens: 8000}},
+		{value: "0", wantErr: true},
+		{value: "lots", wantErr: true},
+	}
+	for _, tt := range tests {
+		actual, err := ParseSplitSize(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSplitSize(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if actual != tt.expected {
+			t.Errorf("ParseSplitSize(%q) = %+v, expected %+v", tt.value, actual, tt.expected)
+		}
+	}
+}
+
+func TestParseMarkdownBlocks(t *testing.T) {
+	output := "## Project Structure\n```text\n. (demo)\n## not a heading\n```\n\n### a.go\n```go\npackage a\n```\n\n## Dependency and Configuration Files\n\n### go.mod\n```\nmodule demo\n```\n"
+	blocks := parseMarkdownBlocks(output)
+	if len(blocks) != 3 {
+		t.Fatalf("expected 3 blocks, got %d: %+v", len(blocks), blocks)
+	}
+	if blocks[0].path != "" || !strings.Contains(blocks[0].text, "## not a heading") {
+		t.Errorf("expected the tree with its fenced content as one block, got %+v", blocks[0])
+	}
+	if blocks[1].path != "a.go" {
+		t.Errorf("expected a.go, got %+v", blocks[1])
+	}
+	if blocks[2].path != "go.mod" || !strings.HasPrefix(blocks[2].text, "## Dependency and Configuration Files\n\n### go.mod") {
+		t.Errorf("expected the section heading to stay with go.mod, got %+v", blocks[2])
+	}
+	if strings.Join([]string{blocks[0].text, blocks[1].text, blocks[2].text}, "") != output {
+		t.Error("expected the blocks to add up to the output")
+	}
+}
+
+func splitTestOutput(files int, lines int) string {
+	var b strings.Builder
+	b.WriteString("## Project Structure\n```text\n. (demo)\n```\n\n")
+	for i := 1; i <= files; i++ {
+		fmt.Fprintf(&b, "### file%02d.go\n```go\n", i)
+		for j := 0; j < lines; j++ {
+			fmt.Fprintf(&b, "var v%d_%d = %d\n", i, j, j)
+		}
+		b.WriteString("```\n\n")
+	}
+	return b.String()
+}
+
+func TestSplitMarkdownOutput(t *testing.T) {
+	output := splitTestOutput(10, 20)
+	limit := SplitLimit{Bytes: 1500}
+	parts := SplitMarkdownOutput(output, "Review this.", limit)
+	if len(parts) < 3 {
+		t.Fatalf("expected several parts, got %d", len(parts))
+	}
+
+	seen := make(map[string]int)
+	for i, part := range parts {
+		if int64(len(part)) > limit.Bytes {
+			t.Errorf("part %d has %d bytes, over the %d limit", i+1, len(part), limit.Bytes)
+		}
+		if !strings.HasPrefix(part, fmt.Sprintf("Review this.\n\n> Part %d of %d\n", i+1, len(parts))) {
+			t.Errorf("part %d does not start with the prompt and marker:\n%s", i+1, part)
+		}
+		for _, block := range parseMarkdownBlocks(part) {
+			if block.path != "" {
+				seen[block.path]++
+			}
+		}
+	}
+	for i := 1; i <= 10; i++ {
+		if path := fmt.Sprintf("file%02d.go", i); seen[path] != 1 {
+			t.Errorf("expected %s in exactly one part, found in %d", path, seen[path])
+		}
+	}
+	if !strings.Contains(parts[0], "## Part Index") || !strings.Contains(parts[0], "## Project Structure") {
+		t.Errorf("expected the index and the project structure in part 1:\n%s", parts[0])
+	}
+	if !strings.Contains(parts[0], fmt.Sprintf("| `file10.go` | %d |", len(parts))) {
+		t.Errorf("expected the index to place file10.go in the last part:\n%s", parts[0])
+	}
+}
+
+func TestSplitMarkdownOutput_OversizedFile(t *testing.T) {
+	output := splitTestOutput(1, 200)
+	limit := SplitLimit{Bytes: 1200}
+	parts := SplitMarkdownOutput(output, "", limit)
+	if len(parts) < 3 {
+		t.Fatalf("expected the large file to be cut into several parts, got %d", len(parts))
+	}
+	for i, part := range parts {
+		if int64(len(part)) > limit.Bytes {
+			t.Errorf("part %d has %d bytes, over the %d limit", i+1, len(part), limit.Bytes)
+		}
+		if strings.Count(part, "```")%2 != 0 {
+			t.Errorf("part %d has an unbalanced code fence:\n%s", i+1, part)
+		}
+	}
+	// Part 1 holds the project structure; the file starts on part 2.
+	if !strings.Contains(parts[1], "### file01.go\n```go\n") || !strings.Contains(parts[2], "### file01.go (continued)\n```go\n") {
+		t.Errorf("expected the continuation to reopen the fence:\n%s", parts[2])
+	}
+}
+
+func TestSplitMarkdownOutput_Tokens(t *testing.T) {
+	limit := SplitLimit{Tokens: 400}
+	for i, part := range SplitMarkdownOutput(splitTestOutput(10, 20), "", limit) {
+		if tokens := CountTokens(part); int64(tokens) > limit.Tokens {
+			t.Errorf("part %d has %d tokens, over the %d limit", i+1, tokens, limit.Tokens)
+		}
+	}
+}
+
+func TestSaveSplitOutput(t *testing.T) {
+	if actual := SplitPartPath("out/context.md", 2); actual != "out/context.part-002.md" {
+		t.Errorf("unexpected part path %q", actual)
+	}
+	if actual := SplitPartPath("context", 12); actual != "context.part-012" {
+		t.Errorf("unexpected part path %q", actual)
+	}
+
+	outputPath := filepath.Join(t.TempDir(), "out.md")
+	paths, err := SaveSplitOutput([]string{"one", "two"}, outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 2 {
+		t.Fatalf("expected two paths, got %v", paths)
+	}
+	content, err := os.ReadFile(paths[1])
+	if err != nil || string(content) != "two" {
+		t.Errorf("expected part 2 to hold its content, got %q (%v)", content, err)
+	}
+}
+
+func TestSaveSplitOutput_RemovesStaleParts(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "out.md")
+	if _, err := SaveSplitOutput([]string{"1", "2", "3", "4", "5", "6"}, outputPath); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := SaveSplitOutput([]string{"one", "two"}, outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 2 {
+		t.Fatalf("expected two paths, got %v", paths)
+	}
+	for number := 3; number <= 6; number++ {
+		if _, err := os.Stat(SplitPartPath(outputPath, number)); !os.IsNotExist(err) {
+			t.Errorf("expected stale part %d to be removed, got %v", number, err)
+		}
+	}
+	content, err := os.ReadFile(SplitPartPath(outputPath, 2))
+	if err != nil || string(content) != "two" {
+		t.Errorf("expected part 2 to hold the new content, got %q (%v)", content, err)
+	}
+}
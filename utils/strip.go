@@ -0,0 +1,497 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Strip modes accepted by --strip.
+const (
+	StripComments   = "comments"
+	StripLicense    = "license"
+	StripBlank      = "blank"
+	StripDocstrings = "docstrings"
+)
+
+// StripOptions selects what --strip removes from collected source files.
+type StripOptions struct {
+	// Comments removes comments other than doc comments and directives.
+	Comments bool
+	// License removes leading comment blocks that hold a license header.
+	License bool
+	// Blank removes blank lines.
+	Blank bool
+	// Docstrings removes doc comments and Python docstrings.
+	Docstrings bool
+}
+
+// Enabled reports whether any strip mode is set.
+func (o StripOptions) Enabled() bool {
+	return o.Comments || o.License || o.Blank || o.Docstrings
+}
+
+// ActiveStripOptions is applied to every collected source file.
+var ActiveStripOptions StripOptions
+
+// ParseStripOptions validates the modes given to --strip.
+func ParseStripOptions(modes []string) (StripOptions, error) {
+	var opts StripOptions
+	for _, mode := range modes {
+		switch strings.ToLower(strings.TrimSpace(mode)) {
+		case "":
+		case StripComments:
+			opts.Comments = true
+		case StripLicense:
+			opts.License = true
+		case StripBlank:
+			opts.Blank = true
+		case StripDocstrings:
+			opts.Docstrings = true
+		default:
+			return StripOptions{}, fmt.Errorf("unknown strip mode '%s' (use %s, %s, %s or %s)", mode, StripComments, StripLicense, StripBlank, StripDocstrings)
+		}
+	}
+	return opts, nil
+}
+
+// licenseHeaderPattern identifies a comment block as a license header.
+var licenseHeaderPattern = regexp.MustCompile(`(?i)copyright|licen[cs]e|spdx-license-identifier|permission is hereby granted|all rights reserved`)
+
+// regexpPrecedingChars are the characters after which a "/" starts a regular
+// expression literal rather than a division.
+const regexpPrecedingChars = "(,=:[!&|?{};+-*%<>~^"
+
+// commentSpan is a comment or docstring found in a source file.
+type commentSpan struct {
+	start, end int
+	doc        bool
+	directive  bool
+	// replacement is written in place of a removed span; a docstring that is
+	// the whole body of a Python function is replaced with "...".
+	replacement string
+}
+
+// stripSource removes what opts selects from text, using the comment syntax
+// of language. Languages without an entry in COMMENT_SYNTAX only have blank
+// lines removed.
+func stripSource(text, language string, opts StripOptions) string {
+	if !opts.Enabled() {
+		return text
+	}
+
+	var removed []commentSpan
+	if syntax, ok := COMMENT_SYNTAX[language]; ok && (opts.Comments || opts.License || opts.Docstrings) {
+		spans := scanComments(text, syntax)
+		license := make(map[int]bool)
+		if opts.License {
+			for _, i := range licenseHeaderSpans(text, spans) {
+				license[i] = true
+			}
+		}
+		for i, span := range spans {
+			switch {
+			case license[i]:
+			case span.directive:
+				continue
+			case span.doc && !opts.Docstrings:
+				continue
+			case !span.doc && !opts.Comments:
+				continue
+			}
+			removed = append(removed, span)
+		}
+	}
+	if len(removed) == 0 && !opts.Blank {
+		return text
+	}
+	return removeSpans(text, removed, opts.Blank)
+}
+
+// literalSpan is a string, character or regular expression literal.
+type literalSpan struct {
+	start, end int
+}
+
+// scanComments returns the comments and docstrings of text in order.
+func scanComments(text string, syntax CommentSyntax) []commentSpan {
+	comments, _ := scanSource(text, syntax)
+	return comments
+}
+
+// scanSource returns the comments and docstrings of text in order, and the
+// literals it skipped so that comment markers inside them are left alone.
+func scanSource(text string, syntax CommentSyntax) ([]commentSpan, []literalSpan) {
+	var spans []commentSpan
+	var literals []literalSpan
+	// lastCode is the last non-space byte outside comments, used to tell
+	// docstrings and regular expressions apart from other code.
+	var lastCode byte
+	i := 0
+	if strings.HasPrefix(text, "#!") {
+		i = lineEnd(text, 0)
+	}
+	for i < len(text) {
+		c := text[i]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			i++
+			continue
+		}
+
+		if delim := matchAny(text, i, syntax.RawStrings); delim != "" {
+			end := closeString(text, i+len(delim), delim, false, true)
+			literals = append(literals, literalSpan{start: i, end: end})
+			i = end
+			lastCode = text[i-1]
+			continue
+		}
+		if delim := matchAny(text, i, syntax.MultilineStrings); delim != "" {
+			end := closeString(text, i+len(delim), delim, true, true)
+			if containsString(syntax.Docstrings, delim) && isDocstring(text, i, end, lastCode) {
+				span := commentSpan{start: i, end: end, doc: true}
+				if docstringIsWholeBody(text, i, end) {
+					span.replacement = "..."
+				}
+				spans = append(spans, span)
+			} else {
+				literals = append(literals, literalSpan{start: i, end: end})
+				lastCode = text[end-1]
+			}
+			i = end
+			continue
+		}
+		if delim := matchAny(text, i, syntax.Strings); delim != "" && isStringStart(text, i, delim) {
+			end := closeString(text, i+len(delim), delim, true, false)
+			literals = append(literals, literalSpan{start: i, end: end})
+			i = end
+			lastCode = text[i-1]
+			continue
+		}
+		if c == '\'' {
+			if end := skipCharLiteral(text, i); end > i {
+				literals = append(literals, literalSpan{start: i, end: end})
+				i = end
+				lastCode = '\''
+				continue
+			}
+		}
+		if syntax.Regexps && c == '/' && (lastCode == 0 || strings.IndexByte(regexpPrecedingChars, lastCode) >= 0) {
+			if end := skipRegexp(text, i); end > i {
+				literals = append(literals, literalSpan{start: i, end: end})
+				i = end
+				lastCode = '/'
+				continue
+			}
+		}
+
+		if open, closer := matchBlock(text, i, syntax.Block); open != "" {
+			end := strings.Index(text[i+len(open):], closer)
+			if end < 0 {
+				end = len(text)
+			} else {
+				end += i + len(open) + len(closer)
+			}
+			spans = append(spans, commentSpan{
+				start:     i,
+				end:       end,
+				doc:       hasAnyPrefix(text[i:], syntax.DocComments) && end-i > len(open)+len(closer),
+				directive: hasAnyPrefix(text[i:], syntax.Directives),
+			})
+			i = end
+			continue
+		}
+		if marker := matchAny(text, i, syntax.Line); marker != "" && isLineCommentStart(text, i, marker) {
+			end := lineEnd(text, i)
+			spans = append(spans, commentSpan{
+				start:     i,
+				end:       end,
+				doc:       hasAnyPrefix(text[i:], syntax.DocComments),
+				directive: hasAnyPrefix(text[i:], syntax.Directives),
+			})
+			i = end
+			continue
+		}
+
+		lastCode = c
+		i++
+	}
+	return spans, literals
+}
+
+// licenseHeaderSpans returns the indexes of the leading comment spans that
+// form a license header. Comments separated by only whitespace belong to one
+// block; a blank line starts a new block, and the header ends at the first
+// code.
+func licenseHeaderSpans(text string, spans []commentSpan) []int {
+	var header, block []int
+	blockText := ""
+	flush := func() {
+		if licenseHeaderPattern.MatchString(blockText) {
+			header = append(header, block...)
+		}
+		block, blockText = nil, ""
+	}
+
+	prevEnd := 0
+	if strings.HasPrefix(text, "#!") {
+		prevEnd = lineEnd(text, 0)
+	}
+	for i, span := range spans {
+		gap := text[prevEnd:span.start]
+		if strings.TrimSpace(gap) != "" {
+			break
+		}
+		if strings.Count(gap, "\n") > 1 {
+			flush()
+		}
+		block = append(block, i)
+		blockText += text[span.start:span.end] + "\n"
+		prevEnd = span.end
+	}
+	flush()
+	return header
+}
+
+// removeSpans deletes spans from text line by line. Lines left empty by a
+// removal are dropped, as are blank lines that would pile up where they were.
+// With dropBlank every blank line is dropped.
+func removeSpans(text string, spans []commentSpan, dropBlank bool) string {
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	var b strings.Builder
+	b.Grow(len(text))
+	next := 0
+	lastWasBlank, droppedSince := true, false
+	for lineStart := 0; lineStart < len(text); {
+		end := lineEnd(text, lineStart)
+		newline := ""
+		if end < len(text) {
+			newline = "\n"
+		}
+		line := text[lineStart:end]
+		if strings.HasSuffix(line, "\r") {
+			line = line[:len(line)-1]
+			newline = "\r" + newline
+		}
+
+		touched := false
+		var kept strings.Builder
+		pos := lineStart
+		for next < len(spans) && spans[next].start < lineStart+len(line) {
+			span := spans[next]
+			if span.end <= pos {
+				next++
+				continue
+			}
+			touched = true
+			if span.start > pos {
+				kept.WriteString(text[pos:span.start])
+			}
+			if span.start >= lineStart {
+				kept.WriteString(span.replacement)
+			}
+			if span.end > lineStart+len(line) {
+				pos = lineStart + len(line)
+				break
+			}
+			pos = span.end
+			next++
+		}
+		if pos < lineStart+len(line) {
+			kept.WriteString(text[pos : lineStart+len(line)])
+		}
+
+		out := kept.String()
+		blank := strings.TrimSpace(out) == ""
+		switch {
+		case touched && blank:
+			droppedSince = true
+		case blank && (dropBlank || (droppedSince && lastWasBlank)):
+		default:
+			if touched {
+				out = strings.TrimRight(out, " \t")
+			}
+			b.WriteString(out)
+			b.WriteString(newline)
+			lastWasBlank, droppedSince = blank, false
+		}
+		lineStart = end + 1
+	}
+	return b.String()
+}
+
+// isDocstring reports whether the string at start..end is a Python
+// docstring: it opens a line, follows a "def ...:" or "class ...:" header or
+// starts the module, and nothing but a comment follows it on its last line.
+func isDocstring(text string, start, end int, lastCode byte) bool {
+	if lastCode != 0 && lastCode != ':' {
+		return false
+	}
+	lineStart := strings.LastIndexByte(text[:start], '\n') + 1
+	if strings.TrimSpace(text[lineStart:start]) != "" {
+		return false
+	}
+	rest := strings.TrimSpace(text[end:lineEnd(text, end)])
+	return rest == "" || strings.HasPrefix(rest, "#")
+}
+
+// docstringIsWholeBody reports whether no code at the docstring's
+// indentation follows it, so removing it would leave an empty body.
+func docstringIsWholeBody(text string, start, end int) bool {
+	lineStart := strings.LastIndexByte(text[:start], '\n') + 1
+	indent := start - lineStart
+	if indent == 0 {
+		return false
+	}
+	for pos := lineEnd(text, end) + 1; pos < len(text); {
+		lineEndPos := lineEnd(text, pos)
+		line := text[pos:lineEndPos]
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != "" && strings.TrimSpace(trimmed) != "" && !strings.HasPrefix(trimmed, "#") {
+			return len(line)-len(trimmed) < indent
+		}
+		pos = lineEndPos + 1
+	}
+	return true
+}
+
+// closeString returns the offset just past the delimiter that closes a
+// string opened before pos. Unless multiline is set the string also ends at
+// a newline; unless escapes is false a backslash escapes the next byte.
+func closeString(text string, pos int, delim string, escapes bool, multiline bool) int {
+	for pos < len(text) {
+		switch {
+		case escapes && text[pos] == '\\':
+			pos += 2
+			continue
+		case !multiline && text[pos] == '\n':
+			return pos
+		case strings.HasPrefix(text[pos:], delim):
+			return pos + len(delim)
+		}
+		pos++
+	}
+	return len(text)
+}
+
+// isStringStart rejects a single quote that follows an identifier or a
+// closing bracket, such as a MATLAB transpose or an English apostrophe in
+// code. Short string prefixes such as Python's r, b and f still count.
+func isStringStart(text string, i int, delim string) bool {
+	if delim != "'" || i == 0 {
+		return true
+	}
+	prev := text[i-1]
+	if prev == ')' || prev == ']' || prev == '}' || prev == '.' {
+		return false
+	}
+	if !isIdentByte(prev) {
+		return true
+	}
+	identStart := i
+	for identStart > 0 && isIdentByte(text[identStart-1]) {
+		identStart--
+	}
+	prefix := strings.ToLower(text[identStart:i])
+	return len(prefix) <= 2 && strings.Trim(prefix, "rbuf") == ""
+}
+
+// skipCharLiteral skips a character literal such as '"' or '\n' in languages
+// that do not list "'" as a string delimiter, returning i when there is none.
+func skipCharLiteral(text string, i int) int {
+	switch {
+	case i+2 < len(text) && text[i+1] != '\\' && text[i+1] != '\n' && text[i+2] == '\'':
+		return i + 3
+	case i+3 < len(text) && text[i+1] == '\\' && text[i+3] == '\'':
+		return i + 4
+	}
+	return i
+}
+
+// skipRegexp skips a JavaScript regular expression literal that starts at i,
+// returning i when the slash does not start one.
+func skipRegexp(text string, i int) int {
+	if i+1 >= len(text) || text[i+1] == '/' || text[i+1] == '*' {
+		return i
+	}
+	inClass := false
+	for pos := i + 1; pos < len(text); pos++ {
+		switch text[pos] {
+		case '\\':
+			pos++
+		case '\n':
+			return i
+		case '[':
+			inClass = true
+		case ']':
+			inClass = false
+		case '/':
+			if !inClass {
+				return pos + 1
+			}
+		}
+	}
+	return i
+}
+
+// isLineCommentStart requires single-character markers such as "#" and ";"
+// to open a line or follow whitespace or ";", so that "${#list}" in a shell
+// script or "key: a#b" in YAML are not taken for comments.
+func isLineCommentStart(text string, i int, marker string) bool {
+	if len(marker) > 1 || i == 0 {
+		return true
+	}
+	prev := text[i-1]
+	return prev == ' ' || prev == '\t' || prev == '\n' || prev == '\r' || (prev == ';' && marker != ";")
+}
+
+func matchAny(text string, i int, delims []string) string {
+	best := ""
+	for _, delim := range delims {
+		if len(delim) > len(best) && strings.HasPrefix(text[i:], delim) {
+			best = delim
+		}
+	}
+	return best
+}
+
+func matchBlock(text string, i int, blocks [][2]string) (string, string) {
+	for _, block := range blocks {
+		if strings.HasPrefix(text[i:], block[0]) {
+			return block[0], block[1]
+		}
+	}
+	return "", ""
+}
+
+func hasAnyPrefix(text string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(text, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// lineEnd returns the offset of the newline ending the line at pos, or
+// len(text) on the last line.
+func lineEnd(text string, pos int) int {
+	if end := strings.IndexByte(text[pos:], '\n'); end >= 0 {
+		return pos + end
+	}
+	return len(text)
+}
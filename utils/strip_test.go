@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStripSource(t *testing.T) {
+	comments := StripOptions{Comments: true}
+	tests := []struct {
+		name     string
+		language string
+		opts     StripOptions
+		text     string
+		expected string
+	}{
+		{
+			name:     "Go line and block comments",
+			language: "Go",
+			opts:     comments,
+			text:     "package main\n\n// helper does things.\nfunc helper() { /* inline */ return }\n\n/*\nblock\n*/\nvar x = 1 // trailing\n",
+			expected: "package main\n\nfunc helper() {  return }\n\nvar x = 1\n",
+		},
+		{
+			name:     "Go strings and raw strings keep their markers",
+			language: "Go",
+			opts:     comments,
+			text:     "var url = \"http://example.com\" // drop\nvar raw = `/* not a comment */`\nvar q = '\"' // drop\nvar esc = \"\\\" // still a string\"\n",
+			expected: "var url = \"http://example.com\"\nvar raw = `/* not a comment */`\nvar q = '\"'\nvar esc = \"\\\" // still a string\"\n",
+		},
+		{
+			name:     "Go directives are kept",
+			language: "Go",
+			opts:     comments,
+			text:     "//go:build linux\n\npackage main\n\n//go:embed data.txt\nvar data string\n",
+			expected: "//go:build linux\n\npackage main\n\n//go:embed data.txt\nvar data string\n",
+		},
+		{
+			name:     "Doc comments are kept unless docstrings is set",
+			language: "Rust",
+			opts:     comments,
+			text:     "/// Adds one.\nfn add(x: i32) -> i32 { x + 1 } // plain\n",
+			expected: "/// Adds one.\nfn add(x: i32) -> i32 { x + 1 }\n",
+		},
+		{
+			name:     "Doc comments are removed with docstrings",
+			language: "Rust",
+			opts:     StripOptions{Comments: true, Docstrings: true},
+			text:     "/// Adds one.\nfn add(x: i32) -> i32 { x + 1 }\n",
+			expected: "fn add(x: i32) -> i32 { x + 1 }\n",
+		},
+		{
+			name:     "Python hash comments, strings and shebang",
+			language: "Python",
+			opts:     comments,
+			text:     "#!/usr/bin/env python3\n# comment\nx = \"# not a comment\"  # comment\ny = '''\n# inside a string\n'''\n",
+			expected: "#!/usr/bin/env python3\nx = \"# not a comment\"\ny = '''\n# inside a string\n'''\n",
+		},
+		{
+			name:     "Python docstrings are kept by default",
+			language: "Python",
+			opts:     comments,
+			text:     "def f():\n    \"\"\"Doc.\"\"\"\n    return 1  # one\n",
+			expected: "def f():\n    \"\"\"Doc.\"\"\"\n    return 1\n",
+		},
+		{
+			name:     "Python docstrings are removed with docstrings",
+			language: "Python",
+			opts:     StripOptions{Docstrings: true},
+			text:     "\"\"\"Module doc.\"\"\"\n\nimport os\n\n\ndef f():\n    \"\"\"Doc.\n\n    More.\n    \"\"\"\n    return 1\n\n\ndef g():\n    \"\"\"Only a docstring.\"\"\"\n\n\nq = \"\"\"not a docstring\"\"\"\n",
+			expected: "import os\n\n\ndef f():\n    return 1\n\n\ndef g():\n    ...\n\n\nq = \"\"\"not a docstring\"\"\"\n",
+		},
+		{
+			name:     "Shell markers need whitespace before them",
+			language: "Shell",
+			opts:     comments,
+			text:     "echo ${#list} # count\necho a#b\n",
+			expected: "echo ${#list}\necho a#b\n",
+		},
+		{
+			name:     "JavaScript regular expressions and template strings",
+			language: "Javascript",
+			opts:     comments,
+			text:     "const re = /\\/\\//g; // slashes\nconst s = `// kept ${a}`;\nconst d = a / b; // div\n",
+			expected: "const re = /\\/\\//g;\nconst s = `// kept ${a}`;\nconst d = a / b;\n",
+		},
+		{
+			name:     "MATLAB transpose does not open a string",
+			language: "MATLAB",
+			opts:     comments,
+			text:     "y = x'; % transpose\nfprintf('%d\\n', y);\n",
+			expected: "y = x';\nfprintf('%d\\n', y);\n",
+		},
+		{
+			name:     "HTML comments",
+			language: "HTML",
+			opts:     comments,
+			text:     "<div>\n  <!-- note -->\n  <p>hi</p>\n</div>\n",
+			expected: "<div>\n  <p>hi</p>\n</div>\n",
+		},
+		{
+			name:     "License header only",
+			language: "Go",
+			opts:     StripOptions{License: true},
+			text:     "// Copyright 2024 Example Inc.\n// SPDX-License-Identifier: MIT\n\n// Package demo does things.\npackage demo\n",
+			expected: "// Package demo does things.\npackage demo\n",
+		},
+		{
+			name:     "License block comment after a shebang",
+			language: "Javascript",
+			opts:     StripOptions{License: true},
+			text:     "#!/usr/bin/env node\n/**\n * @license MIT\n */\nconsole.log(1) // keep\n",
+			expected: "#!/usr/bin/env node\nconsole.log(1) // keep\n",
+		},
+		{
+			name:     "License mode leaves later comments alone",
+			language: "Go",
+			opts:     StripOptions{License: true},
+			text:     "package demo\n\n// Copyright notice in the middle.\nvar x = 1\n",
+			expected: "package demo\n\n// Copyright notice in the middle.\nvar x = 1\n",
+		},
+		{
+			name:     "Blank lines",
+			language: "Go",
+			opts:     StripOptions{Blank: true},
+			text:     "package demo\n\n\t\nvar x = 1\r\n\r\nvar y = 2\n",
+			expected: "package demo\nvar x = 1\r\nvar y = 2\n",
+		},
+		{
+			name:     "Languages without comment syntax only lose blank lines",
+			language: "Markdown",
+			opts:     StripOptions{Comments: true, Blank: true},
+			text:     "# Title\n\n// text\n",
+			expected: "# Title\n// text\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := stripSource(tt.text, tt.language, tt.opts)
+			if actual != tt.expected {
+				t.Errorf("expected:\n%q\ngot:\n%q", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseStripOptions(t *testing.T) {
+	opts, err := ParseStripOptions([]string{"comments", " License", "BLANK", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts != (StripOptions{Comments: true, License: true, Blank: true}) {
+		t.Errorf("unexpected options %+v", opts)
+	}
+	if _, err := ParseStripOptions([]string{"whitespace"}); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
+
+func TestProcessSourceFiles_Strip(t *testing.T) {
+	orig := ActiveStripOptions
+	t.Cleanup(func() { ActiveStripOptions = orig })
+
+	tempDir := t.TempDir()
+	createTestFile(t, filepath.Join(tempDir, "main.go"), "// Copyright 2024 Example Inc.\n\npackage main\n\n// main runs.\nfunc main() {}\n")
+
+	ActiveStripOptions = StripOptions{Comments: true, License: true}
+	output := ProcessSourceFiles(tempDir, 5, map[string]struct{}{}, nil, map[string]struct{}{}, nil, true, false, nil)
+	if strings.Contains(output, "Copyright") || strings.Contains(output, "main runs") {
+		t.Errorf("expected comments to be stripped:\n%s", output)
+	}
+	if !strings.Contains(output, "package main\n\nfunc main() {}") {
+		t.Errorf("expected code to be kept:\n%s", output)
+	}
+	if !strings.Contains(output, "stripped: ") {
+		t.Errorf("expected the debug statistics to report the savings:\n%s", output)
+	}
+}
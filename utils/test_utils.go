@@ -15,7 +15,7 @@ func setupTestDir(t *testing.T) (string, func()) {
 }
 
 // createTestFile は指定されたパスにファイルを作成し、内容を書き込みます。
-func createTestFile(t *testing.T, path, content string) {
+func createTestFile(t testing.TB, path, content string) {
 	t.Helper()
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// TokenizerBPE approximates byte-pair encoders such as cl100k.
+	TokenizerBPE = "bpe"
+	// TokenizerChars uses the common "one token per four characters" rule.
+	TokenizerChars = "chars"
+)
+
+// MaxTokens is the total token budget for collected source files.
+var MaxTokens int64 = 0 // 0 means no limit
+
+// ActiveTokenizer is the tokenizer used for budgets and diagnostics.
+var ActiveTokenizer Tokenizer = bpeTokenizer{}
+
+// Tokenizer estimates how many LLM tokens a piece of text occupies.
+// Implementations work fully offline and never need a vocabulary download.
+type Tokenizer interface {
+	Name() string
+	CountTokens(text string) int
+}
+
+// GetTokenizer returns the tokenizer registered under name.
+func GetTokenizer(name string) (Tokenizer, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", TokenizerBPE:
+		return bpeTokenizer{}, nil
+	case TokenizerChars:
+		return charsTokenizer{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported tokenizer '%s'. Supported: %s, %s", name, TokenizerBPE, TokenizerChars)
+	}
+}
+
+// CountTokens counts tokens in text with the active tokenizer.
+func CountTokens(text string) int {
+	if ActiveTokenizer == nil {
+		return charsTokenizer{}.CountTokens(text)
+	}
+	return ActiveTokenizer.CountTokens(text)
+}
+
+var tokenCountPattern = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([kmKM]?)$`)
+
+// ParseTokenCount parses token counts like "8000", "128k" or "1.5m".
+// Unlike ParseSize, multipliers are decimal because model limits are quoted that way.
+func ParseTokenCount(countStr string) (int64, error) {
+	if countStr == "" {
+		return 0, nil
+	}
+
+	matches := tokenCountPattern.FindStringSubmatch(strings.TrimSpace(countStr))
+	if len(matches) != 3 {
+		return 0, fmt.Errorf("invalid token count: %s (expected formats: 8000, 128k, 1m)", countStr)
+	}
+
+	value, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number in token count: %s", matches[1])
+	}
+
+	var multiplier float64 = 1
+	switch strings.ToLower(matches[2]) {
+	case "k":
+		multiplier = 1000
+	case "m":
+		multiplier = 1000 * 1000
+	}
+
+	return int64(value * multiplier), nil
+}
+
+// charsTokenizer implements the chars/4 heuristic.
+type charsTokenizer struct{}
+
+func (charsTokenizer) Name() string { return TokenizerChars }
+
+func (charsTokenizer) CountTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// bpeTokenizer approximates a BPE tokenizer without its merge table.
+// Text is pre-tokenized the way cl100k splits it (letter runs with one leading
+// space or symbol, digit groups of up to three, symbol runs, whitespace runs),
+// then each piece is charged the number of merges it typically survives as.
+type bpeTokenizer struct{}
+
+func (bpeTokenizer) Name() string { return TokenizerBPE }
+
+func (bpeTokenizer) CountTokens(text string) int {
+	tokens := 0
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == '\'' && i+1 < len(runes) && isContractionStart(runes[i+1:]):
+			// 's, 't, 're, 've, 'm, 'll, 'd are single tokens.
+			i += contractionLength(runes[i+1:]) + 1
+			tokens++
+		case isLetter(r) || (!unicode.IsSpace(r) && !unicode.IsDigit(r) && i+1 < len(runes) && isLetter(runes[i+1])) || (r == ' ' && i+1 < len(runes) && isLetter(runes[i+1])):
+			// A letter run, optionally glued to one leading space or symbol.
+			if !isLetter(r) {
+				i++
+			}
+			start := i
+			for i < len(runes) && isLetter(runes[i]) {
+				i++
+			}
+			tokens += letterRunTokens(runes[start:i])
+		case unicode.IsDigit(r):
+			start := i
+			for i < len(runes) && unicode.IsDigit(runes[i]) {
+				i++
+			}
+			tokens += (i - start + 2) / 3
+		case r == '\n' || r == '\r':
+			for i < len(runes) && (runes[i] == '\n' || runes[i] == '\r') {
+				i++
+			}
+			tokens++
+		case unicode.IsSpace(r):
+			start := i
+			for i < len(runes) && unicode.IsSpace(runes[i]) && runes[i] != '\n' && runes[i] != '\r' {
+				i++
+			}
+			// Indentation runs merge well; a single space before a symbol
+			// is usually folded into the following piece.
+			if i-start > 1 || i >= len(runes) {
+				tokens++
+			}
+		default:
+			start := i
+			for i < len(runes) && !isLetter(runes[i]) && !unicode.IsDigit(runes[i]) && !unicode.IsSpace(runes[i]) {
+				i++
+			}
+			tokens += (i - start + 1) / 2
+		}
+	}
+	return tokens
+}
+
+func isLetter(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsMark(r)
+}
+
+func isContractionStart(rest []rune) bool {
+	return contractionLength(rest) > 0
+}
+
+func contractionLength(rest []rune) int {
+	for _, c := range []string{"ll", "re", "ve", "s", "t", "m", "d"} {
+		if len(rest) < len(c) {
+			continue
+		}
+		if strings.EqualFold(string(rest[:len(c)]), c) && (len(rest) == len(c) || !isLetter(rest[len(c)])) {
+			return len(c)
+		}
+	}
+	return 0
+}
+
+// letterRunTokens charges roughly one token per five ASCII letters and one per
+// rune for scripts (CJK, etc.) that cl100k rarely merges.
+func letterRunTokens(run []rune) int {
+	ascii := 0
+	other := 0
+	for _, r := range run {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	tokens := other
+	if ascii > 0 {
+		tokens += (ascii + 4) / 5
+	}
+	if tokens == 0 {
+		tokens = 1
+	}
+	return tokens
+}
@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTokenizer(t *testing.T) {
+	tok, err := GetTokenizer("")
+	require.NoError(t, err)
+	assert.Equal(t, TokenizerBPE, tok.Name())
+
+	tok, err = GetTokenizer("CHARS")
+	require.NoError(t, err)
+	assert.Equal(t, TokenizerChars, tok.Name())
+
+	_, err = GetTokenizer("tiktoken")
+	assert.Error(t, err)
+}
+
+func TestCharsTokenizer(t *testing.T) {
+	tok := charsTokenizer{}
+	assert.Equal(t, 0, tok.CountTokens(""))
+	assert.Equal(t, 1, tok.CountTokens("abc"))
+	assert.Equal(t, 1, tok.CountTokens("abcd"))
+	assert.Equal(t, 2, tok.CountTokens("abcde"))
+	// Runes, not bytes, are counted.
+	assert.Equal(t, 1, tok.CountTokens("日本語"))
+}
+
+func TestBPETokenizer(t *testing.T) {
+	tok := bpeTokenizer{}
+
+	tests := []struct {
+		name string
+		text string
+		want int
+	}{
+		{name: "empty", text: "", want: 0},
+		{name: "single word", text: "hello", want: 1},
+		{name: "words with leading spaces", text: "hello world again", want: 3},
+		{name: "contraction", text: "don't", want: 2},
+		{name: "digits are grouped by three", text: "1234567", want: 3},
+		{name: "newline run", text: "a\n\n\nb", want: 3},
+		{name: "indentation run", text: "\n\tx", want: 2},
+		{name: "cjk counts per rune", text: "日本語", want: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tok.CountTokens(tt.text))
+		})
+	}
+}
+
+func TestBPETokenizer_CodeIsInExpectedRange(t *testing.T) {
+	code := "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hello, world\")\n}\n"
+	got := bpeTokenizer{}.CountTokens(code)
+	// cl100k encodes this snippet as 21 tokens.
+	assert.InDelta(t, 21, got, 6)
+}
+
+func TestParseTokenCount(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{input: "", want: 0},
+		{input: "8000", want: 8000},
+		{input: "128k", want: 128000},
+		{input: "1.5M", want: 1500000},
+		{input: " 32K ", want: 32000},
+		{input: "12g", wantErr: true},
+		{input: "-5", wantErr: true},
+		{input: "abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseTokenCount(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestProjectScannerScan_TokenBudget(t *testing.T) {
+	origMaxTokens := MaxTokens
+	origTokenizer := ActiveTokenizer
+	t.Cleanup(func() {
+		MaxTokens = origMaxTokens
+		ActiveTokenizer = origTokenizer
+	})
+
+	tempDir := t.TempDir()
+	createTestFile(t, filepath.Join(tempDir, "a.go"), strings.Repeat("x", 40))
+	createTestFile(t, filepath.Join(tempDir, "b.go"), strings.Repeat("x", 40))
+	createTestFile(t, filepath.Join(tempDir, "c.go"), strings.Repeat("x", 40))
+
+	ActiveTokenizer = charsTokenizer{}
+	MaxTokens = 20 // a + b fit exactly, c exceeds
+
+	scanner := newProjectScannerForTest(tempDir)
+	scanner.collectStructure = false
+	scanner.collectReadmes = false
+
+	result, err := scanner.scan()
+	require.NoError(t, err)
+
+	sourceMarkdown := flattenSourceMarkdownByLanguage(result.sourceFileContents)
+	assert.Contains(t, sourceMarkdown, "### a.go")
+	assert.Contains(t, sourceMarkdown, "### b.go")
+	assert.NotContains(t, sourceMarkdown, "c.go")
+	assert.True(t, result.tokenLimitHit)
+	assert.False(t, result.limitHit)
+	assert.Equal(t, 20, result.totalTokens)
+	assert.Equal(t, map[string]int{"a.go": 10, "b.go": 10}, result.fileTokenCounts)
+}
+
+func TestProjectScannerScan_TokensPerFileAddUp(t *testing.T) {
+	origTokenizer := ActiveTokenizer
+	t.Cleanup(func() { ActiveTokenizer = origTokenizer })
+	ActiveTokenizer = charsTokenizer{}
+
+	tempDir := t.TempDir()
+	createTestFile(t, filepath.Join(tempDir, "main.go"), strings.Repeat("x", 40))
+	createTestFile(t, filepath.Join(tempDir, "package.json"), `{"name": "demo", "version": "1.0.0"}`)
+
+	scanner := newProjectScannerForTest(tempDir)
+	scanner.collectDependencies = true
+	result, err := scanner.scan()
+	require.NoError(t, err)
+
+	sum := 0
+	for _, tokens := range result.fileTokenCounts {
+		sum += tokens
+	}
+	assert.Contains(t, result.fileTokenCounts, "package.json")
+	assert.Equal(t, result.totalTokens, sum, "the tokens per file add up to the total")
+}
+
+func TestBuildMarkdownOutput_DebugReportsTokens(t *testing.T) {
+	origMaxTokens := MaxTokens
+	t.Cleanup(func() { MaxTokens = origMaxTokens })
+	MaxTokens = 100
+
+	output := buildMarkdownOutput("## Project Structure", map[string][]string{}, &scanResult{
+		sourceFileContents: map[string][]string{"Go": {"### z.go", "### a.go"}},
+		totalTokens:        42,
+		fileTokenCounts:    map[string]int{"z.go": 40, "a.go": 2},
+		tokenLimitHit:      true,
+	}, true)
+
+	assert.Contains(t, output, "**Token Statistics**: 42 tokens collected")
+	assert.Contains(t, output, "100 token limit reached")
+	aIndex := strings.Index(output, "- `a.go`: 2")
+	zIndex := strings.Index(output, "- `z.go`: 40")
+	require.NotEqual(t, -1, aIndex)
+	require.NotEqual(t, -1, zIndex)
+	assert.Less(t, aIndex, zIndex)
+	assert.Less(t, zIndex, strings.Index(output, "## Project Structure"))
+}
@@ -1,11 +1,15 @@
 package utils
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 // ParseSize parses human-readable size strings like "1k", "1m", "1g" and returns bytes.
@@ -56,9 +60,183 @@ func ParseSize(sizeStr string) (int64, error) {
 
 // SaveToMarkdown saves the generated Markdown content to a file or outputs it to stdout.
 func SaveToMarkdown(content string, outputPath string) error {
+	out, err := OpenOutput(outputPath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(out, content); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// OpenOutput opens outputPath for writing, or stdout when it is empty.
+// Closing the stdout writer ends the output with a newline.
+//
+// A file is written under a temporary name next to outputPath and renamed
+// into place by Close, so an interrupted or failed run leaves the previous
+// output intact. A symlink is replaced through its target, the mode of an
+// existing file is kept, and devices such as /dev/null are written
+// directly.
+func OpenOutput(outputPath string) (io.WriteCloser, error) {
 	if outputPath == "" {
-		fmt.Println(content)
-		return nil
+		return stdoutWriter{}, nil
+	}
+	path, perm := outputPath, os.FileMode(0644)
+	if target, err := filepath.EvalSymlinks(outputPath); err == nil {
+		info, err := os.Stat(target)
+		if err != nil {
+			return nil, err
+		}
+		if !info.Mode().IsRegular() {
+			return os.OpenFile(outputPath, os.O_WRONLY|os.O_TRUNC, 0)
+		}
+		path, perm = target, info.Mode().Perm()
+	}
+	return createAtomicFile(path, perm)
+}
+
+// RewriteOutput replaces the file at outputPath with content unless it
+// already holds exactly that, and reports whether the file was written.
+func RewriteOutput(outputPath string, content []byte) (bool, error) {
+	if current, err := os.ReadFile(outputPath); err == nil && bytes.Equal(current, content) {
+		return false, nil
+	}
+	out, err := OpenOutput(outputPath)
+	if err != nil {
+		return false, err
+	}
+	out.Write(content)
+	return true, out.Close()
+}
+
+// atomicFile is written under a temporary name and renamed to path by
+// Close, so readers see either the old or the new file and never a
+// partial one.
+type atomicFile struct {
+	tmp  *os.File
+	path string
+	perm os.FileMode
+	err  error
+}
+
+// pendingAtomicFiles are the atomicFiles not closed yet, whose temporary
+// files RemoveTemporaryFiles removes.
+var (
+	pendingAtomicFilesMu sync.Mutex
+	pendingAtomicFiles   = make(map[*atomicFile]struct{})
+)
+
+func createAtomicFile(path string, perm os.FileMode) (*atomicFile, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	f := &atomicFile{tmp: tmp, path: path, perm: perm}
+	pendingAtomicFilesMu.Lock()
+	pendingAtomicFiles[f] = struct{}{}
+	pendingAtomicFilesMu.Unlock()
+	return f, nil
+}
+
+// done takes f off the pending files, and reports false when it was taken
+// off already.
+func (f *atomicFile) done() bool {
+	pendingAtomicFilesMu.Lock()
+	defer pendingAtomicFilesMu.Unlock()
+	if _, ok := pendingAtomicFiles[f]; !ok {
+		return false
+	}
+	delete(pendingAtomicFiles, f)
+	return true
+}
+
+// discard closes and removes the temporary file.
+func (f *atomicFile) discard() {
+	f.tmp.Close()
+	os.Remove(f.tmp.Name())
+}
+
+// RemoveTemporaryFiles removes the temporary files of outputs that are
+// still being written, so that a run interrupted by a signal keeps the
+// previous output and leaves nothing behind.
+func RemoveTemporaryFiles() {
+	pendingAtomicFilesMu.Lock()
+	defer pendingAtomicFilesMu.Unlock()
+	for f := range pendingAtomicFiles {
+		f.discard()
+		delete(pendingAtomicFiles, f)
 	}
-	return os.WriteFile(outputPath, []byte(content), 0644)
-}
\ No newline at end of file
+}
+
+func (f *atomicFile) Write(p []byte) (int, error) {
+	n, err := f.tmp.Write(p)
+	if err != nil && f.err == nil {
+		f.err = err
+	}
+	return n, err
+}
+
+// Close renames the file into place, or removes it when a write failed.
+// The content is synced first, so that after a crash path holds either
+// the old or the new file.
+func (f *atomicFile) Close() error {
+	if !f.done() {
+		return os.ErrClosed
+	}
+	err := f.err
+	if err == nil {
+		err = f.tmp.Chmod(f.perm)
+	}
+	if err == nil {
+		err = f.tmp.Sync()
+	}
+	if closeErr := f.tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.tmp.Name(), f.path)
+	}
+	if err != nil {
+		os.Remove(f.tmp.Name())
+	}
+	return err
+}
+
+// DiscardOutput closes an output opened by OpenOutput after the run failed.
+// A file output keeps its previous content, and stdout does not get the
+// closing newline.
+func DiscardOutput(out io.WriteCloser) {
+	switch out := out.(type) {
+	case *atomicFile:
+		if out.done() {
+			out.discard()
+		}
+	case stdoutWriter:
+	default:
+		out.Close()
+	}
+}
+
+// writeFileAtomic writes data to path through an atomicFile.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := createAtomicFile(path, perm)
+	if err != nil {
+		return err
+	}
+	f.Write(data)
+	return f.Close()
+}
+
+// stdoutWriter writes to os.Stdout as it is at the time of each write.
+type stdoutWriter struct{}
+
+func (stdoutWriter) Write(p []byte) (int, error) {
+	return os.Stdout.Write(p)
+}
+
+func (stdoutWriter) Close() error {
+	_, err := fmt.Fprintln(os.Stdout)
+	return err
+}
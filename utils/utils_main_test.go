@@ -121,4 +121,138 @@ func TestSaveToMarkdownErrorPath(t *testing.T) {
 			t.Errorf("Expected SaveToMarkdown to return an error for invalid path, but it did not")
 		}
 	})
-}
\ No newline at end of file
+}
+
+func TestRewriteOutput(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "context.md")
+
+	written, err := RewriteOutput(path, []byte("# First"))
+	if err != nil || !written {
+		t.Fatalf("RewriteOutput() = %v, %v; want true, nil", written, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat output: %v", err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("Expected mode 0644, got %v", info.Mode().Perm())
+	}
+
+	written, err = RewriteOutput(path, []byte("# First"))
+	if err != nil || written {
+		t.Errorf("RewriteOutput() with unchanged content = %v, %v; want false, nil", written, err)
+	}
+
+	written, err = RewriteOutput(path, []byte("# Second"))
+	if err != nil || !written {
+		t.Fatalf("RewriteOutput() = %v, %v; want true, nil", written, err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "# Second" {
+		t.Errorf("Expected the new content, got %q", data)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected no temporary files to remain, got %d entries", len(entries))
+	}
+}
+
+func TestRemoveTemporaryFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "context.md")
+	if err := os.WriteFile(path, []byte("previous"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	out, err := OpenOutput(path)
+	if err != nil {
+		t.Fatalf("OpenOutput() error = %v", err)
+	}
+	out.Write([]byte("interrupted"))
+	RemoveTemporaryFiles()
+
+	if err := out.Close(); err == nil {
+		t.Errorf("Expected Close after RemoveTemporaryFiles to fail")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "previous" {
+		t.Errorf("Expected the previous output to be kept, got %q", data)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Expected no temporary files to remain, got %d entries", len(entries))
+	}
+}
+
+func TestOpenOutput_ReplacesFileOnClose(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "context.md")
+	if err := os.WriteFile(path, []byte("previous"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	out, err := OpenOutput(path)
+	if err != nil {
+		t.Fatalf("OpenOutput() error = %v", err)
+	}
+	out.Write([]byte("partial"))
+	if data, _ := os.ReadFile(path); string(data) != "previous" {
+		t.Errorf("Expected the previous output until Close, got %q", data)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "partial" {
+		t.Errorf("Expected the new output after Close, got %q", data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the mode of the replaced file to be kept, got %v", info.Mode().Perm())
+	}
+
+	link := filepath.Join(dir, "link.md")
+	if err := os.Symlink("context.md", link); err != nil {
+		t.Skipf("Symlinks are not supported: %v", err)
+	}
+	if err := SaveToMarkdown("through link", link); err != nil {
+		t.Fatalf("SaveToMarkdown() error = %v", err)
+	}
+	if info, _ := os.Lstat(link); info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Expected the symlink to be kept")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "through link" {
+		t.Errorf("Expected the symlink target to be replaced, got %q", data)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("Expected no temporary files to remain, got %d entries", len(entries))
+	}
+}
+
+func TestOpenOutput_FailedWriteKeepsPreviousOutput(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "context.md")
+	if err := os.WriteFile(path, []byte("previous"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	out, err := OpenOutput(path)
+	if err != nil {
+		t.Fatalf("OpenOutput() error = %v", err)
+	}
+	// Closing the temporary file first makes the next write fail.
+	out.(*atomicFile).tmp.Close()
+	if _, err := out.Write([]byte("partial")); err == nil {
+		t.Fatalf("Expected the write to fail")
+	}
+	if err := out.Close(); err == nil {
+		t.Errorf("Expected Close to report the failed write")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "previous" {
+		t.Errorf("Expected the previous output to be kept, got %q", data)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected the temporary file to be removed, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,342 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WatchDebounce is how long WatchProject waits after the last change of a
+// burst before it runs again.
+var WatchDebounce = 300 * time.Millisecond
+
+// WatchPollInterval is how often the polling watcher walks the project.
+var WatchPollInterval = time.Second
+
+// WatchPoll makes WatchProject poll the project even where the platform
+// can report changes.
+var WatchPoll = false
+
+// watchFilter decides which changed paths concern a scan with the same
+// include, exclude, .gitignore, test, asset and language rules.
+type watchFilter struct {
+	rootPath string
+
+	includePaths   map[string]struct{}
+	includeMatcher *SimpleMatcher
+	excludeNames   map[string]struct{}
+	excludeMatcher *SimpleMatcher
+	gi             *GitIgnoreMatcher
+	includeTests   bool
+
+	// ignored files never count as changes, together with the temporary
+	// files writeFileAtomic creates next to them.
+	ignored map[string]struct{}
+}
+
+// skips reports whether a changed path below the root is skipped. The
+// watchers only look into directories that are not skipped, so the
+// ancestors of absPath have passed already. A .gitignore is never skipped,
+// because its rules decide what the scan sees.
+func (f *watchFilter) skips(absPath string, isDir bool) bool {
+	if absPath == f.rootPath {
+		return false
+	}
+	if _, ok := f.ignored[absPath]; ok {
+		return true
+	}
+	dir, name := filepath.Split(absPath)
+	for ignored := range f.ignored {
+		if filepath.Dir(ignored)+string(filepath.Separator) == dir && strings.HasPrefix(name, filepath.Base(ignored)+".tmp-") {
+			return true
+		}
+	}
+	if isGitIgnoreFile(absPath, isDir) {
+		return false
+	}
+	return shouldSkipPath(absPath, name, isDir, f.includePaths, f.includeMatcher, f.excludeNames, f.excludeMatcher, f.gi)
+}
+
+func isGitIgnoreFile(absPath string, isDir bool) bool {
+	return !isDir && filepath.Base(absPath) == ".gitignore"
+}
+
+// gitIgnoreChanged makes the rules of a changed .gitignore apply from now
+// on, to the watchers and to the next run, which share the matcher.
+func (f *watchFilter) gitIgnoreChanged(absPath string) {
+	f.gi.forget(filepath.Dir(absPath))
+}
+
+// concerns reports whether a change that is not skipped shows in the
+// output: directories and .gitignore files always do, dependency files
+// and READMEs are collected whatever their language, and other files
+// count when the tree lists them, so tests, assets and files of other
+// languages do not. Language detection depends on the last run, so
+// concerns is called between runs.
+func (f *watchFilter) concerns(change watchChange) bool {
+	name := filepath.Base(change.path)
+	if change.isDir || isGitIgnoreFile(change.path, change.isDir) {
+		return true
+	}
+	if _, isLockfile := lockfileSummarizers[name]; isLockfile || isDependencyManifest(name) || strings.EqualFold(name, "readme.md") {
+		return true
+	}
+	scanner := projectScanner{includePaths: f.includePaths, includeMatcher: f.includeMatcher, includeTests: f.includeTests}
+	return shouldIncludeInStructure(scanner.buildFileScanMeta(change.path))
+}
+
+// walkDirs calls fn for root and every directory below it that is not
+// skipped.
+func (f *watchFilter) walkDirs(root string, debug bool, fn func(dir string) error) error {
+	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			PrintWarning(fmt.Sprintf("Error accessing path %s: %v", path, err), debug)
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if f.skips(path, true) {
+			return filepath.SkipDir
+		}
+		return fn(path)
+	})
+}
+
+// watchChange is a created, changed, renamed or removed path that is not
+// skipped.
+type watchChange struct {
+	path  string
+	isDir bool
+}
+
+// changeWatcher signals on changes() after files or directories that are
+// not skipped were created, changed, renamed or removed, and take returns
+// them. Signals are not queued; one pending signal stands for any number
+// of changes.
+type changeWatcher interface {
+	changes() <-chan struct{}
+	take() []watchChange
+	close() error
+}
+
+// changeSet holds the changes a watcher saw until they are taken.
+type changeSet struct {
+	mu      sync.Mutex
+	pending []watchChange
+	ch      chan struct{}
+}
+
+func newChangeSet() *changeSet {
+	return &changeSet{ch: make(chan struct{}, 1)}
+}
+
+func (c *changeSet) add(change watchChange) {
+	c.mu.Lock()
+	c.pending = append(c.pending, change)
+	c.mu.Unlock()
+	notify(c.ch)
+}
+
+func (c *changeSet) changes() <-chan struct{} {
+	return c.ch
+}
+
+func (c *changeSet) take() []watchChange {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	changes := c.pending
+	c.pending = nil
+	return changes
+}
+
+// newChangeWatcher returns the native watcher of the platform, or a
+// polling watcher when WatchPoll is set or the native one is unavailable.
+func newChangeWatcher(filter *watchFilter, debug bool) changeWatcher {
+	if !WatchPoll {
+		watcher, err := newNativeWatcher(filter, debug)
+		if err == nil {
+			PrintDebug("Watching for changes with "+nativeWatcherName, debug)
+			return watcher
+		}
+		PrintWarning(fmt.Sprintf("Falling back to polling: %v", err), debug)
+	}
+	PrintDebug(fmt.Sprintf("Watching for changes by polling every %s", WatchPollInterval), debug)
+	return newPollWatcher(filter, WatchPollInterval, debug)
+}
+
+// notify records a pending change without blocking.
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// pollWatcher walks the project every interval and signals when the size,
+// modification time or kind of an entry changed, or entries came or went.
+type pollWatcher struct {
+	*changeSet
+	filter *watchFilter
+	debug  bool
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+type pollState struct {
+	size    int64
+	modTime time.Time
+	isDir   bool
+}
+
+func newPollWatcher(filter *watchFilter, interval time.Duration, debug bool) *pollWatcher {
+	w := &pollWatcher{
+		changeSet: newChangeSet(),
+		filter:    filter,
+		debug:     debug,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	previous := w.snapshot()
+	go func() {
+		defer close(w.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				current := w.snapshot()
+				for _, change := range changedEntries(previous, current) {
+					if isGitIgnoreFile(change.path, change.isDir) {
+						w.filter.gitIgnoreChanged(change.path)
+					}
+					w.add(change)
+				}
+				previous = current
+			}
+		}
+	}()
+	return w
+}
+
+// snapshot records every entry of the project that is not skipped.
+func (w *pollWatcher) snapshot() map[string]pollState {
+	states := make(map[string]pollState)
+	w.filter.walkDirs(w.filter.rootPath, w.debug, func(dir string) error {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			PrintWarning(fmt.Sprintf("Error accessing path %s: %v", dir, err), w.debug)
+			return nil
+		}
+		for _, entry := range entries {
+			path := filepath.Join(dir, entry.Name())
+			if w.filter.skips(path, entry.IsDir()) {
+				continue
+			}
+			state := pollState{isDir: entry.IsDir()}
+			if !entry.IsDir() {
+				info, err := entry.Info()
+				if err != nil {
+					continue
+				}
+				state.size = info.Size()
+				state.modTime = info.ModTime()
+			}
+			states[path] = state
+		}
+		return nil
+	})
+	return states
+}
+
+// changedEntries returns the entries that differ between two snapshots.
+func changedEntries(previous, current map[string]pollState) []watchChange {
+	var changes []watchChange
+	for path, state := range previous {
+		other, ok := current[path]
+		if !ok || other.size != state.size || other.isDir != state.isDir || !other.modTime.Equal(state.modTime) {
+			changes = append(changes, watchChange{path: path, isDir: state.isDir})
+		}
+	}
+	for path, state := range current {
+		if _, ok := previous[path]; !ok {
+			changes = append(changes, watchChange{path: path, isDir: state.isDir})
+		}
+	}
+	return changes
+}
+
+func (w *pollWatcher) close() error {
+	close(w.stop)
+	<-w.done
+	return nil
+}
+
+// WatchProject calls run once and then again whenever files or directories
+// below rootPath change that a scan with the same include, exclude,
+// .gitignore, test, asset and language rules would see. An edited
+// .gitignore is read again, and its rules apply to the next run through
+// gi. Changes to ignorePaths, such as the output file, are not counted. A
+// burst of changes leads to a single run, once no change was seen for
+// WatchDebounce. An error from the first run is returned; later errors are
+// printed and watching goes on until ctx ends.
+func WatchProject(ctx context.Context, rootPath string, includePaths map[string]struct{}, includeMatcher *SimpleMatcher, excludeNames map[string]struct{}, excludeMatcher *SimpleMatcher, gi *GitIgnoreMatcher, includeTests bool, ignorePaths []string, debug bool, run func() error) error {
+	absRoot, err := normalizeAbsolutePath(rootPath)
+	if err != nil {
+		return err
+	}
+	filter := &watchFilter{
+		rootPath:       absRoot,
+		includePaths:   includePaths,
+		includeMatcher: includeMatcher,
+		excludeNames:   excludeNames,
+		excludeMatcher: excludeMatcher,
+		gi:             gi,
+		includeTests:   includeTests,
+		ignored:        make(map[string]struct{}),
+	}
+	for _, path := range ignorePaths {
+		if abs, err := normalizeAbsolutePath(path); err == nil {
+			filter.ignored[abs] = struct{}{}
+		}
+	}
+
+	// The watcher starts first, so changes made during the first run
+	// lead to another one.
+	watcher := newChangeWatcher(filter, debug)
+	defer watcher.close()
+	if err := run(); err != nil {
+		return err
+	}
+
+	debounce := time.NewTimer(WatchDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-watcher.changes():
+			for _, change := range watcher.take() {
+				if filter.concerns(change) {
+					debounce.Reset(WatchDebounce)
+					break
+				}
+			}
+		case <-debounce.C:
+			PrintDebug("Change detected, regenerating output", debug)
+			if err := run(); err != nil {
+				PrintError(err.Error())
+			}
+		}
+	}
+}
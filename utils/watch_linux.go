@@ -0,0 +1,140 @@
+//go:build linux
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const nativeWatcherName = "inotify"
+
+// inotifyMask selects the events that change what a scan sees. Modified
+// files report IN_MODIFY; IN_ATTRIB alone only touches a file.
+const inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ONLYDIR
+
+// inotifyWatcher watches every directory that is not skipped with inotify
+// and adds the directories created later.
+type inotifyWatcher struct {
+	*changeSet
+	filter *watchFilter
+	debug  bool
+	file   *os.File
+	fd     int
+	dirs   map[int32]string
+	done   chan struct{}
+}
+
+func newNativeWatcher(filter *watchFilter, debug bool) (changeWatcher, error) {
+	// A non-blocking descriptor lets the runtime poller wake the reader
+	// when the file is closed.
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify: %w", err)
+	}
+	w := &inotifyWatcher{
+		changeSet: newChangeSet(),
+		filter:    filter,
+		debug:     debug,
+		file:      os.NewFile(uintptr(fd), "inotify"),
+		fd:        fd,
+		dirs:      make(map[int32]string),
+		done:      make(chan struct{}),
+	}
+	if err := w.addTree(filter.rootPath); err != nil {
+		w.file.Close()
+		return nil, err
+	}
+	go w.readEvents()
+	return w, nil
+}
+
+// addTree watches dir and the directories below it that are not skipped.
+// Running out of watches is an error; other directories that cannot be
+// watched are reported and left out.
+func (w *inotifyWatcher) addTree(dir string) error {
+	return w.filter.walkDirs(dir, w.debug, func(path string) error {
+		wd, err := syscall.InotifyAddWatch(w.fd, path, inotifyMask)
+		if err != nil {
+			if errors.Is(err, syscall.ENOSPC) {
+				return fmt.Errorf("inotify watch limit reached at %s (see fs.inotify.max_user_watches)", path)
+			}
+			PrintWarning(fmt.Sprintf("Could not watch %s: %v", path, err), w.debug)
+			return nil
+		}
+		w.dirs[int32(wd)] = path
+		return nil
+	})
+}
+
+func (w *inotifyWatcher) readEvents() {
+	defer close(w.done)
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				PrintWarning(fmt.Sprintf("Reading inotify events failed: %v", err), w.debug)
+			}
+			return
+		}
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			offset = nameStart + int(event.Len)
+			if offset > n {
+				break
+			}
+			name := strings.TrimRight(string(buf[nameStart:offset]), "\x00")
+			w.handle(event.Wd, event.Mask, name)
+		}
+	}
+}
+
+// handle updates the watched directories for one event and records it
+// when it changes what a scan sees.
+func (w *inotifyWatcher) handle(wd int32, mask uint32, name string) {
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		// Events were dropped; treat it as a change of the whole project.
+		w.add(watchChange{path: w.filter.rootPath, isDir: true})
+		return
+	}
+	if mask&syscall.IN_IGNORED != 0 {
+		delete(w.dirs, wd)
+		return
+	}
+	dir, ok := w.dirs[wd]
+	if !ok || name == "" {
+		return
+	}
+	path := filepath.Join(dir, name)
+	isDir := mask&syscall.IN_ISDIR != 0
+	if w.filter.skips(path, isDir) {
+		return
+	}
+	if isDir && mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+		if err := w.addTree(path); err != nil {
+			PrintWarning(err.Error(), w.debug)
+		}
+	}
+	// Directories the new rules no longer ignore are watched from now on.
+	if isGitIgnoreFile(path, isDir) {
+		w.filter.gitIgnoreChanged(path)
+		if err := w.addTree(dir); err != nil {
+			PrintWarning(err.Error(), w.debug)
+		}
+	}
+	w.add(watchChange{path: path, isDir: isDir})
+}
+
+func (w *inotifyWatcher) close() error {
+	err := w.file.Close()
+	<-w.done
+	return err
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package utils
+
+import (
+	"errors"
+	"runtime"
+)
+
+const nativeWatcherName = "none"
+
+// newNativeWatcher is only implemented with inotify on Linux; elsewhere
+// WatchProject polls.
+func newNativeWatcher(filter *watchFilter, debug bool) (changeWatcher, error) {
+	return nil, errors.New("no native file watcher on " + runtime.GOOS)
+}
@@ -0,0 +1,219 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withFastWatch shortens the watch intervals for a test.
+func withFastWatch(t *testing.T, poll bool) {
+	t.Helper()
+	origDebounce, origInterval, origPoll := WatchDebounce, WatchPollInterval, WatchPoll
+	t.Cleanup(func() { WatchDebounce, WatchPollInterval, WatchPoll = origDebounce, origInterval, origPoll })
+	WatchDebounce = 100 * time.Millisecond
+	WatchPollInterval = 20 * time.Millisecond
+	WatchPoll = poll
+}
+
+func newTestWatchFilter(t *testing.T, root string, ignored ...string) *watchFilter {
+	t.Helper()
+	gi, err := NewGitIgnoreMatcher(root)
+	require.NoError(t, err)
+	filter := &watchFilter{
+		rootPath:     root,
+		includePaths: map[string]struct{}{},
+		excludeNames: map[string]struct{}{"node_modules": {}},
+		gi:           gi,
+		ignored:      make(map[string]struct{}),
+	}
+	for _, path := range ignored {
+		filter.ignored[path] = struct{}{}
+	}
+	return filter
+}
+
+// waitForChange reports whether the watcher signals within timeout.
+func waitForChange(w changeWatcher, timeout time.Duration) bool {
+	select {
+	case <-w.changes():
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func TestWatchFilter_Skips(t *testing.T) {
+	root := t.TempDir()
+	createTestFile(t, filepath.Join(root, ".gitignore"), "*.log\n")
+	output := filepath.Join(root, "context.md")
+	filter := newTestWatchFilter(t, root, output)
+
+	assert.False(t, filter.skips(filepath.Join(root, "main.go"), false))
+	assert.False(t, filter.skips(filepath.Join(root, "pkg"), true))
+	assert.True(t, filter.skips(output, false), "the output file is ignored")
+	assert.True(t, filter.skips(filepath.Join(root, "context.md.tmp-123"), false), "temporary files of the output are ignored")
+	assert.True(t, filter.skips(filepath.Join(root, "debug.log"), false), ".gitignore rules apply")
+	assert.True(t, filter.skips(filepath.Join(root, "node_modules"), true), "excluded names apply")
+	assert.False(t, filter.skips(filepath.Join(root, ".gitignore"), false), "a .gitignore is watched although it is a dotfile")
+}
+
+func TestWatchFilter_Concerns(t *testing.T) {
+	withLanguageFilter(t, []string{"go"}, nil)
+	root := t.TempDir()
+	createTestFile(t, filepath.Join(root, "main.go"), "package main\n")
+	createTestFile(t, filepath.Join(root, "main_test.go"), "package main\n")
+	createTestFile(t, filepath.Join(root, "app.py"), "print(1)\n")
+	createTestFile(t, filepath.Join(root, "logo.png"), "\x89PNG\r\n\x1a\n")
+	createTestFile(t, filepath.Join(root, "package.json"), "{}\n")
+	filter := newTestWatchFilter(t, root)
+
+	concerns := func(name string) bool {
+		return filter.concerns(watchChange{path: filepath.Join(root, name)})
+	}
+	assert.True(t, concerns("main.go"))
+	assert.True(t, concerns(".gitignore"))
+	assert.True(t, concerns("package.json"), "dependency files are collected whatever the languages")
+	assert.True(t, filter.concerns(watchChange{path: filepath.Join(root, "pkg"), isDir: true}))
+	assert.False(t, concerns("main_test.go"), "test files are left out")
+	assert.False(t, concerns("logo.png"), "assets are left out")
+	assert.False(t, concerns("app.py"), "files of other languages are left out")
+
+	filter.includeTests = true
+	assert.True(t, concerns("main_test.go"), "--include-tests keeps test files")
+}
+
+func TestChangeWatcher_ReportsIncludedChanges(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		poll bool
+	}{
+		{name: "native", poll: false},
+		{name: "polling", poll: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			withFastWatch(t, tc.poll)
+			root := t.TempDir()
+			createTestFile(t, filepath.Join(root, ".gitignore"), "*.log\n")
+			createTestFile(t, filepath.Join(root, "main.go"), "package main\n")
+			createTestFile(t, filepath.Join(root, "node_modules", "dep.js"), "module.exports = {}\n")
+			watcher := newChangeWatcher(newTestWatchFilter(t, root), false)
+			defer watcher.close()
+
+			createTestFile(t, filepath.Join(root, "debug.log"), "ignored\n")
+			createTestFile(t, filepath.Join(root, "node_modules", "dep.js"), "module.exports = {a: 1}\n")
+			assert.False(t, waitForChange(watcher, 300*time.Millisecond), "skipped paths are not reported")
+
+			createTestFile(t, filepath.Join(root, "main.go"), "package main\n\nfunc main() {}\n")
+			assert.True(t, waitForChange(watcher, 2*time.Second), "an edited file is reported")
+
+			createTestFile(t, filepath.Join(root, "pkg", "lib.go"), "package pkg\n")
+			assert.True(t, waitForChange(watcher, 2*time.Second), "a new directory is reported")
+			waitForChange(watcher, 200*time.Millisecond)
+			createTestFile(t, filepath.Join(root, "pkg", "lib.go"), "package pkg\n\nvar X = 1\n")
+			assert.True(t, waitForChange(watcher, 2*time.Second), "files in a new directory are watched")
+		})
+	}
+}
+
+func TestChangeWatcher_ReloadsGitIgnore(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		poll bool
+	}{
+		{name: "native", poll: false},
+		{name: "polling", poll: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			withFastWatch(t, tc.poll)
+			root := t.TempDir()
+			createTestFile(t, filepath.Join(root, ".gitignore"), "build/\n")
+			createTestFile(t, filepath.Join(root, "build", "gen.go"), "package build\n")
+			filter := newTestWatchFilter(t, root)
+			watcher := newChangeWatcher(filter, false)
+			defer watcher.close()
+
+			createTestFile(t, filepath.Join(root, ".gitignore"), "*.log\n")
+			assert.True(t, waitForChange(watcher, 2*time.Second), "an edited .gitignore is reported")
+			assert.Contains(t, watcher.take(), watchChange{path: filepath.Join(root, ".gitignore")})
+			assert.True(t, filter.skips(filepath.Join(root, "debug.log"), false), "new rules apply")
+			assert.False(t, filter.skips(filepath.Join(root, "build"), true), "dropped rules no longer apply")
+
+			waitForChange(watcher, 200*time.Millisecond)
+			watcher.take()
+			createTestFile(t, filepath.Join(root, "build", "gen.go"), "package build\n\nvar X = 1\n")
+			assert.True(t, waitForChange(watcher, 2*time.Second), "a directory that is no longer ignored is watched")
+		})
+	}
+}
+
+func TestWatchProject_DebouncesBursts(t *testing.T) {
+	withFastWatch(t, false)
+	root := t.TempDir()
+	createTestFile(t, filepath.Join(root, "main.go"), "package main\n")
+
+	var runs atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- WatchProject(ctx, root, map[string]struct{}{}, nil, map[string]struct{}{}, nil, nil, false, nil, false, func() error {
+			runs.Add(1)
+			return nil
+		})
+	}()
+
+	require.Eventually(t, func() bool { return runs.Load() == 1 }, 2*time.Second, 10*time.Millisecond, "the output is written when watching starts")
+	for i := 0; i < 5; i++ {
+		createTestFile(t, filepath.Join(root, "main.go"), "package main\n"+string(rune('a'+i))+"\n")
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Eventually(t, func() bool { return runs.Load() == 2 }, 2*time.Second, 10*time.Millisecond)
+	time.Sleep(3 * WatchDebounce)
+	assert.Equal(t, int32(2), runs.Load(), "a burst of changes leads to one run")
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("WatchProject did not return after its context ended")
+	}
+}
+
+func TestWatchProject_IgnoresFilteredFiles(t *testing.T) {
+	withFastWatch(t, false)
+	root := t.TempDir()
+	createTestFile(t, filepath.Join(root, "main.go"), "package main\n")
+
+	var runs atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go WatchProject(ctx, root, map[string]struct{}{}, nil, map[string]struct{}{}, nil, nil, false, nil, false, func() error {
+		runs.Add(1)
+		return nil
+	})
+
+	require.Eventually(t, func() bool { return runs.Load() == 1 }, 2*time.Second, 10*time.Millisecond)
+	createTestFile(t, filepath.Join(root, "main_test.go"), "package main\n")
+	createTestFile(t, filepath.Join(root, "logo.png"), "\x89PNG\r\n\x1a\n")
+	time.Sleep(4 * WatchDebounce)
+	assert.Equal(t, int32(1), runs.Load(), "changes to tests and assets do not regenerate the output")
+
+	createTestFile(t, filepath.Join(root, "main.go"), "package main\n\nfunc main() {}\n")
+	require.Eventually(t, func() bool { return runs.Load() == 2 }, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestWatchProject_ReturnsFirstRunError(t *testing.T) {
+	withFastWatch(t, false)
+	errRun := errors.New("cannot write")
+	err := WatchProject(context.Background(), t.TempDir(), map[string]struct{}{}, nil, map[string]struct{}{}, nil, nil, false, nil, false, func() error {
+		return errRun
+	})
+	assert.ErrorIs(t, err, errRun)
+}